@@ -1,19 +1,208 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/rs/zerolog/log"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/nelsonalves117/go-products-api/internal/channels/rest"
 	"github.com/nelsonalves117/go-products-api/internal/config"
+	"github.com/nelsonalves117/go-products-api/internal/fx"
+	"github.com/nelsonalves117/go-products-api/internal/lifecycle"
+	"github.com/nelsonalves117/go-products-api/internal/notify"
+	"github.com/nelsonalves117/go-products-api/internal/repositories"
+	"github.com/nelsonalves117/go-products-api/internal/service"
 )
 
 func main() {
-	config.Parse()
+	cfg, err := config.Parse("config/config.yaml")
+	if err != nil {
+		log.Panic().Err(err).Msg("an error occurred while trying to load the config")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	server := rest.New()
+	logger := logrus.New()
+	lc := lifecycle.New(cfg.ShutdownGracePeriod, logger)
 
-	err := server.Start()
+	repo, err := newRepository(cfg, lc, logger)
 	if err != nil {
-		log.Panic().Err(err).Msg("an error occurred while trying to start the server")
+		log.Panic().Err(err).Msg("an error occurred while trying to create the repository")
 	}
+
+	rates := fx.NewCache(fx.NewFileProvider(cfg.FX.RatesFile), cfg.FX.CacheTTL)
+
+	svc := service.New(cfg, repo, rates, newNotifier(cfg, logger), logger)
+
+	if len(os.Args) > 1 && os.Args[1] == "reconcile-stock" {
+		err = reconcileStock(ctx, lc, svc, logger, os.Args[2:])
+		if err != nil {
+			log.Panic().Err(err).Msg("an error occurred while reconciling stock")
+		}
+
+		return
+	}
+
+	server := rest.New(cfg, svc, logger)
+
+	lc.Append(lifecycle.Hook{
+		Name:   "http server",
+		Run:    server.Start,
+		OnStop: server.Shutdown,
+	})
+
+	lc.AppendPeriodic("trash purge", cfg.Trash.PurgeInterval, func(ctx context.Context) error {
+		_, err := svc.PurgeDeletedProducts(ctx)
+		return err
+	})
+	lc.AppendPeriodic("price scheduler", cfg.Prices.SchedulerInterval, func(ctx context.Context) error {
+		_, err := svc.ApplyDuePriceChanges(ctx)
+		return err
+	})
+	lc.AppendPeriodic("reservation reaper", cfg.Reservations.ReaperInterval, func(ctx context.Context) error {
+		_, err := svc.ReleaseExpiredReservations(ctx)
+		return err
+	})
+	lc.AppendPeriodic("low-stock alert sender", cfg.Alerts.SendInterval, func(ctx context.Context) error {
+		_, err := svc.SendLowStockAlerts(ctx)
+		return err
+	})
+
+	err = lc.Run(ctx)
+	if err != nil {
+		log.Panic().Err(err).Msg("an error occurred while running the server")
+	}
+}
+
+// reconcileStock runs the reconcile-stock command, which reports the products
+// whose stock does not match the stock movement ledger. With -fix it appends the
+// movements that make the ledger match instead of failing.
+func reconcileStock(ctx context.Context, lc *lifecycle.Lifecycle, svc service.Service, logger logrus.FieldLogger, args []string) error {
+	flags := flag.NewFlagSet("reconcile-stock", flag.ContinueOnError)
+	fix := flags.Bool("fix", false, "append movements that make the ledger match the stored stock")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	return lc.RunOnce(ctx, func(ctx context.Context) error {
+		discrepancies, err := svc.ReconcileStock(ctx, *fix)
+		if err != nil {
+			return err
+		}
+
+		for _, discrepancy := range discrepancies {
+			logger.WithFields(logrus.Fields{
+				"product_id": discrepancy.ProductId,
+				"field":      discrepancy.Field,
+				"warehouse":  discrepancy.Warehouse,
+				"stored":     discrepancy.Stored,
+				"ledger":     discrepancy.Ledger,
+			}).Warn("stock does not match the ledger")
+		}
+
+		if len(discrepancies) > 0 && !*fix {
+			return fmt.Errorf("%d stock discrepancies found", len(discrepancies))
+		}
+
+		logger.WithField("discrepancies", len(discrepancies)).Info("stock reconciled")
+
+		return nil
+	})
+}
+
+// newNotifier builds the Notifier selected by the alerts config key.
+func newNotifier(cfg config.Config, logger logrus.FieldLogger) notify.Notifier {
+	if cfg.Alerts.Notifier == config.NotifierWebhook {
+		return notify.NewWebhookNotifier(cfg.Alerts.WebhookURL, cfg.Alerts.WebhookTimeout)
+	}
+
+	return notify.NewLogNotifier(logger)
+}
+
+// newRepository builds the Repository selected by the storage config key and
+// ties the MongoDB client, when there is one, to the application lifecycle.
+func newRepository(cfg config.Config, lc *lifecycle.Lifecycle, logger logrus.FieldLogger) (repositories.Repository, error) {
+	if cfg.Storage == config.StorageMemory {
+		return repositories.NewMemory(), nil
+	}
+
+	opts := options.Client().
+		ApplyURI(cfg.ConnectionString).
+		SetRegistry(repositories.NewMongoRegistry(cfg.Currency))
+
+	client, err := mongo.Connect(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	db := client.Database(cfg.Database)
+
+	lc.Append(lifecycle.Hook{
+		Name: "mongodb",
+		OnStart: func(ctx context.Context) error {
+			err := client.Ping(ctx, nil)
+			if err != nil {
+				return err
+			}
+
+			err = repositories.CreateMongoIndexes(ctx, db)
+			if err != nil {
+				return err
+			}
+
+			migrated, err := repositories.MigrateLegacyPrices(ctx, db, cfg.Currency)
+			if err != nil {
+				return err
+			}
+
+			if migrated > 0 {
+				logger.WithField("migrated", migrated).Info("migrated legacy prices")
+			}
+
+			migrated, err = repositories.MigrateLegacyStock(ctx, db, cfg.Stock.Warehouses[0])
+			if err != nil {
+				return err
+			}
+
+			if migrated > 0 {
+				logger.WithField("migrated", migrated).Info("migrated legacy stock")
+			}
+
+			migrated, err = repositories.MigrateLegacyCategories(ctx, db)
+			if err != nil {
+				return err
+			}
+
+			if migrated > 0 {
+				logger.WithField("migrated", migrated).Info("migrated legacy categories")
+			}
+
+			migrated, err = repositories.MigrateLegacyStatus(ctx, db)
+			if err != nil {
+				return err
+			}
+
+			if migrated > 0 {
+				logger.WithField("migrated", migrated).Info("migrated legacy statuses")
+			}
+
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return client.Disconnect(ctx)
+		},
+	})
+
+	return repositories.NewMongo(db), nil
 }
@@ -0,0 +1,28 @@
+package actor
+
+import "context"
+
+// Anonymous is reported for requests that did not identify who made them.
+const Anonymous = "anonymous"
+
+// System is reported for changes made by the service itself, such as the
+// periodic trash purge.
+const System = "system"
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries the name of the actor making
+// the request.
+func NewContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, contextKey{}, name)
+}
+
+// FromContext returns the actor carried by ctx, or Anonymous.
+func FromContext(ctx context.Context) string {
+	name, ok := ctx.Value(contextKey{}).(string)
+	if !ok || name == "" {
+		return Anonymous
+	}
+
+	return name
+}
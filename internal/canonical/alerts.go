@@ -0,0 +1,30 @@
+package canonical
+
+import "time"
+
+// LowStockAlert is raised when the stock of a product drops below its reorder
+// threshold. A product has at most one open alert, which is closed once the
+// product is restocked, so crossing the threshold notifies only once.
+// NotifiedAt is set once the alert has been sent; until then it is retried.
+type LowStockAlert struct {
+	ProductId  string     `bson:"_id"`
+	Name       string     `bson:"name"`
+	Category   string     `bson:"category"`
+	Stock      int        `bson:"stock"`
+	Threshold  int        `bson:"threshold"`
+	CreatedAt  time.Time  `bson:"created_at"`
+	NotifiedAt *time.Time `bson:"notified_at,omitempty"`
+}
+
+type LowStockAlertPage struct {
+	Alerts     []LowStockAlert
+	NextCursor string
+	HasMore    bool
+}
+
+// CategoryThreshold is the reorder threshold of the products of a category
+// that have none of their own.
+type CategoryThreshold struct {
+	Category  string `bson:"_id"`
+	Threshold int    `bson:"threshold"`
+}
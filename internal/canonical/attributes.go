@@ -0,0 +1,115 @@
+package canonical
+
+import "regexp"
+
+// Types of product attributes.
+const (
+	AttributeString = "string"
+	AttributeNumber = "number"
+	AttributeEnum   = "enum"
+	AttributeBool   = "bool"
+)
+
+var AttributeTypes = []string{AttributeString, AttributeNumber, AttributeEnum, AttributeBool}
+
+// attributeName is what attribute names must look like, so that they can be
+// used as keys of a document and in query parameters as they are.
+var attributeName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+// ValidAttributeName reports whether name can name an attribute: up to 40
+// lower case letters, digits and underscores, starting with a letter.
+func ValidAttributeName(name string) bool {
+	return attributeName.MatchString(name)
+}
+
+// AttributeDefinition is an entry of the attribute schema of a category. Enum
+// attributes take one of Values; other types have none.
+type AttributeDefinition struct {
+	Name     string   `bson:"name"`
+	Type     string   `bson:"type"`
+	Required bool     `bson:"required,omitempty"`
+	Values   []string `bson:"values,omitempty"`
+}
+
+// AttributeFilter matches products whose attribute Name equals any of Values.
+type AttributeFilter struct {
+	Name   string
+	Values []any
+}
+
+// AttributeNumberValue returns value as a float64 if it is a number. Numbers
+// come as float64 from JSON but may be stored as integers.
+func AttributeNumberValue(value any) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case float32:
+		return float64(number), true
+	case int:
+		return float64(number), true
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	default:
+		return 0, false
+	}
+}
+
+// AttributeEquals compares attribute values, numbers by value whatever their
+// type.
+func AttributeEquals(a, b any) bool {
+	x, ok := AttributeNumberValue(a)
+	if !ok {
+		return a == b
+	}
+
+	y, ok := AttributeNumberValue(b)
+
+	return ok && x == y
+}
+
+// EqualAttributes reports whether a and b hold the same attributes.
+func EqualAttributes(a, b map[string]any) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for name, value := range a {
+		other, ok := b[name]
+		if !ok || !AttributeEquals(value, other) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MergeAttributes returns attributes with members merged in the way of an RFC
+// 7396 merge patch: a nil value removes that attribute and nil members remove
+// them all. attributes itself is left untouched.
+func MergeAttributes(attributes, members map[string]any) map[string]any {
+	if members == nil {
+		return nil
+	}
+
+	merged := make(map[string]any, len(attributes)+len(members))
+	for name, value := range attributes {
+		merged[name] = value
+	}
+
+	for name, value := range members {
+		if value == nil {
+			delete(merged, name)
+			continue
+		}
+
+		merged[name] = value
+	}
+
+	if len(merged) == 0 {
+		return nil
+	}
+
+	return merged
+}
@@ -1,12 +1,260 @@
 package canonical
 
-import "time"
+import (
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/money"
+)
 
 type Product struct {
-	Id        string    `bson:"_id"`
-	Name      string    `bson:"name"`
-	Category  string    `bson:"category"`
-	Price     float32   `bson:"price"`
-	Stock     int       `bson:"stock"`
-	CreatedAt time.Time `bson:"created_at"`
+	Id        string      `bson:"_id"`
+	Name      string      `bson:"name" validate:"required,max=120"`
+	Category  string      `bson:"category" validate:"required,max=60"`
+	Price     money.Money `bson:"price" validate:"required,min=0"`
+	Stock     int         `bson:"stock"`
+	CreatedAt time.Time   `bson:"created_at"`
+	Version   int64       `bson:"version"`
+
+	// Status is where the product is in its lifecycle. It only changes
+	// through the transitions CanTransition allows.
+	Status string `bson:"status"`
+
+	// PriceOverrides are prices in other currencies that take precedence over
+	// converting Price. There is at most one per currency.
+	PriceOverrides []money.Money `bson:"price_overrides,omitempty"`
+
+	// StockLevels holds the stock of the product per warehouse; Stock is kept
+	// equal to their sum. Backorders allows levels to go below zero.
+	StockLevels []StockLevel `bson:"stock_levels,omitempty"`
+	Backorders  bool         `bson:"backorders,omitempty"`
+
+	// Reserved is the part of Stock held by reservations that have not been
+	// confirmed, released or expired yet.
+	Reserved int `bson:"reserved,omitempty"`
+
+	// ReorderThreshold raises a low-stock alert when Stock drops below it.
+	// Zero falls back to the threshold of the category.
+	ReorderThreshold int `bson:"reorder_threshold,omitempty" validate:"min=0"`
+
+	// Attributes are checked against the attribute schema of the category:
+	// strings and enum values are strings, numbers float64 and bools bool.
+	Attributes map[string]any `bson:"attributes,omitempty"`
+
+	// PublishAt and UnpublishAt bound the window the product is visible in,
+	// from PublishAt on and until UnpublishAt. Either may be left open.
+	PublishAt   *time.Time `bson:"publish_at,omitempty"`
+	UnpublishAt *time.Time `bson:"unpublish_at,omitempty"`
+
+	// ParentId is set on variants, the products a parent is sold as in each
+	// size, color and so on. SKU identifies a variant and Attributes tell it
+	// apart from its siblings.
+	ParentId          string            `bson:"parent_id,omitempty"`
+	SKU               string            `bson:"sku,omitempty"`
+	VariantAttributes map[string]string `bson:"variant_attributes,omitempty"`
+
+	// InheritsPrice is set on variants without a price of their own, which
+	// are sold at the current price of their parent. Price then holds the
+	// price of the parent as of the last write; reads fill in the current
+	// one with InheritPrice.
+	InheritsPrice bool `bson:"inherits_price,omitempty"`
+
+	// Variants sums up the variants of a parent when it is read on its own.
+	// It is not stored.
+	Variants *VariantSummary `bson:"-"`
+
+	DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+	DeletedBy string     `bson:"deleted_by,omitempty"`
+}
+
+// Operations recorded in the audit log.
+const (
+	OperationCreate  = "create"
+	OperationUpdate  = "update"
+	OperationPatch   = "patch"
+	OperationDelete  = "delete"
+	OperationRestore = "restore"
+	OperationPurge   = "purge"
+
+	OperationTransition = "transition"
+
+	OperationAdjustStock        = "adjust_stock"
+	OperationConfirmReservation = "confirm_reservation"
+)
+
+// AuditEntry records a single mutation of a product. Entries are never
+// changed once written.
+type AuditEntry struct {
+	Id        string        `bson:"_id"`
+	ProductId string        `bson:"product_id"`
+	Operation string        `bson:"operation"`
+	Actor     string        `bson:"actor"`
+	Timestamp time.Time     `bson:"timestamp"`
+	Changes   []FieldChange `bson:"changes"`
+}
+
+type FieldChange struct {
+	Field  string `bson:"field"`
+	Before any    `bson:"before"`
+	After  any    `bson:"after"`
+}
+
+type AuditPage struct {
+	Entries    []AuditEntry
+	NextCursor string
+	HasMore    bool
+}
+
+// ProductPatch holds the fields of a partial update. Nil fields are left
+// untouched; a zero PublishAt or UnpublishAt opens that end of the window.
+// Attributes are merged into those of the product by MergeAttributes rather
+// than replacing them.
+type ProductPatch struct {
+	Name           *string
+	Category       *string
+	Price          *money.Money
+	Stock          *int
+	PriceOverrides *[]money.Money
+	StockLevels    *[]StockLevel
+	Backorders     *bool
+
+	ReorderThreshold *int
+	Attributes       *map[string]any
+	Status           *string
+	PublishAt        *time.Time
+	UnpublishAt      *time.Time
+}
+
+// Apply returns product with the fields set in patch replaced.
+func (patch ProductPatch) Apply(product Product) Product {
+	if patch.Name != nil {
+		product.Name = *patch.Name
+	}
+
+	if patch.Category != nil {
+		product.Category = *patch.Category
+	}
+
+	// A price set on a variant is its own from then on.
+	if patch.Price != nil {
+		product.Price = *patch.Price
+		product.InheritsPrice = false
+	}
+
+	if patch.PriceOverrides != nil {
+		product.PriceOverrides = *patch.PriceOverrides
+	}
+
+	if patch.Stock != nil {
+		product.Stock = *patch.Stock
+	}
+
+	if patch.StockLevels != nil {
+		product.StockLevels = *patch.StockLevels
+	}
+
+	if patch.Backorders != nil {
+		product.Backorders = *patch.Backorders
+	}
+
+	if patch.ReorderThreshold != nil {
+		product.ReorderThreshold = *patch.ReorderThreshold
+	}
+
+	if patch.Attributes != nil {
+		product.Attributes = MergeAttributes(product.Attributes, *patch.Attributes)
+	}
+
+	if patch.Status != nil {
+		product.Status = *patch.Status
+	}
+
+	if patch.PublishAt != nil {
+		product.PublishAt = OptionalTime(*patch.PublishAt)
+	}
+
+	if patch.UnpublishAt != nil {
+		product.UnpublishAt = OptionalTime(*patch.UnpublishAt)
+	}
+
+	return product
+}
+
+// PublishedAt reports whether at falls in the publication window of product.
+func (product Product) PublishedAt(at time.Time) bool {
+	return (product.PublishAt == nil || !product.PublishAt.After(at)) &&
+		(product.UnpublishAt == nil || product.UnpublishAt.After(at))
+}
+
+// OptionalTime returns nil for the zero time and a pointer to t otherwise.
+func OptionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+type SearchResult struct {
+	Product Product
+	Score   float64
+}
+
+type SearchPage struct {
+	Results    []SearchResult
+	NextCursor string
+	HasMore    bool
+}
+
+// Product fields listings can be sorted on.
+const (
+	FieldName      = "name"
+	FieldCategory  = "category"
+	FieldPrice     = "price"
+	FieldStock     = "stock"
+	FieldCreatedAt = "created_at"
+)
+
+var SortableFields = []string{FieldName, FieldCategory, FieldPrice, FieldStock, FieldCreatedAt}
+
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// ProductQuery narrows down and orders a product listing. Zero-valued criteria
+// are not applied. Categories matches products in any of the categories
+// listed; an empty, non-nil list matches none, and so does ParentIds for the
+// variants of the parents listed. Attributes matches products that pass every
+// filter and Statuses those in any of the statuses listed.
+type ProductQuery struct {
+	Category     string
+	Categories   []string
+	NamePrefix   string
+	PriceMin     *money.Money
+	PriceMax     *money.Money
+	StockLt      *int
+	CreatedAfter *time.Time
+	ParentId     string
+	ParentIds    []string
+	SKU          string
+	Attributes   []AttributeFilter
+	Statuses     []string
+	Sort         []SortField
+
+	// ParentsOnly leaves variants out, which are sold through their parent.
+	ParentsOnly bool
+
+	// Deleted lists the products in the trash instead of the live ones.
+	Deleted bool
+}
+
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+type ProductPage struct {
+	Products   []Product
+	NextCursor string
+	HasMore    bool
 }
@@ -0,0 +1,58 @@
+package canonical
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Category is a node of the category tree. Products refer to it by slug. Path
+// is the materialized path of the category, the slugs from the root down to
+// it, as in "/electronics/phones", so a subtree is found with one prefix
+// match. Attributes is the schema the attributes of the products of the
+// category are checked against; it is not inherited by subcategories.
+type Category struct {
+	Slug       string                `bson:"_id"`
+	Name       string                `bson:"name" validate:"required,max=60"`
+	Parent     string                `bson:"parent,omitempty"`
+	Path       string                `bson:"path"`
+	Attributes []AttributeDefinition `bson:"attributes,omitempty"`
+	CreatedAt  time.Time             `bson:"created_at"`
+	Version    int64                 `bson:"version"`
+}
+
+// CategoryPath returns the path of the category slug under parentPath, which
+// is empty for a root category.
+func CategoryPath(parentPath, slug string) string {
+	return parentPath + "/" + slug
+}
+
+// InSubtree reports whether path is root or one of its descendants.
+func InSubtree(path, root string) bool {
+	return path == root || strings.HasPrefix(path, root+"/")
+}
+
+// Slugify turns name into a slug: letters and digits in lower case, with every
+// run of anything else replaced by a single hyphen. "Home & Garden" becomes
+// "home-garden".
+func Slugify(name string) string {
+	var builder strings.Builder
+
+	hyphen := false
+	for _, r := range strings.TrimSpace(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && builder.Len() > 0 {
+				builder.WriteByte('-')
+			}
+
+			builder.WriteRune(unicode.ToLower(r))
+			hyphen = false
+
+			continue
+		}
+
+		hyphen = true
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,67 @@
+package canonical
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/money"
+)
+
+// Statuses of a PriceChange.
+const (
+	PriceScheduled = "scheduled"
+	PriceApplied   = "applied"
+	PriceSkipped   = "skipped"
+)
+
+// PriceChange is an entry of the price timeline of a product: the price it
+// takes from EffectiveAt on. Changes made by writing the product are recorded
+// as applied right away; scheduled changes are applied by the price scheduler
+// once they are due.
+type PriceChange struct {
+	Id          string      `bson:"_id"`
+	ProductId   string      `bson:"product_id"`
+	Price       money.Money `bson:"price" validate:"required,min=0"`
+	EffectiveAt time.Time   `bson:"effective_at"`
+	Status      string      `bson:"status"`
+	CreatedAt   time.Time   `bson:"created_at"`
+	CreatedBy   string      `bson:"created_by"`
+	AppliedAt   *time.Time  `bson:"applied_at,omitempty"`
+}
+
+// PriceAt is the price a product had, or is scheduled to have, at a point in
+// time, along with the change it comes from. Projected prices come from a
+// change that has not been applied yet. The change has no Id when the price
+// is the current one of a product without a recorded timeline.
+type PriceAt struct {
+	ProductId string
+	At        time.Time
+	Change    PriceChange
+	Projected bool
+}
+
+// ExchangeRate is the price of one unit of From in To, as published by Source
+// at AsOf.
+type ExchangeRate struct {
+	From   money.Currency
+	To     money.Currency
+	Rate   *big.Rat
+	AsOf   time.Time
+	Source string
+}
+
+// Where the price of a LocalizedProduct comes from.
+const (
+	PriceSourceBase      = "base"
+	PriceSourceOverride  = "override"
+	PriceSourceConverted = "converted"
+)
+
+// LocalizedProduct is a product along with its price in the currency a client
+// asked for. Rate is only set for converted prices.
+type LocalizedProduct struct {
+	Product     Product
+	Price       money.Money
+	PriceSource string
+	Rate        *ExchangeRate
+}
@@ -0,0 +1,28 @@
+package canonical
+
+import "slices"
+
+// Lifecycle statuses of a product. Products are created as drafts or active,
+// only active products are sold, and archived ones are kept for the record.
+const (
+	StatusDraft        = "draft"
+	StatusActive       = "active"
+	StatusDiscontinued = "discontinued"
+	StatusArchived     = "archived"
+)
+
+var Statuses = []string{StatusDraft, StatusActive, StatusDiscontinued, StatusArchived}
+
+// transitions lists the statuses a product can move to from each status. A
+// discontinued product can be brought back; an archived one is final.
+var transitions = map[string][]string{
+	StatusDraft:        {StatusActive, StatusArchived},
+	StatusActive:       {StatusDiscontinued},
+	StatusDiscontinued: {StatusActive, StatusArchived},
+}
+
+// CanTransition reports whether a product can move from one status to the
+// other.
+func CanTransition(from, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
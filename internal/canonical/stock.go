@@ -0,0 +1,118 @@
+package canonical
+
+import "time"
+
+// StockLevel is the quantity of a product on hand at one warehouse. It is
+// negative when more has been sold than there is, which only products that
+// allow backorders can get to.
+type StockLevel struct {
+	Warehouse string `bson:"warehouse"`
+	Quantity  int    `bson:"quantity"`
+}
+
+// Stock is the stock of a product across every warehouse.
+type Stock struct {
+	ProductId  string
+	Levels     []StockLevel
+	Available  int
+	Reserved   int
+	Backorders bool
+	Version    int64
+}
+
+// Available returns how much of product can still be sold: the stock on hand
+// less what is held by reservations.
+func (product Product) Available() int {
+	return product.Stock - product.Reserved
+}
+
+// StockAt returns the quantity of levels at warehouse.
+func StockAt(levels []StockLevel, warehouse string) int {
+	for _, level := range levels {
+		if level.Warehouse == warehouse {
+			return level.Quantity
+		}
+	}
+
+	return 0
+}
+
+// TotalStock adds up the quantities of levels.
+func TotalStock(levels []StockLevel) int {
+	total := 0
+	for _, level := range levels {
+		total += level.Quantity
+	}
+
+	return total
+}
+
+// Reservation statuses. A reservation is held until it is confirmed, released
+// or it expires.
+const (
+	ReservationHeld      = "held"
+	ReservationConfirmed = "confirmed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation holds stock of a product for a checkout until ExpiresAt.
+type Reservation struct {
+	Id          string     `bson:"_id"`
+	ProductId   string     `bson:"product_id"`
+	Quantity    int        `bson:"quantity" validate:"min=1"`
+	Status      string     `bson:"status"`
+	ExpiresAt   time.Time  `bson:"expires_at"`
+	CreatedAt   time.Time  `bson:"created_at"`
+	CreatedBy   string     `bson:"created_by"`
+	CompletedAt *time.Time `bson:"completed_at,omitempty"`
+}
+
+// Types of stock movements. Reservation movements record stock being held and
+// given back; they count towards Reserved rather than Stock.
+const (
+	MovementReceipt     = "receipt"
+	MovementSale        = "sale"
+	MovementAdjustment  = "adjustment"
+	MovementReturn      = "return"
+	MovementReservation = "reservation"
+)
+
+// StockMovement records a single change to the stock of a product at a
+// warehouse. Movements are never changed once written, so the stock of a
+// product is the sum of its movements. Reservation movements have no
+// warehouse.
+type StockMovement struct {
+	Id        string    `bson:"_id"`
+	ProductId string    `bson:"product_id"`
+	Warehouse string    `bson:"warehouse,omitempty"`
+	Type      string    `bson:"type"`
+	Quantity  int       `bson:"quantity"`
+	Reference string    `bson:"reference,omitempty"`
+	Actor     string    `bson:"actor"`
+	Timestamp time.Time `bson:"timestamp"`
+}
+
+type StockMovementPage struct {
+	Movements  []StockMovement
+	NextCursor string
+	HasMore    bool
+}
+
+// StockBalance sums up the movements of a product.
+type StockBalance struct {
+	ProductId string
+	Levels    []StockLevel
+	Reserved  int
+}
+
+// StockDiscrepancy reports a product whose stored stock does not match its
+// movements. Field is either "stock", for the stock at Warehouse or in total
+// if Warehouse is empty, or "reserved".
+type StockDiscrepancy struct {
+	ProductId string
+	Field     string
+	Warehouse string
+	Stored    int
+	Ledger    int
+}
@@ -0,0 +1,77 @@
+package canonical
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/nelsonalves117/go-products-api/internal/money"
+)
+
+// VariantSummary adds up the variants of a parent: their stock and the range
+// their prices fall in. Variants are priced in the currency of their parent.
+type VariantSummary struct {
+	Count     int
+	Stock     int
+	Available int
+	PriceMin  money.Money
+	PriceMax  money.Money
+}
+
+// SummarizeVariants returns the summary of the variants of parent, or nil if
+// there are none. Variants without a price of their own count at the current
+// price of parent.
+func SummarizeVariants(parent Product, variants []Product) *VariantSummary {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	first := InheritPrice(variants[0], parent)
+
+	summary := &VariantSummary{PriceMin: first.Price, PriceMax: first.Price}
+	for _, variant := range variants {
+		variant = InheritPrice(variant, parent)
+
+		summary.Count++
+		summary.Stock += variant.Stock
+		summary.Available += variant.Available()
+
+		if variant.Price.Amount < summary.PriceMin.Amount {
+			summary.PriceMin = variant.Price
+		}
+
+		if variant.Price.Amount > summary.PriceMax.Amount {
+			summary.PriceMax = variant.Price
+		}
+	}
+
+	return summary
+}
+
+// InheritPrice returns variant at the current price of parent if it has no
+// price of its own.
+func InheritPrice(variant, parent Product) Product {
+	if variant.InheritsPrice {
+		variant.Price = parent.Price
+	}
+
+	return variant
+}
+
+// VariantName returns the name of the variant of parent with attributes, the
+// name of the parent followed by the attribute values in key order, as in
+// "T-shirt (red, M)".
+func VariantName(parent string, attributes map[string]string) string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, attributes[key])
+	}
+
+	return parent + " (" + strings.Join(values, ", ") + ")"
+}
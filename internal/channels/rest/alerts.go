@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetLowStockAlerts lists the products whose stock is below their reorder
+// threshold.
+func (rest *rest) GetLowStockAlerts(c echo.Context) error {
+	page, err := pageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	alertPage, err := rest.service.GetLowStockAlerts(c.Request().Context(), page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toLowStockAlertPageResponse(alertPage))
+}
+
+// SetCategoryThreshold sets the reorder threshold of the products of a
+// category that have none of their own. A threshold of zero removes it.
+func (rest *rest) SetCategoryThreshold(c echo.Context) error {
+	var request categoryThresholdRequest
+
+	err := c.Bind(&request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	err = c.Validate(&request)
+	if err != nil {
+		return err
+	}
+
+	threshold, err := rest.service.SetCategoryThreshold(c.Request().Context(), c.Param("category"), request.Threshold)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, categoryThresholdResponse{Category: threshold.Category, Threshold: threshold.Threshold})
+}
@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+)
+
+func (rest *rest) GetCategories(c echo.Context) error {
+	categories, err := rest.service.GetCategories(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toCategoryListResponse(categories))
+}
+
+func (rest *rest) GetCategory(c echo.Context) error {
+	category, err := rest.service.GetCategory(c.Request().Context(), c.Param("category"))
+	if err != nil {
+		return err
+	}
+
+	setETag(c, category.Version)
+
+	return c.JSON(http.StatusOK, toCategoryResponse(category))
+}
+
+func (rest *rest) CreateCategory(c echo.Context) error {
+	var request categoryRequest
+
+	err := c.Bind(&request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	err = c.Validate(&request)
+	if err != nil {
+		return err
+	}
+
+	category, err := rest.service.CreateCategory(c.Request().Context(), canonical.Category{
+		Slug:       request.Slug,
+		Name:       request.Name,
+		Parent:     request.Parent,
+		Attributes: toAttributeDefinitions(request.Attributes),
+	})
+	if err != nil {
+		return err
+	}
+
+	setETag(c, category.Version)
+
+	return c.JSON(http.StatusCreated, toCategoryResponse(category))
+}
+
+// UpdateCategory renames a category, replaces its attribute schema or moves
+// it under another parent. The slug in the body, if any, must be the one of
+// the category.
+func (rest *rest) UpdateCategory(c echo.Context) error {
+	var request categoryRequest
+
+	err := c.Bind(&request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	err = c.Validate(&request)
+	if err != nil {
+		return err
+	}
+
+	if request.Slug != "" && request.Slug != c.Param("category") {
+		return errs.InvalidFields(errs.FieldError{Field: "slug", Message: "cannot be changed"})
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	category, err := rest.service.UpdateCategory(c.Request().Context(), c.Param("category"), version, canonical.Category{
+		Name:       request.Name,
+		Parent:     request.Parent,
+		Attributes: toAttributeDefinitions(request.Attributes),
+	})
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	setETag(c, category.Version)
+
+	return c.JSON(http.StatusOK, toCategoryResponse(category))
+}
+
+// DeleteCategory removes a category that has neither subcategories nor
+// products.
+func (rest *rest) DeleteCategory(c echo.Context) error {
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	err = rest.service.DeleteCategory(c.Request().Context(), c.Param("category"), version)
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	return c.JSON(http.StatusOK, nil)
+}
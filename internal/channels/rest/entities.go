@@ -1,19 +1,297 @@
 package rest
 
-import "time"
+import (
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/money"
+)
 
 type productRequest struct {
-	Name     string  `json:"name"`
-	Category string  `json:"category"`
-	Price    float32 `json:"price"`
-	Stock    int     `json:"stock"`
+	Name     string      `json:"name" validate:"required,max=120"`
+	Category string      `json:"category" validate:"required,max=60"`
+	Price    money.Money `json:"price" validate:"required,min=0"`
+	Stock    int         `json:"stock"`
+
+	PriceOverrides []money.Money `json:"price_overrides"`
+	Backorders     bool          `json:"backorders"`
+
+	ReorderThreshold int `json:"reorder_threshold" validate:"min=0"`
+
+	Attributes map[string]any `json:"attributes"`
+
+	// Status can be draft or active on create. Updates may only repeat the
+	// current status; it is changed through transitions.
+	Status string `json:"status"`
+
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+type transitionRequest struct {
+	Status string `json:"status" validate:"required"`
 }
 
 type productResponse struct {
-	Id        string    `json:"_id"`
-	Name      string    `json:"name"`
-	Category  string    `json:"category"`
-	Price     float32   `json:"price"`
-	Stock     int       `json:"stock"`
-	CreatedAt time.Time `json:"created_at"`
+	Id        string      `json:"_id"`
+	Name      string      `json:"name"`
+	Category  string      `json:"category"`
+	Price     money.Money `json:"price"`
+	Stock     int         `json:"stock"`
+	CreatedAt time.Time   `json:"created_at"`
+	Version   int64       `json:"version"`
+	Status    string      `json:"status"`
+
+	PriceOverrides []money.Money `json:"price_overrides,omitempty"`
+
+	Available   int                  `json:"available"`
+	Reserved    int                  `json:"reserved"`
+	StockLevels []stockLevelResponse `json:"stock_levels,omitempty"`
+	Backorders  bool                 `json:"backorders"`
+
+	ReorderThreshold int `json:"reorder_threshold,omitempty"`
+
+	Attributes map[string]any `json:"attributes,omitempty"`
+
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty"`
+
+	ParentId          string                  `json:"parent_id,omitempty"`
+	SKU               string                  `json:"sku,omitempty"`
+	VariantAttributes map[string]string       `json:"variant_attributes,omitempty"`
+	Variants          *variantSummaryResponse `json:"variants,omitempty"`
+
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy string     `json:"deleted_by,omitempty"`
+}
+
+type localizedProductResponse struct {
+	productResponse
+	LocalPrice localPriceResponse `json:"local_price"`
+}
+
+type localPriceResponse struct {
+	Price        money.Money           `json:"price"`
+	Source       string                `json:"source"`
+	ExchangeRate *exchangeRateResponse `json:"exchange_rate,omitempty"`
+}
+
+type exchangeRateResponse struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Rate   string    `json:"rate"`
+	AsOf   time.Time `json:"as_of"`
+	Source string    `json:"source"`
+}
+
+type productPageResponse struct {
+	Products   []productResponse `json:"products"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	HasMore    bool              `json:"has_more"`
+}
+
+type searchResultResponse struct {
+	productResponse
+	Score float64 `json:"score"`
+}
+
+type searchPageResponse struct {
+	Results    []searchResultResponse `json:"results"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+	HasMore    bool                   `json:"has_more"`
+}
+
+type auditEntryResponse struct {
+	Id        string                `json:"id"`
+	ProductId string                `json:"product_id"`
+	Operation string                `json:"operation"`
+	Actor     string                `json:"actor"`
+	Timestamp time.Time             `json:"timestamp"`
+	Changes   []fieldChangeResponse `json:"changes"`
+}
+
+type fieldChangeResponse struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+type auditPageResponse struct {
+	Entries    []auditEntryResponse `json:"entries"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+	HasMore    bool                 `json:"has_more"`
+}
+
+type priceChangeRequest struct {
+	Price       money.Money `json:"price" validate:"required,min=0"`
+	EffectiveAt time.Time   `json:"effective_at" validate:"required"`
+}
+
+type priceChangeResponse struct {
+	Id          string      `json:"id"`
+	ProductId   string      `json:"product_id"`
+	Price       money.Money `json:"price"`
+	EffectiveAt time.Time   `json:"effective_at"`
+	Status      string      `json:"status"`
+	CreatedAt   time.Time   `json:"created_at"`
+	CreatedBy   string      `json:"created_by"`
+	AppliedAt   *time.Time  `json:"applied_at,omitempty"`
+}
+
+type priceHistoryResponse struct {
+	Prices []priceChangeResponse `json:"prices"`
+}
+
+type priceAtResponse struct {
+	ProductId   string      `json:"product_id"`
+	At          time.Time   `json:"at"`
+	Price       money.Money `json:"price"`
+	EffectiveAt time.Time   `json:"effective_at"`
+	Status      string      `json:"status"`
+	ChangeId    string      `json:"change_id,omitempty"`
+	Projected   bool        `json:"projected"`
+}
+
+type stockLevelResponse struct {
+	Warehouse string `json:"warehouse"`
+	Quantity  int    `json:"quantity"`
+}
+
+type stockResponse struct {
+	ProductId  string               `json:"product_id"`
+	Available  int                  `json:"available"`
+	Reserved   int                  `json:"reserved"`
+	Backorders bool                 `json:"backorders"`
+	Levels     []stockLevelResponse `json:"levels"`
+}
+
+type stockAdjustmentRequest struct {
+	Type      string `json:"type"`
+	Quantity  int    `json:"quantity" validate:"required"`
+	Reference string `json:"reference" validate:"max=120"`
+}
+
+type stockMovementResponse struct {
+	Id        string    `json:"id"`
+	ProductId string    `json:"product_id"`
+	Warehouse string    `json:"warehouse,omitempty"`
+	Type      string    `json:"type"`
+	Quantity  int       `json:"quantity"`
+	Reference string    `json:"reference,omitempty"`
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type stockMovementPageResponse struct {
+	Movements  []stockMovementResponse `json:"movements"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+	HasMore    bool                    `json:"has_more"`
+}
+
+type reservationRequest struct {
+	Quantity   int `json:"quantity" validate:"min=1"`
+	TTLSeconds int `json:"ttl_seconds" validate:"min=0"`
+}
+
+type reservationResponse struct {
+	Id          string     `json:"id"`
+	ProductId   string     `json:"product_id"`
+	Quantity    int        `json:"quantity"`
+	Status      string     `json:"status"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CreatedBy   string     `json:"created_by"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+type lowStockAlertResponse struct {
+	ProductId  string     `json:"product_id"`
+	Name       string     `json:"name"`
+	Category   string     `json:"category"`
+	Stock      int        `json:"stock"`
+	Threshold  int        `json:"threshold"`
+	CreatedAt  time.Time  `json:"created_at"`
+	NotifiedAt *time.Time `json:"notified_at,omitempty"`
+}
+
+type lowStockAlertPageResponse struct {
+	Alerts     []lowStockAlertResponse `json:"alerts"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+	HasMore    bool                    `json:"has_more"`
+}
+
+type categoryThresholdRequest struct {
+	Threshold int `json:"threshold" validate:"min=0"`
+}
+
+type categoryThresholdResponse struct {
+	Category  string `json:"category"`
+	Threshold int    `json:"threshold"`
+}
+
+type categoryRequest struct {
+	Slug       string                       `json:"slug"`
+	Name       string                       `json:"name" validate:"required,max=60"`
+	Parent     string                       `json:"parent"`
+	Attributes []attributeDefinitionRequest `json:"attributes"`
+}
+
+type attributeDefinitionRequest struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Values   []string `json:"values"`
+}
+
+type categoryResponse struct {
+	Slug       string                        `json:"slug"`
+	Name       string                        `json:"name"`
+	Parent     string                        `json:"parent,omitempty"`
+	Path       string                        `json:"path"`
+	Attributes []attributeDefinitionResponse `json:"attributes"`
+	CreatedAt  time.Time                     `json:"created_at"`
+	Version    int64                         `json:"version"`
+}
+
+type attributeDefinitionResponse struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Values   []string `json:"values,omitempty"`
+}
+
+type categoryListResponse struct {
+	Categories []categoryResponse `json:"categories"`
+}
+
+// variantRequest creates a variant. Without a price, the variant is sold at
+// the price of its parent and follows it when the parent is repriced.
+type variantRequest struct {
+	SKU               string            `json:"sku" validate:"required,max=64"`
+	VariantAttributes map[string]string `json:"variant_attributes" validate:"required,min=1"`
+	Price             money.Money       `json:"price" validate:"min=0"`
+	Stock             int               `json:"stock"`
+	Backorders        bool              `json:"backorders"`
+
+	ReorderThreshold int `json:"reorder_threshold" validate:"min=0"`
+}
+
+type variantSummaryResponse struct {
+	Count      int                `json:"count"`
+	Stock      int                `json:"stock"`
+	Available  int                `json:"available"`
+	PriceRange priceRangeResponse `json:"price_range"`
+}
+
+type priceRangeResponse struct {
+	Min money.Money `json:"min"`
+	Max money.Money `json:"max"`
+}
+
+type variantListResponse struct {
+	Variants []productResponse `json:"variants"`
+}
+
+type fieldErrorResponse struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
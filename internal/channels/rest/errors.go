@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details object.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	Errors []fieldErrorResponse `json:"errors,omitempty"`
+}
+
+func (rest *rest) errorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	p := toProblem(err)
+	p.Instance = c.Request().URL.Path
+
+	if p.Status >= http.StatusInternalServerError {
+		rest.logger.WithError(err).Error("unexpected error occurred while handling request")
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(p.Status)
+	} else {
+		var body []byte
+		body, err = json.Marshal(p)
+		if err == nil {
+			err = c.Blob(p.Status, problemContentType, body)
+		}
+	}
+
+	if err != nil {
+		rest.logger.WithError(err).Error("error occurred while trying to write the error response")
+	}
+}
+
+func toProblem(err error) problem {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		p := newProblem(httpErr.Code)
+		if message, ok := httpErr.Message.(string); ok {
+			p.Detail = message
+		}
+
+		return p
+	}
+
+	switch errs.KindOf(err) {
+	case errs.KindNotFound:
+		return newProblemWithDetail(http.StatusNotFound, errs.Detail(err))
+	case errs.KindConflict, errs.KindVersionConflict:
+		return newProblemWithDetail(http.StatusConflict, errs.Detail(err))
+	case errs.KindBadRequest:
+		return newProblemWithDetail(http.StatusBadRequest, errs.Detail(err))
+	case errs.KindValidation:
+		p := newProblemWithDetail(http.StatusUnprocessableEntity, errs.Detail(err))
+		for _, field := range errs.Fields(err) {
+			p.Errors = append(p.Errors, fieldErrorResponse{Field: field.Field, Message: field.Message})
+		}
+
+		return p
+	default:
+		return newProblemWithDetail(http.StatusInternalServerError, "unexpected error occurred")
+	}
+}
+
+func newProblem(status int) problem {
+	return problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+}
+
+func newProblemWithDetail(status int, detail string) problem {
+	p := newProblem(status)
+	p.Detail = detail
+
+	return p
+}
@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+)
+
+// Product versions are exposed as strong entity tags so that clients can make
+// their writes conditional with If-Match.
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func setETag(c echo.Context, version int64) {
+	c.Response().Header().Set("ETag", formatETag(version))
+}
+
+// ifMatchVersion reads the product version a write is conditioned on.
+func ifMatchVersion(c echo.Context) (int64, error) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if header == "" {
+		return 0, echo.NewHTTPError(http.StatusPreconditionRequired, "the If-Match header is required")
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if err != nil || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || version < 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "the If-Match header must hold a single strong entity tag")
+	}
+
+	return version, nil
+}
+
+// preconditionError reports a conditional write that lost against a newer
+// version of the product as 412 Precondition Failed.
+func preconditionError(err error) error {
+	if errs.KindOf(err) == errs.KindVersionConflict {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, errs.Detail(err))
+	}
+
+	return err
+}
@@ -1,7 +1,13 @@
 package rest
 
 import (
+	"encoding/json"
+	"sort"
+	"time"
+
 	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/money"
 )
 
 func toCanonical(product productRequest) canonical.Product {
@@ -10,6 +16,17 @@ func toCanonical(product productRequest) canonical.Product {
 		Category: product.Category,
 		Price:    product.Price,
 		Stock:    product.Stock,
+
+		PriceOverrides: product.PriceOverrides,
+		Backorders:     product.Backorders,
+
+		ReorderThreshold: product.ReorderThreshold,
+		Attributes:       product.Attributes,
+
+		Status: product.Status,
+
+		PublishAt:   product.PublishAt,
+		UnpublishAt: product.UnpublishAt,
 	}
 }
 
@@ -21,5 +38,370 @@ func toResponse(product canonical.Product) productResponse {
 		Price:     product.Price,
 		Stock:     product.Stock,
 		CreatedAt: product.CreatedAt,
+		Version:   product.Version,
+		Status:    product.Status,
+
+		PriceOverrides: product.PriceOverrides,
+
+		Available:   product.Available(),
+		Reserved:    product.Reserved,
+		StockLevels: toStockLevelResponses(product.StockLevels),
+		Backorders:  product.Backorders,
+
+		ReorderThreshold: product.ReorderThreshold,
+		Attributes:       product.Attributes,
+
+		PublishAt:   product.PublishAt,
+		UnpublishAt: product.UnpublishAt,
+
+		ParentId:          product.ParentId,
+		SKU:               product.SKU,
+		VariantAttributes: product.VariantAttributes,
+		Variants:          toVariantSummaryResponse(product.Variants),
+
+		DeletedAt: product.DeletedAt,
+		DeletedBy: product.DeletedBy,
+	}
+}
+
+func toVariantSummaryResponse(summary *canonical.VariantSummary) *variantSummaryResponse {
+	if summary == nil {
+		return nil
+	}
+
+	return &variantSummaryResponse{
+		Count:      summary.Count,
+		Stock:      summary.Stock,
+		Available:  summary.Available,
+		PriceRange: priceRangeResponse{Min: summary.PriceMin, Max: summary.PriceMax},
+	}
+}
+
+func toVariantListResponse(variants []canonical.Product) variantListResponse {
+	responses := make([]productResponse, 0, len(variants))
+	for _, variant := range variants {
+		responses = append(responses, toResponse(variant))
+	}
+
+	return variantListResponse{Variants: responses}
+}
+
+func toStockLevelResponses(levels []canonical.StockLevel) []stockLevelResponse {
+	if len(levels) == 0 {
+		return nil
+	}
+
+	responses := make([]stockLevelResponse, 0, len(levels))
+	for _, level := range levels {
+		responses = append(responses, stockLevelResponse{Warehouse: level.Warehouse, Quantity: level.Quantity})
+	}
+
+	return responses
+}
+
+func toStockResponse(stock canonical.Stock) stockResponse {
+	levels := toStockLevelResponses(stock.Levels)
+	if levels == nil {
+		levels = []stockLevelResponse{}
+	}
+
+	return stockResponse{
+		ProductId:  stock.ProductId,
+		Available:  stock.Available,
+		Reserved:   stock.Reserved,
+		Backorders: stock.Backorders,
+		Levels:     levels,
+	}
+}
+
+func toStockMovementPageResponse(page canonical.StockMovementPage) stockMovementPageResponse {
+	movements := make([]stockMovementResponse, 0, len(page.Movements))
+	for _, movement := range page.Movements {
+		movements = append(movements, stockMovementResponse{
+			Id:        movement.Id,
+			ProductId: movement.ProductId,
+			Warehouse: movement.Warehouse,
+			Type:      movement.Type,
+			Quantity:  movement.Quantity,
+			Reference: movement.Reference,
+			Actor:     movement.Actor,
+			Timestamp: movement.Timestamp,
+		})
+	}
+
+	return stockMovementPageResponse{
+		Movements:  movements,
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}
+}
+
+func toLowStockAlertPageResponse(page canonical.LowStockAlertPage) lowStockAlertPageResponse {
+	alerts := make([]lowStockAlertResponse, 0, len(page.Alerts))
+	for _, alert := range page.Alerts {
+		alerts = append(alerts, lowStockAlertResponse{
+			ProductId:  alert.ProductId,
+			Name:       alert.Name,
+			Category:   alert.Category,
+			Stock:      alert.Stock,
+			Threshold:  alert.Threshold,
+			CreatedAt:  alert.CreatedAt,
+			NotifiedAt: alert.NotifiedAt,
+		})
+	}
+
+	return lowStockAlertPageResponse{
+		Alerts:     alerts,
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}
+}
+
+func toCategoryResponse(category canonical.Category) categoryResponse {
+	return categoryResponse{
+		Slug:       category.Slug,
+		Name:       category.Name,
+		Parent:     category.Parent,
+		Path:       category.Path,
+		Attributes: toAttributeDefinitionResponses(category.Attributes),
+		CreatedAt:  category.CreatedAt,
+		Version:    category.Version,
+	}
+}
+
+func toAttributeDefinitions(requests []attributeDefinitionRequest) []canonical.AttributeDefinition {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	definitions := make([]canonical.AttributeDefinition, 0, len(requests))
+	for _, request := range requests {
+		definitions = append(definitions, canonical.AttributeDefinition{
+			Name:     request.Name,
+			Type:     request.Type,
+			Required: request.Required,
+			Values:   request.Values,
+		})
+	}
+
+	return definitions
+}
+
+func toAttributeDefinitionResponses(definitions []canonical.AttributeDefinition) []attributeDefinitionResponse {
+	responses := make([]attributeDefinitionResponse, 0, len(definitions))
+	for _, definition := range definitions {
+		responses = append(responses, attributeDefinitionResponse{
+			Name:     definition.Name,
+			Type:     definition.Type,
+			Required: definition.Required,
+			Values:   definition.Values,
+		})
+	}
+
+	return responses
+}
+
+func toCategoryListResponse(categories []canonical.Category) categoryListResponse {
+	responses := make([]categoryResponse, 0, len(categories))
+	for _, category := range categories {
+		responses = append(responses, toCategoryResponse(category))
+	}
+
+	return categoryListResponse{Categories: responses}
+}
+
+func toReservationResponse(reservation canonical.Reservation) reservationResponse {
+	return reservationResponse{
+		Id:          reservation.Id,
+		ProductId:   reservation.ProductId,
+		Quantity:    reservation.Quantity,
+		Status:      reservation.Status,
+		ExpiresAt:   reservation.ExpiresAt,
+		CreatedAt:   reservation.CreatedAt,
+		CreatedBy:   reservation.CreatedBy,
+		CompletedAt: reservation.CompletedAt,
+	}
+}
+
+// rateDigits is the number of decimal places exchange rates are shown with.
+const rateDigits = 6
+
+func toLocalizedResponse(localized canonical.LocalizedProduct) localizedProductResponse {
+	response := localizedProductResponse{
+		productResponse: toResponse(localized.Product),
+		LocalPrice: localPriceResponse{
+			Price:  localized.Price,
+			Source: localized.PriceSource,
+		},
+	}
+
+	if rate := localized.Rate; rate != nil {
+		response.LocalPrice.ExchangeRate = &exchangeRateResponse{
+			From:   rate.From.String(),
+			To:     rate.To.String(),
+			Rate:   rate.Rate.FloatString(rateDigits),
+			AsOf:   rate.AsOf,
+			Source: rate.Source,
+		}
+	}
+
+	return response
+}
+
+func toPageResponse(page canonical.ProductPage) productPageResponse {
+	products := make([]productResponse, 0, len(page.Products))
+	for _, product := range page.Products {
+		products = append(products, toResponse(product))
+	}
+
+	return productPageResponse{
+		Products:   products,
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}
+}
+
+func toSearchPageResponse(page canonical.SearchPage) searchPageResponse {
+	results := make([]searchResultResponse, 0, len(page.Results))
+	for _, result := range page.Results {
+		results = append(results, searchResultResponse{
+			productResponse: toResponse(result.Product),
+			Score:           result.Score,
+		})
 	}
+
+	return searchPageResponse{
+		Results:    results,
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}
+}
+
+func toAuditPageResponse(page canonical.AuditPage) auditPageResponse {
+	entries := make([]auditEntryResponse, 0, len(page.Entries))
+	for _, entry := range page.Entries {
+		changes := make([]fieldChangeResponse, 0, len(entry.Changes))
+		for _, change := range entry.Changes {
+			changes = append(changes, fieldChangeResponse{
+				Field:  change.Field,
+				Before: change.Before,
+				After:  change.After,
+			})
+		}
+
+		entries = append(entries, auditEntryResponse{
+			Id:        entry.Id,
+			ProductId: entry.ProductId,
+			Operation: entry.Operation,
+			Actor:     entry.Actor,
+			Timestamp: entry.Timestamp,
+			Changes:   changes,
+		})
+	}
+
+	return auditPageResponse{
+		Entries:    entries,
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}
+}
+
+func toPriceChangeResponse(change canonical.PriceChange) priceChangeResponse {
+	return priceChangeResponse{
+		Id:          change.Id,
+		ProductId:   change.ProductId,
+		Price:       change.Price,
+		EffectiveAt: change.EffectiveAt,
+		Status:      change.Status,
+		CreatedAt:   change.CreatedAt,
+		CreatedBy:   change.CreatedBy,
+		AppliedAt:   change.AppliedAt,
+	}
+}
+
+func toPriceHistoryResponse(changes []canonical.PriceChange) priceHistoryResponse {
+	prices := make([]priceChangeResponse, 0, len(changes))
+	for _, change := range changes {
+		prices = append(prices, toPriceChangeResponse(change))
+	}
+
+	return priceHistoryResponse{Prices: prices}
+}
+
+func toPriceAtResponse(price canonical.PriceAt) priceAtResponse {
+	return priceAtResponse{
+		ProductId:   price.ProductId,
+		At:          price.At,
+		Price:       price.Change.Price,
+		EffectiveAt: price.Change.EffectiveAt,
+		Status:      price.Change.Status,
+		ChangeId:    price.Change.Id,
+		Projected:   price.Projected,
+	}
+}
+
+// toPatch converts an RFC 7396 merge patch into a canonical.ProductPatch. A null
+// member clears the field, which resets it to its zero value. The attributes
+// member is itself merged, so a null attribute removes only that attribute.
+func toPatch(document map[string]json.RawMessage) (canonical.ProductPatch, error) {
+	var (
+		patch  canonical.ProductPatch
+		fields []errs.FieldError
+	)
+
+	for key, value := range document {
+		var err error
+
+		switch key {
+		case "name":
+			patch.Name, err = decodeMember[string](value)
+		case "category":
+			patch.Category, err = decodeMember[string](value)
+		case "price":
+			patch.Price, err = decodeMember[money.Money](value)
+		case "stock":
+			patch.Stock, err = decodeMember[int](value)
+		case "price_overrides":
+			patch.PriceOverrides, err = decodeMember[[]money.Money](value)
+		case "backorders":
+			patch.Backorders, err = decodeMember[bool](value)
+		case "reorder_threshold":
+			patch.ReorderThreshold, err = decodeMember[int](value)
+		case "attributes":
+			patch.Attributes, err = decodeMember[map[string]any](value)
+		case "publish_at":
+			patch.PublishAt, err = decodeMember[time.Time](value)
+		case "unpublish_at":
+			patch.UnpublishAt, err = decodeMember[time.Time](value)
+		default:
+			fields = append(fields, errs.FieldError{Field: key, Message: "is not a known field"})
+			continue
+		}
+
+		if err != nil {
+			fields = append(fields, errs.FieldError{Field: key, Message: "has an invalid type"})
+		}
+	}
+
+	if len(fields) > 0 {
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+		return canonical.ProductPatch{}, errs.InvalidFields(fields...)
+	}
+
+	return patch, nil
+}
+
+func decodeMember[T any](value json.RawMessage) (*T, error) {
+	var member T
+
+	if string(value) == "null" {
+		return &member, nil
+	}
+
+	err := json.Unmarshal(value, &member)
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
 }
@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nelsonalves117/go-products-api/internal/actor"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+)
+
+// actorHeader names who is making a request. Authentication happens upstream,
+// so the header is trusted as is.
+const actorHeader = "X-Actor"
+
+// adminHeader lets a request see products outside their publication window,
+// and previewHeader evaluates the windows at another time, as in
+// X-Preview-At: 2024-12-01T00:00:00Z. Like actorHeader, they are trusted.
+const (
+	adminHeader   = "X-Admin"
+	previewHeader = "X-Preview-At"
+)
+
+func actorMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if name := c.Request().Header.Get(actorHeader); name != "" {
+			req := c.Request()
+			c.SetRequest(req.WithContext(actor.NewContext(req.Context(), name)))
+		}
+
+		return next(c)
+	}
+}
+
+func visibilityMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+
+		admin, err := parseHeader(req.Header.Get(adminHeader), adminHeader, strconv.ParseBool)
+		if err != nil {
+			return err
+		}
+
+		at, err := parseHeader(req.Header.Get(previewHeader), previewHeader, func(value string) (time.Time, error) {
+			return time.Parse(time.RFC3339, value)
+		})
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case admin != nil && *admin:
+			c.SetRequest(req.WithContext(visibility.Admin(req.Context())))
+		case at != nil:
+			c.SetRequest(req.WithContext(visibility.Preview(req.Context(), *at)))
+		}
+
+		return next(c)
+	}
+}
+
+func parseHeader[T any](value, name string, parse func(string) (T, error)) (*T, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := parse(value)
+	if err != nil {
+		return nil, errs.BadRequest(fmt.Sprintf("invalid value %q for header %q", value, name))
+	}
+
+	return &parsed, nil
+}
@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (rest *rest) SchedulePriceChange(c echo.Context) error {
+	var request priceChangeRequest
+
+	err := c.Bind(&request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	err = c.Validate(&request)
+	if err != nil {
+		return err
+	}
+
+	change, err := rest.service.SchedulePriceChange(c.Request().Context(), c.Param("id"), request.Price, request.EffectiveAt)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, toPriceChangeResponse(change))
+}
+
+func (rest *rest) GetPriceHistory(c echo.Context) error {
+	changes, err := rest.service.GetPriceHistory(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toPriceHistoryResponse(changes))
+}
+
+// GetPriceAt answers what the price of a product is at the time given by the
+// at query parameter, now if it is missing.
+func (rest *rest) GetPriceAt(c echo.Context) error {
+	at, err := parseParam(c.QueryParam("at"), "at", func(value string) (time.Time, error) {
+		return time.Parse(time.RFC3339, value)
+	})
+	if err != nil {
+		return err
+	}
+
+	if at == nil {
+		now := time.Now()
+		at = &now
+	}
+
+	price, err := rest.service.GetPriceAt(c.Request().Context(), c.Param("id"), *at)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toPriceAtResponse(price))
+}
@@ -0,0 +1,160 @@
+package rest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+)
+
+// attributeParamPrefix starts the query parameters that filter listings by
+// attribute, as in ?attr.voltage=220.
+const attributeParamPrefix = "attr."
+
+// listingParams are the query parameters GET /products understands, along
+// with the attribute filters.
+var listingParams = map[string]bool{
+	"limit":         true,
+	"cursor":        true,
+	"sort":          true,
+	"category":      true,
+	"name_prefix":   true,
+	"currency":      true,
+	"price_min":     true,
+	"price_max":     true,
+	"stock_lt":      true,
+	"created_after": true,
+	"status":        true,
+}
+
+// productQuery builds the listing criteria from the query string, for example
+// ?category=phones&price_max=100&sort=price,-created_at. Price bounds are in
+// the currency parameter, or in defaultCurrency if there is none. Without
+// ?status=draft,active and the like, only active products are listed.
+func productQuery(c echo.Context, defaultCurrency money.Currency) (canonical.ProductQuery, error) {
+	params := c.QueryParams()
+
+	var attributes []canonical.AttributeFilter
+
+	for key, values := range params {
+		if name, ok := strings.CutPrefix(key, attributeParamPrefix); ok {
+			attributes = append(attributes, canonical.AttributeFilter{Name: name, Values: attributeValues(values[0])})
+			continue
+		}
+
+		if !listingParams[key] {
+			return canonical.ProductQuery{}, errs.BadRequest(fmt.Sprintf("unknown query parameter %q", key))
+		}
+	}
+
+	// Filters are sorted so that equal queries are built alike.
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Name < attributes[j].Name })
+
+	query := canonical.ProductQuery{
+		Category:   params.Get("category"),
+		NamePrefix: params.Get("name_prefix"),
+		Sort:       parseSort(params.Get("sort")),
+		Attributes: attributes,
+	}
+
+	if status := params.Get("status"); status != "" {
+		query.Statuses = strings.Split(status, ",")
+	}
+
+	currency := defaultCurrency
+	if code := params.Get("currency"); code != "" {
+		parsed, err := money.ParseCurrency(code)
+		if err != nil {
+			return canonical.ProductQuery{}, errs.BadRequest(fmt.Sprintf("invalid value %q for query parameter %q", code, "currency"))
+		}
+
+		currency = parsed
+	}
+
+	parseMoney := func(value string) (money.Money, error) {
+		return money.Parse(value, currency)
+	}
+
+	var err error
+
+	query.PriceMin, err = parseParam(params.Get("price_min"), "price_min", parseMoney)
+	if err != nil {
+		return canonical.ProductQuery{}, err
+	}
+
+	query.PriceMax, err = parseParam(params.Get("price_max"), "price_max", parseMoney)
+	if err != nil {
+		return canonical.ProductQuery{}, err
+	}
+
+	query.StockLt, err = parseParam(params.Get("stock_lt"), "stock_lt", strconv.Atoi)
+	if err != nil {
+		return canonical.ProductQuery{}, err
+	}
+
+	query.CreatedAfter, err = parseParam(params.Get("created_after"), "created_after", func(value string) (time.Time, error) {
+		return time.Parse(time.RFC3339, value)
+	})
+	if err != nil {
+		return canonical.ProductQuery{}, err
+	}
+
+	return query, nil
+}
+
+// attributeValues returns the values an attribute filter given as text
+// matches: the text itself, and the number or bool it stands for, since the
+// type of the attribute depends on the category of each product.
+func attributeValues(text string) []any {
+	values := []any{text}
+
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		values = append(values, number)
+	}
+
+	if text == "true" || text == "false" {
+		values = append(values, text == "true")
+	}
+
+	return values
+}
+
+// parseSort reads a comma separated list of fields, each one optionally
+// prefixed by - for descending order. Fields are checked by the service.
+func parseSort(value string) []canonical.SortField {
+	if value == "" {
+		return nil
+	}
+
+	var sort []canonical.SortField
+
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+
+		descending := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(strings.TrimPrefix(field, "-"), "+")
+
+		sort = append(sort, canonical.SortField{Field: field, Descending: descending})
+	}
+
+	return sort
+}
+
+func parseParam[T any](value, name string, parse func(string) (T, error)) (*T, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := parse(value)
+	if err != nil {
+		return nil, errs.BadRequest(fmt.Sprintf("invalid value %q for query parameter %q", value, name))
+	}
+
+	return &parsed, nil
+}
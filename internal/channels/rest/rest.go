@@ -1,73 +1,204 @@
 package rest
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
 	"github.com/nelsonalves117/go-products-api/internal/config"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/money"
 	"github.com/nelsonalves117/go-products-api/internal/service"
+	"github.com/nelsonalves117/go-products-api/internal/validation"
+	"github.com/sirupsen/logrus"
 )
 
+const mergePatchContentType = "application/merge-patch+json"
+
 type Rest interface {
+	http.Handler
 	Start() error
+	Shutdown(ctx context.Context) error
 }
 
 type rest struct {
-	service service.Service
+	service  service.Service
+	router   *echo.Echo
+	port     string
+	currency money.Currency
+	logger   logrus.FieldLogger
 }
 
-func New() Rest {
-	return &rest{
-		service: service.New(),
+func New(cfg config.Config, service service.Service, logger logrus.FieldLogger) Rest {
+	rest := &rest{
+		service:  service,
+		router:   echo.New(),
+		port:     cfg.Port,
+		currency: cfg.Currency,
+		logger:   logger,
 	}
+
+	rest.router.HideBanner = true
+	rest.router.HTTPErrorHandler = rest.errorHandler
+	rest.router.Validator = &requestValidator{}
+	rest.router.Use(middleware.Logger())
+	rest.router.Use(actorMiddleware)
+	rest.router.Use(visibilityMiddleware)
+
+	rest.router.GET("/products", rest.GetAllProducts)
+	rest.router.GET("/products/search", rest.SearchProducts)
+	rest.router.GET("/products/trash", rest.GetDeletedProducts)
+	rest.router.GET("/products/:id", rest.GetProductById)
+	rest.router.GET("/products/categories/:category", rest.GetProductsByCategory)
+	rest.router.POST("/products/create", rest.CreateProduct)
+	rest.router.PUT("/products/update/:id", rest.UpdateProduct)
+	rest.router.PATCH("/products/:id", rest.PatchProduct)
+	rest.router.DELETE("/products/delete/:id", rest.DeleteProduct)
+	rest.router.POST("/products/:id/restore", rest.RestoreProduct)
+	rest.router.POST("/products/:id/transitions", rest.TransitionProduct)
+	rest.router.GET("/products/:id/history", rest.GetProductHistory)
+	rest.router.POST("/products/:id/prices", rest.SchedulePriceChange)
+	rest.router.GET("/products/:id/prices", rest.GetPriceHistory)
+	rest.router.GET("/products/:id/price", rest.GetPriceAt)
+	rest.router.GET("/products/:id/stock", rest.GetStock)
+	rest.router.GET("/products/:id/stock/movements", rest.GetStockMovements)
+	rest.router.POST("/products/:id/stock/:warehouse/adjustments", rest.AdjustStock)
+	rest.router.POST("/products/:id/reservations", rest.ReserveStock)
+	rest.router.POST("/products/:id/reservations/:reservation/confirm", rest.ConfirmReservation)
+	rest.router.POST("/products/:id/reservations/:reservation/release", rest.ReleaseReservation)
+	rest.router.GET("/products/:id/variants", rest.GetVariants)
+	rest.router.POST("/products/:id/variants", rest.CreateVariant)
+	rest.router.GET("/categories", rest.GetCategories)
+	rest.router.GET("/categories/:category", rest.GetCategory)
+	rest.router.POST("/categories", rest.CreateCategory)
+	rest.router.PUT("/categories/:category", rest.UpdateCategory)
+	rest.router.DELETE("/categories/:category", rest.DeleteCategory)
+	rest.router.GET("/alerts/low-stock", rest.GetLowStockAlerts)
+	rest.router.PUT("/alerts/low-stock/thresholds/:category", rest.SetCategoryThreshold)
+
+	return rest
 }
 
-func (rest *rest) Start() error {
-	router := echo.New()
+func (rest *rest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest.router.ServeHTTP(w, r)
+}
 
-	router.Use(middleware.Logger())
+// Start serves HTTP until Shutdown is called.
+func (rest *rest) Start() error {
+	err := rest.router.Start(":" + rest.port)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
 
-	router.GET("/products", rest.GetAllProducts)
-	router.GET("/products/:id", rest.GetProductById)
-	router.GET("/products/categories/:category", rest.GetProductsByCategory)
-	router.POST("/products/create", rest.CreateProduct)
-	router.PUT("/products/update/:id", rest.UpdateProduct)
-	router.DELETE("/products/delete/:id", rest.DeleteProduct)
+	return err
+}
 
-	return router.Start(":" + config.Get().Port)
+// Shutdown stops accepting connections and waits for in-flight requests to
+// finish or for ctx to be done.
+func (rest *rest) Shutdown(ctx context.Context) error {
+	return rest.router.Shutdown(ctx)
 }
 
+// GetAllProducts lists the products matching the query parameters. Variants
+// are not listed on their own: parents carry the summary of theirs, which are
+// listed by GET /products/:id/variants.
 func (rest *rest) GetAllProducts(c echo.Context) error {
-	productSlice, err := rest.service.GetAllProducts()
+	query, err := productQuery(c, rest.currency)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errors.New("unexpected error occurred"))
+		return err
 	}
 
-	return c.JSON(http.StatusOK, productSlice)
+	page, err := pageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	productPage, err := rest.service.GetAllProducts(c.Request().Context(), query, page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toPageResponse(productPage))
 }
 
+// GetProductsByCategory lists the products in a category, and with
+// ?include_descendants=true those in its subcategories too.
 func (rest *rest) GetProductsByCategory(c echo.Context) error {
 	category := c.Param("category")
 
-	productSlice, err := rest.service.GetProductsByCategory(category)
+	includeDescendants, err := parseParam(c.QueryParam("include_descendants"), "include_descendants", strconv.ParseBool)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errors.New("unexpected error occurred"))
+		return err
 	}
 
-	return c.JSON(http.StatusOK, productSlice)
+	page, err := pageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	productPage, err := rest.service.GetProductsByCategory(c.Request().Context(), category, includeDescendants != nil && *includeDescendants, page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toPageResponse(productPage))
+}
+
+func (rest *rest) SearchProducts(c echo.Context) error {
+	page, err := pageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	searchPage, err := rest.service.SearchProducts(c.Request().Context(), c.QueryParam("q"), page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toSearchPageResponse(searchPage))
 }
 
 func (rest *rest) GetProductById(c echo.Context) error {
 	id := c.Param("id")
 
-	product, err := rest.service.GetProductById(id)
+	if code := c.QueryParam("currency"); code != "" {
+		return rest.getLocalizedProduct(c, id, code)
+	}
+
+	product, err := rest.service.GetProductById(c.Request().Context(), id)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errors.New("unexpected error occurred"))
+		return err
 	}
 
-	return c.JSON(http.StatusOK, product)
+	setETag(c, product.Version)
+
+	return c.JSON(http.StatusOK, toResponse(product))
+}
+
+// getLocalizedProduct serves GET /products/:id?currency=EUR, which adds the
+// price of the product in that currency and, if it had to be converted, the
+// exchange rate used.
+func (rest *rest) getLocalizedProduct(c echo.Context, id, code string) error {
+	currency, err := money.ParseCurrency(code)
+	if err != nil {
+		return errs.BadRequest(fmt.Sprintf("invalid value %q for query parameter %q", code, "currency"))
+	}
+
+	localized, err := rest.service.GetLocalizedProduct(c.Request().Context(), id, currency)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, localized.Product.Version)
+
+	return c.JSON(http.StatusOK, toLocalizedResponse(localized))
 }
 
 func (rest *rest) CreateProduct(c echo.Context) error {
@@ -75,14 +206,21 @@ func (rest *rest) CreateProduct(c echo.Context) error {
 
 	err := c.Bind(&product)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, errors.New("invalid data"))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
 	}
 
-	createdProduct, err := rest.service.CreateProduct(toCanonical(product))
+	err = c.Validate(&product)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errors.New("unexpected error occurred"))
+		return err
 	}
 
+	createdProduct, err := rest.service.CreateProduct(c.Request().Context(), toCanonical(product))
+	if err != nil {
+		return err
+	}
+
+	setETag(c, createdProduct.Version)
+
 	return c.JSON(http.StatusCreated, toResponse(createdProduct))
 }
 
@@ -91,25 +229,181 @@ func (rest *rest) UpdateProduct(c echo.Context) error {
 
 	err := c.Bind(&product)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, errors.New("invalid data"))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	err = c.Validate(&product)
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
 	}
 
 	id := c.Param("id")
-	updatedProduct, err := rest.service.UpdateProduct(id, toCanonical(product))
+	updatedProduct, err := rest.service.UpdateProduct(c.Request().Context(), id, version, toCanonical(product))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errors.New("unexpected error occurred"))
+		return preconditionError(err)
 	}
 
+	setETag(c, updatedProduct.Version)
+
 	return c.JSON(http.StatusOK, toResponse(updatedProduct))
 }
 
+// PatchProduct applies an RFC 7396 JSON merge patch to a product.
+func (rest *rest) PatchProduct(c echo.Context) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != mergePatchContentType && mediaType != echo.MIMEApplicationJSON {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "expected "+mergePatchContentType)
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	var document map[string]json.RawMessage
+
+	err = json.NewDecoder(c.Request().Body).Decode(&document)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	patch, err := toPatch(document)
+	if err != nil {
+		return err
+	}
+
+	id := c.Param("id")
+	patchedProduct, err := rest.service.PatchProduct(c.Request().Context(), id, version, patch)
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	setETag(c, patchedProduct.Version)
+
+	return c.JSON(http.StatusOK, toResponse(patchedProduct))
+}
+
+// DeleteProduct moves a product to the trash. Active products also need
+// ?force=true.
 func (rest *rest) DeleteProduct(c echo.Context) error {
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	force, err := parseParam(c.QueryParam("force"), "force", strconv.ParseBool)
+	if err != nil {
+		return err
+	}
+
 	id := c.Param("id")
 
-	err := rest.service.DeleteProduct(id)
+	err = rest.service.DeleteProduct(c.Request().Context(), id, version, force != nil && *force)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, errors.New("unexpected error occurred"))
+		return preconditionError(err)
 	}
 
 	return c.JSON(http.StatusOK, nil)
 }
+
+// TransitionProduct moves a product to another lifecycle status.
+func (rest *rest) TransitionProduct(c echo.Context) error {
+	var request transitionRequest
+
+	err := c.Bind(&request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	err = c.Validate(&request)
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	product, err := rest.service.TransitionProduct(c.Request().Context(), c.Param("id"), version, request.Status)
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	setETag(c, product.Version)
+
+	return c.JSON(http.StatusOK, toResponse(product))
+}
+
+func (rest *rest) GetDeletedProducts(c echo.Context) error {
+	page, err := pageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	productPage, err := rest.service.GetDeletedProducts(c.Request().Context(), page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toPageResponse(productPage))
+}
+
+func (rest *rest) RestoreProduct(c echo.Context) error {
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	id := c.Param("id")
+	restoredProduct, err := rest.service.RestoreProduct(c.Request().Context(), id, version)
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	setETag(c, restoredProduct.Version)
+
+	return c.JSON(http.StatusOK, toResponse(restoredProduct))
+}
+
+func (rest *rest) GetProductHistory(c echo.Context) error {
+	page, err := pageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	auditPage, err := rest.service.GetProductHistory(c.Request().Context(), c.Param("id"), page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toAuditPageResponse(auditPage))
+}
+
+func pageRequest(c echo.Context) (canonical.PageRequest, error) {
+	page := canonical.PageRequest{
+		Cursor: c.QueryParam("cursor"),
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 0 {
+			return canonical.PageRequest{}, errs.BadRequest(fmt.Sprintf("invalid value %q for query parameter %q, expected a non-negative integer", limit, "limit"))
+		}
+
+		page.Limit = value
+	}
+
+	return page, nil
+}
+
+type requestValidator struct{}
+
+func (requestValidator) Validate(i any) error {
+	return validation.Struct(i)
+}
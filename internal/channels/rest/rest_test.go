@@ -0,0 +1,831 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/config"
+	"github.com/nelsonalves117/go-products-api/internal/fx"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+	"github.com/nelsonalves117/go-products-api/internal/repositories"
+	"github.com/nelsonalves117/go-products-api/internal/service"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRest(repo repositories.Repository) Rest {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := config.Config{Currency: "BRL"}
+
+	rates := fx.NewFileProvider("testdata/rates.json")
+
+	svc := service.New(cfg, repo, rates, nil, logger)
+
+	// Products can only be put in categories that exist.
+	for _, name := range []string{"testCategory", "Electronics"} {
+		_, err := svc.CreateCategory(context.Background(), canonical.Category{Name: name})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return New(cfg, svc, logger)
+}
+
+// doRequest serves a request on handler. headers holds key/value pairs.
+func doRequest(handler http.Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	var value T
+
+	err := json.Unmarshal(rec.Body.Bytes(), &value)
+	assert.Nil(t, err)
+
+	return value
+}
+
+func TestCreateAndGetProduct(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":10}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	created := decode[productResponse](t, rec)
+	assert.NotEmpty(t, created.Id)
+	assert.Equal(t, "test", created.Name)
+
+	rec = doRequest(server, http.MethodGet, "/products", "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	page := decode[productPageResponse](t, rec)
+	assert.Len(t, page.Products, 1)
+	assert.Equal(t, created.Id, page.Products[0].Id)
+	assert.False(t, page.HasMore)
+}
+
+func TestGetProductById_NotFound(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodGet, "/products/xpto", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+	p := decode[problem](t, rec)
+	assert.Equal(t, "product xpto not found", p.Detail)
+	assert.Equal(t, "/products/xpto", p.Instance)
+}
+
+func TestCreateProduct_Invalid(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"","category":"testCategory","price":{"amount":"-1","currency":"BRL"},"stock":10}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	p := decode[problem](t, rec)
+	assert.Equal(t, []fieldErrorResponse{
+		{Field: "name", Message: "is required"},
+		{Field: "price", Message: "must be greater than or equal to 0"},
+	}, p.Errors)
+}
+
+func TestCreateProduct_MalformedBody(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":`)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestPatchProduct(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":10}`)
+	created := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+created.Id, `{"price":{"amount":"10","currency":"BRL"},"stock":null}`, "If-Match", rec.Header().Get("ETag"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	patched := decode[productResponse](t, rec)
+	assert.Equal(t, created.Id, patched.Id)
+	assert.Equal(t, "test", patched.Name)
+	assert.Equal(t, "testcategory", patched.Category)
+	assert.Equal(t, money.Money{Amount: 1000, Currency: "BRL"}, patched.Price)
+	assert.Equal(t, 0, patched.Stock)
+	assert.True(t, created.CreatedAt.Equal(patched.CreatedAt))
+}
+
+func TestPatchProduct_UnknownField(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":10}`)
+	created := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+created.Id, `{"colour":"red","price":"cheap"}`, "If-Match", `"1"`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	p := decode[problem](t, rec)
+	assert.Equal(t, []fieldErrorResponse{
+		{Field: "colour", Message: "is not a known field"},
+		{Field: "price", Message: "has an invalid type"},
+	}, p.Errors)
+}
+
+func TestUpdateProduct_RequiresMatchingVersion(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":10}`)
+	created := decode[productResponse](t, rec)
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+	body := `{"name":"updated","category":"testCategory","price":{"amount":"100","currency":"BRL"},"stock":5}`
+
+	rec = doRequest(server, http.MethodPut, "/products/update/"+created.Id, body)
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+
+	rec = doRequest(server, http.MethodPut, "/products/update/"+created.Id, body, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	assert.Equal(t, int64(2), decode[productResponse](t, rec).Version)
+
+	rec = doRequest(server, http.MethodPut, "/products/update/"+created.Id, body, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = doRequest(server, http.MethodDelete, "/products/delete/"+created.Id+"?force=true", "", "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = doRequest(server, http.MethodDelete, "/products/delete/"+created.Id+"?force=true", "", "If-Match", `"2"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestSearchProducts(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	doRequest(server, http.MethodPost, "/products/create", `{"name":"Phone","category":"Electronics","price":{"amount":"200","currency":"BRL"},"stock":10}`)
+	doRequest(server, http.MethodPost, "/products/create", `{"name":"Laptop","category":"Electronics","price":{"amount":"900","currency":"BRL"},"stock":3}`)
+
+	rec := doRequest(server, http.MethodGet, "/products/search?q=phone", "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	page := decode[searchPageResponse](t, rec)
+	assert.Len(t, page.Results, 1)
+	assert.Equal(t, "Phone", page.Results[0].Name)
+	assert.Greater(t, page.Results[0].Score, float64(0))
+}
+
+func TestGetAllProducts_FiltersAndSorts(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	doRequest(server, http.MethodPost, "/products/create", `{"name":"Phone","category":"Electronics","price":{"amount":"200","currency":"BRL"},"stock":10}`)
+	doRequest(server, http.MethodPost, "/products/create", `{"name":"Phablet","category":"Electronics","price":{"amount":"300","currency":"BRL"},"stock":2}`)
+	doRequest(server, http.MethodPost, "/products/create", `{"name":"Laptop","category":"Electronics","price":{"amount":"900","currency":"BRL"},"stock":3}`)
+
+	rec := doRequest(server, http.MethodGet, "/products?name_prefix=Ph&sort=-price", "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	page := decode[productPageResponse](t, rec)
+	assert.Len(t, page.Products, 2)
+	assert.Equal(t, "Phablet", page.Products[0].Name)
+	assert.Equal(t, "Phone", page.Products[1].Name)
+
+	rec = doRequest(server, http.MethodGet, "/products?stock_lt=5&price_min=500", "")
+
+	page = decode[productPageResponse](t, rec)
+	assert.Len(t, page.Products, 1)
+	assert.Equal(t, "Laptop", page.Products[0].Name)
+}
+
+func TestGetAllProducts_RejectsUnknownCriteria(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	for _, target := range []string{
+		"/products?colour=red",
+		"/products?price_gt=10",
+		"/products?sort=colour",
+		"/products?price_min=cheap",
+		"/products?cursor=garbage",
+		"/products?limit=-1",
+		"/products?limit=ten",
+	} {
+		rec := doRequest(server, http.MethodGet, target, "")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
+
+func TestDeleteAndRestoreProduct(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":10}`)
+	created := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodDelete, "/products/delete/"+created.Id+"?force=true", "", "If-Match", `"1"`, "X-Actor", "jane")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/trash", "")
+	trash := decode[productPageResponse](t, rec)
+	assert.Len(t, trash.Products, 1)
+	assert.Equal(t, "jane", trash.Products[0].DeletedBy)
+	assert.NotNil(t, trash.Products[0].DeletedAt)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/restore", "", "If-Match", `"2"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestGetProductHistory(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":10}`, "X-Actor", "jane")
+	created := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+created.Id, `{"price":{"amount":"150","currency":"BRL"}}`, "Content-Type", mergePatchContentType, "If-Match", `"1"`, "X-Actor", "john")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id+"/history", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	history := decode[auditPageResponse](t, rec)
+	assert.Len(t, history.Entries, 2)
+
+	assert.Equal(t, "patch", history.Entries[0].Operation)
+	assert.Equal(t, "john", history.Entries[0].Actor)
+	assert.Equal(t, []fieldChangeResponse{{Field: "price", Before: "200.00 BRL", After: "150.00 BRL"}}, history.Entries[0].Changes)
+
+	assert.Equal(t, "create", history.Entries[1].Operation)
+	assert.Equal(t, "jane", history.Entries[1].Actor)
+}
+
+func TestPriceTimeline(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":10}`)
+	created := decode[productResponse](t, rec)
+
+	effectiveAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/prices", `{"price":{"amount":"150","currency":"BRL"},"effective_at":"`+effectiveAt.Format(time.RFC3339)+`"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	scheduled := decode[priceChangeResponse](t, rec)
+	assert.Equal(t, "scheduled", scheduled.Status)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id+"/prices", "")
+	history := decode[priceHistoryResponse](t, rec)
+	assert.Len(t, history.Prices, 2)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id+"/price", "")
+	assert.Equal(t, "200.00", decode[priceAtResponse](t, rec).Price.Decimal())
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id+"/price?at="+effectiveAt.Add(time.Hour).Format(time.RFC3339), "")
+	assert.Equal(t, "150.00", decode[priceAtResponse](t, rec).Price.Decimal())
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id+"/price?at="+effectiveAt.Add(time.Hour).Format(time.RFC3339), "")
+	assert.True(t, decode[priceAtResponse](t, rec).Projected)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/prices", `{"price":{"amount":"150","currency":"BRL"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/prices", `{"price":{"amount":"30","currency":"USD"},"effective_at":"`+effectiveAt.Format(time.RFC3339)+`"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+func TestCreateProduct_RejectsUnknownCurrency(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"19.99","currency":"XYZ"},"stock":10}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"19.999","currency":"BRL"},"stock":10}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":19.99,"currency":"BRL"},"stock":10}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"price":{"amount":"19.99","currency":"BRL"}`)
+}
+
+func TestGetProductById_InCurrency(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"100.00","currency":"BRL"},"price_overrides":[{"amount":"20.00","currency":"USD"}],"stock":10}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	created := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id+"?currency=USD", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	usd := decode[localizedProductResponse](t, rec)
+	assert.Equal(t, "override", usd.LocalPrice.Source)
+	assert.Equal(t, "20.00", usd.LocalPrice.Price.Decimal())
+	assert.Nil(t, usd.LocalPrice.ExchangeRate)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id+"?currency=EUR", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	eur := decode[localizedProductResponse](t, rec)
+	assert.Equal(t, "converted", eur.LocalPrice.Source)
+	assert.Equal(t, money.Money{Amount: 1700, Currency: "EUR"}, eur.LocalPrice.Price)
+	assert.Equal(t, "0.170000", eur.LocalPrice.ExchangeRate.Rate)
+	assert.Equal(t, time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC), eur.LocalPrice.ExchangeRate.AsOf)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id+"?currency=JPY", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id+"?currency=XYZ", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCreateProduct_RejectsDuplicateOverrides(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"100.00","currency":"BRL"},"price_overrides":[{"amount":"20.00","currency":"BRL"}],"stock":10}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+func TestAdjustStock(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":10}`)
+	created := decode[productResponse](t, rec)
+	assert.Equal(t, 10, created.Available)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/stock/main/adjustments", `{"quantity":-11}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/stock/elsewhere/adjustments", `{"quantity":1}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/stock/main/adjustments", `{"quantity":-4}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	stock := decode[stockResponse](t, rec)
+	assert.Equal(t, 6, stock.Available)
+	assert.Equal(t, []stockLevelResponse{{Warehouse: "main", Quantity: 6}}, stock.Levels)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id, "")
+	product := decode[productResponse](t, rec)
+	assert.Equal(t, 6, product.Stock)
+	assert.Equal(t, 6, product.Available)
+}
+
+func TestGetStockMovements(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":10}`)
+	created := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/stock/main/adjustments", `{"type":"sale","quantity":-3,"reference":"order-1"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id+"/stock/movements?limit=1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	page := decode[stockMovementPageResponse](t, rec)
+	assert.True(t, page.HasMore)
+	assert.Len(t, page.Movements, 1)
+	assert.Equal(t, "sale", page.Movements[0].Type)
+	assert.Equal(t, -3, page.Movements[0].Quantity)
+	assert.Equal(t, "order-1", page.Movements[0].Reference)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id+"/stock/movements?cursor="+page.NextCursor, "")
+	page = decode[stockMovementPageResponse](t, rec)
+	assert.False(t, page.HasMore)
+	assert.Len(t, page.Movements, 1)
+	assert.Equal(t, "receipt", page.Movements[0].Type)
+	assert.Equal(t, 10, page.Movements[0].Quantity)
+}
+
+func TestReservations(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":1}`)
+	created := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/reservations", `{"quantity":1,"ttl_seconds":60}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	held := decode[reservationResponse](t, rec)
+	assert.Equal(t, "held", held.Status)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/reservations", `{"quantity":1,"ttl_seconds":60}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id, "")
+	product := decode[productResponse](t, rec)
+	assert.Equal(t, 0, product.Available)
+	assert.Equal(t, 1, product.Reserved)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/reservations/"+held.Id+"/release", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "released", decode[reservationResponse](t, rec).Status)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/reservations", `{"quantity":1,"ttl_seconds":60}`)
+	held = decode[reservationResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/reservations/"+held.Id+"/confirm", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "confirmed", decode[reservationResponse](t, rec).Status)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+created.Id+"/reservations/"+held.Id+"/release", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+created.Id, "")
+	product = decode[productResponse](t, rec)
+	assert.Equal(t, 0, product.Stock)
+	assert.Equal(t, 0, product.Reserved)
+}
+
+func TestLowStockAlerts(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPut, "/alerts/low-stock/thresholds/testCategory", `{"threshold":5}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, categoryThresholdResponse{Category: "testcategory", Threshold: 5}, decode[categoryThresholdResponse](t, rec))
+
+	rec = doRequest(server, http.MethodPost, "/products/create", `{"name":"test","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":10}`)
+	created := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPost, "/products/create", `{"name":"other","category":"testCategory","price":{"amount":"200","currency":"BRL"},"stock":10,"reorder_threshold":2}`)
+	other := decode[productResponse](t, rec)
+	assert.Equal(t, 2, other.ReorderThreshold)
+
+	doRequest(server, http.MethodPost, "/products/"+created.Id+"/stock/main/adjustments", `{"quantity":-6}`)
+	doRequest(server, http.MethodPost, "/products/"+created.Id+"/stock/main/adjustments", `{"quantity":-1}`)
+	doRequest(server, http.MethodPost, "/products/"+other.Id+"/stock/main/adjustments", `{"quantity":-6}`)
+
+	rec = doRequest(server, http.MethodGet, "/alerts/low-stock", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	page := decode[lowStockAlertPageResponse](t, rec)
+	assert.Len(t, page.Alerts, 1)
+	assert.Equal(t, created.Id, page.Alerts[0].ProductId)
+	assert.Equal(t, 3, page.Alerts[0].Stock)
+	assert.Equal(t, 5, page.Alerts[0].Threshold)
+
+	doRequest(server, http.MethodPost, "/products/"+created.Id+"/stock/main/adjustments", `{"type":"receipt","quantity":2}`)
+
+	rec = doRequest(server, http.MethodGet, "/alerts/low-stock", "")
+	assert.Empty(t, decode[lowStockAlertPageResponse](t, rec).Alerts)
+
+	doRequest(server, http.MethodPut, "/alerts/low-stock/thresholds/testCategory", `{"threshold":6}`)
+
+	rec = doRequest(server, http.MethodGet, "/alerts/low-stock", "")
+	page = decode[lowStockAlertPageResponse](t, rec)
+	assert.Len(t, page.Alerts, 1)
+	assert.Equal(t, 6, page.Alerts[0].Threshold)
+
+	doRequest(server, http.MethodPut, "/alerts/low-stock/thresholds/testCategory", `{"threshold":0}`)
+
+	rec = doRequest(server, http.MethodGet, "/alerts/low-stock", "")
+	assert.Empty(t, decode[lowStockAlertPageResponse](t, rec).Alerts)
+}
+
+func TestCategoryTree(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/categories", `{"name":"Phones","parent":"electronics"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	phones := decode[categoryResponse](t, rec)
+	assert.Equal(t, "phones", phones.Slug)
+	assert.Equal(t, "/electronics/phones", phones.Path)
+
+	rec = doRequest(server, http.MethodPost, "/categories", `{"name":"Tablets","parent":"toys"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/create", `{"name":"Phone","category":"Phones","price":{"amount":"200","currency":"BRL"},"stock":1}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "phones", decode[productResponse](t, rec).Category)
+
+	doRequest(server, http.MethodPost, "/products/create", `{"name":"Laptop","category":"electronics","price":{"amount":"900","currency":"BRL"},"stock":1}`)
+
+	rec = doRequest(server, http.MethodPost, "/products/create", `{"name":"Ball","category":"toys","price":{"amount":"10","currency":"BRL"},"stock":1}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/categories/electronics", "")
+	assert.Len(t, decode[productPageResponse](t, rec).Products, 1)
+
+	rec = doRequest(server, http.MethodGet, "/products/categories/electronics?include_descendants=true", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, decode[productPageResponse](t, rec).Products, 2)
+
+	rec = doRequest(server, http.MethodGet, "/products/categories/toys", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(server, http.MethodDelete, "/categories/electronics", "", "If-Match", `"1"`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(server, http.MethodPut, "/categories/phones", `{"name":"Mobile phones","parent":"testcategory"}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/testcategory/phones", decode[categoryResponse](t, rec).Path)
+
+	rec = doRequest(server, http.MethodPut, "/categories/phones", `{"name":"Phones","parent":"testcategory"}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/categories", "")
+	categories := decode[categoryListResponse](t, rec).Categories
+	assert.Len(t, categories, 3)
+	assert.Equal(t, "electronics", categories[0].Slug)
+}
+
+func TestVariants(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"T-shirt","category":"testCategory","price":{"amount":"59.90","currency":"BRL"}}`)
+	parent := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+parent.Id+"/variants", `{"sku":"TS-RED-M","variant_attributes":{"size":"M","color":"red"},"stock":4}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	variant := decode[productResponse](t, rec)
+	assert.Equal(t, parent.Id, variant.ParentId)
+	assert.Equal(t, "T-shirt (red, M)", variant.Name)
+	assert.Equal(t, "testcategory", variant.Category)
+	assert.Equal(t, parent.Price, variant.Price)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+parent.Id+"/variants", `{"sku":"TS-RED-XL","variant_attributes":{"size":"XL","color":"red"},"price":{"amount":"69.90","currency":"BRL"},"stock":2}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+parent.Id+"/variants", `{"sku":"TS-RED-M","variant_attributes":{"size":"S","color":"red"}}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+parent.Id+"/variants", `{"sku":"TS-RED-M2","variant_attributes":{"color":"red","size":"M"}}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+variant.Id+"/variants", `{"sku":"TS-RED-M-X","variant_attributes":{"fit":"slim"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+parent.Id+"/variants", `{"sku":"TS-BLUE-M","attributes":{"size":"M","color":"blue"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+parent.Id+"/variants", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, decode[variantListResponse](t, rec).Variants, 2)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+parent.Id, "")
+	summary := decode[productResponse](t, rec).Variants
+	assert.NotNil(t, summary)
+	assert.Equal(t, 2, summary.Count)
+	assert.Equal(t, 6, summary.Stock)
+	assert.Equal(t, "59.90", summary.PriceRange.Min.Decimal())
+	assert.Equal(t, "69.90", summary.PriceRange.Max.Decimal())
+
+	rec = doRequest(server, http.MethodGet, "/products/missing/variants", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestVariants_FollowTheirParentsPrice(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"T-shirt","category":"testCategory","price":{"amount":"59.90","currency":"BRL"}}`)
+	parent := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+parent.Id+"/variants", `{"sku":"TS-RED-M","variant_attributes":{"size":"M","color":"red"}}`)
+	variant := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+parent.Id+"/variants", `{"sku":"TS-RED-XL","variant_attributes":{"size":"XL","color":"red"},"price":{"amount":"69.90","currency":"BRL"}}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+parent.Id, `{"price":{"amount":"49.90","currency":"BRL"}}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+variant.Id, "")
+	assert.Equal(t, "49.90", decode[productResponse](t, rec).Price.Decimal())
+
+	rec = doRequest(server, http.MethodGet, "/products/"+parent.Id, "")
+	summary := decode[productResponse](t, rec).Variants
+	assert.Equal(t, "49.90", summary.PriceRange.Min.Decimal())
+	assert.Equal(t, "69.90", summary.PriceRange.Max.Decimal())
+
+	// Once given a price of its own, the variant no longer follows.
+	rec = doRequest(server, http.MethodPatch, "/products/"+variant.Id, `{"price":{"amount":"54.90","currency":"BRL"}}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+parent.Id, `{"price":{"amount":"39.90","currency":"BRL"}}`, "If-Match", `"2"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+parent.Id+"/variants", "")
+	variants := decode[variantListResponse](t, rec).Variants
+	assert.Len(t, variants, 2)
+
+	prices := make([]string, 0, len(variants))
+	for _, listed := range variants {
+		prices = append(prices, listed.Price.Decimal())
+	}
+	assert.ElementsMatch(t, []string{"54.90", "69.90"}, prices)
+}
+
+func TestVariants_ListedThroughTheirParent(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"T-shirt","category":"testCategory","price":{"amount":"59.90","currency":"BRL"}}`)
+	parent := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+parent.Id+"/variants", `{"sku":"TS-RED-M","variant_attributes":{"size":"M","color":"red"},"stock":4}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products", "")
+	products := decode[productPageResponse](t, rec).Products
+	assert.Len(t, products, 1)
+	assert.Equal(t, parent.Id, products[0].Id)
+	assert.Equal(t, 4, products[0].Variants.Stock)
+
+	rec = doRequest(server, http.MethodGet, "/products/categories/testCategory", "")
+	products = decode[productPageResponse](t, rec).Products
+	assert.Len(t, products, 1)
+	assert.Equal(t, 4, products[0].Variants.Stock)
+
+	rec = doRequest(server, http.MethodGet, "/products/search?q=shirt", "")
+	results := decode[searchPageResponse](t, rec).Results
+	assert.Len(t, results, 1)
+	assert.Equal(t, 4, results[0].Variants.Stock)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+parent.Id+"?currency=BRL", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 4, decode[localizedProductResponse](t, rec).Variants.Stock)
+}
+
+func TestVariants_CannotBeOrphaned(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"T-shirt","category":"testCategory","price":{"amount":"59.90","currency":"BRL"}}`)
+	parent := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+parent.Id+"/variants", `{"sku":"TS-RED-M","variant_attributes":{"size":"M","color":"red"}}`)
+	variant := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodDelete, "/products/delete/"+parent.Id+"?force=true", "", "If-Match", `"1"`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+parent.Id, `{"category":"Electronics"}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+variant.Id, `{"category":"Electronics"}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// Without variants left, the parent goes; its variants cannot come back
+	// without it.
+	rec = doRequest(server, http.MethodDelete, "/products/delete/"+variant.Id+"?force=true", "", "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodDelete, "/products/delete/"+parent.Id+"?force=true", "", "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+variant.Id+"/restore", "", "If-Match", `"2"`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+parent.Id+"/restore", "", "If-Match", `"2"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+variant.Id+"/restore", "", "If-Match", `"2"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAttributeSchema(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/categories", `{"name":"Chargers","attributes":[{"name":"voltage","type":"number","required":true},{"name":"plug","type":"enum","values":["a","c"]}]}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Len(t, decode[categoryResponse](t, rec).Attributes, 2)
+
+	rec = doRequest(server, http.MethodPost, "/products/create", `{"name":"Charger","category":"chargers","price":{"amount":"20","currency":"BRL"},"attributes":{"plug":"c"}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/create", `{"name":"Charger","category":"chargers","price":{"amount":"20","currency":"BRL"},"attributes":{"voltage":220,"plug":"c"}}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	charger := decode[productResponse](t, rec)
+	assert.Equal(t, map[string]any{"voltage": 220.0, "plug": "c"}, charger.Attributes)
+
+	doRequest(server, http.MethodPost, "/products/create", `{"name":"Travel charger","category":"chargers","price":{"amount":"30","currency":"BRL"},"attributes":{"voltage":110}}`)
+
+	rec = doRequest(server, http.MethodGet, "/products?attr.voltage=220", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	products := decode[productPageResponse](t, rec).Products
+	assert.Len(t, products, 1)
+	assert.Equal(t, charger.Id, products[0].Id)
+
+	rec = doRequest(server, http.MethodGet, "/products?attr.Voltage=220", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+charger.Id, `{"attributes":{"voltage":"high"}}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+charger.Id, `{"attributes":{"voltage":127}}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"voltage": 127.0, "plug": "c"}, decode[productResponse](t, rec).Attributes)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+charger.Id, `{"attributes":{"plug":null}}`, "If-Match", `"2"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]any{"voltage": 127.0}, decode[productResponse](t, rec).Attributes)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+charger.Id, `{"attributes":{"voltage":null}}`, "If-Match", `"3"`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+func TestProductLifecycle(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"Draft","category":"testCategory","price":{"amount":"10","currency":"BRL"},"status":"draft"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	draft := decode[productResponse](t, rec)
+	assert.Equal(t, canonical.StatusDraft, draft.Status)
+
+	rec = doRequest(server, http.MethodPost, "/products/create", `{"name":"Live","category":"testCategory","price":{"amount":"10","currency":"BRL"}}`)
+	assert.Equal(t, canonical.StatusActive, decode[productResponse](t, rec).Status)
+
+	rec = doRequest(server, http.MethodPost, "/products/create", `{"name":"Gone","category":"testCategory","price":{"amount":"10","currency":"BRL"},"status":"archived"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products", "")
+	assert.Len(t, decode[productPageResponse](t, rec).Products, 1)
+
+	rec = doRequest(server, http.MethodGet, "/products?status=draft,active", "")
+	assert.Len(t, decode[productPageResponse](t, rec).Products, 2)
+
+	rec = doRequest(server, http.MethodGet, "/products/categories/testcategory", "")
+	assert.Len(t, decode[productPageResponse](t, rec).Products, 1)
+
+	rec = doRequest(server, http.MethodGet, "/products/search?q=draft", "")
+	assert.Empty(t, decode[searchPageResponse](t, rec).Results)
+
+	rec = doRequest(server, http.MethodGet, "/products?status=retired", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(server, http.MethodPut, "/products/update/"+draft.Id, `{"name":"Draft","category":"testCategory","price":{"amount":"10","currency":"BRL"},"status":"active"}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "status", decode[problem](t, rec).Errors[0].Field)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+draft.Id+"/transitions", `{"status":"discontinued"}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+draft.Id+"/transitions", `{"status":"discontinued"}`, "If-Match", `"7"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+draft.Id+"/transitions", `{"status":"active"}`, "If-Match", `"1"`, "X-Actor", "jane")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, canonical.StatusActive, decode[productResponse](t, rec).Status)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+draft.Id+"/history", "")
+	entries := decode[auditPageResponse](t, rec).Entries
+	assert.Equal(t, canonical.OperationTransition, entries[0].Operation)
+	assert.Equal(t, "jane", entries[0].Actor)
+
+	rec = doRequest(server, http.MethodDelete, "/products/delete/"+draft.Id, "", "If-Match", `"2"`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/products/"+draft.Id+"/transitions", `{"status":"discontinued"}`, "If-Match", `"2"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodDelete, "/products/delete/"+draft.Id, "", "If-Match", `"3"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestPublicationWindows(t *testing.T) {
+	server := newTestRest(repositories.NewMemory())
+
+	rec := doRequest(server, http.MethodPost, "/products/create", `{"name":"Campaign","category":"testCategory","price":{"amount":"10","currency":"BRL"},"publish_at":"2030-01-01T00:00:00Z","unpublish_at":"2030-02-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	campaign := decode[productResponse](t, rec)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+campaign.Id, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+campaign.Id, "", "X-Admin", "true")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodGet, "/products", "", "X-Preview-At", "2030-01-15T00:00:00Z")
+	assert.Len(t, decode[productPageResponse](t, rec).Products, 1)
+
+	rec = doRequest(server, http.MethodGet, "/products", "", "X-Preview-At", "2030-02-01T00:00:00Z")
+	assert.Len(t, decode[productPageResponse](t, rec).Products, 0)
+
+	rec = doRequest(server, http.MethodGet, "/products", "", "X-Preview-At", "next week")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(server, http.MethodPatch, "/products/"+campaign.Id, `{"publish_at":null}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Nil(t, decode[productResponse](t, rec).PublishAt)
+
+	rec = doRequest(server, http.MethodGet, "/products/"+campaign.Id, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
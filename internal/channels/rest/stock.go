@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+)
+
+func (rest *rest) GetStock(c echo.Context) error {
+	stock, err := rest.service.GetStock(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	setETag(c, stock.Version)
+
+	return c.JSON(http.StatusOK, toStockResponse(stock))
+}
+
+func (rest *rest) GetStockMovements(c echo.Context) error {
+	page, err := pageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	movementPage, err := rest.service.GetStockMovements(c.Request().Context(), c.Param("id"), page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toStockMovementPageResponse(movementPage))
+}
+
+// AdjustStock records a receipt, sale, adjustment or return of the quantity in
+// the request body, negative to take stock out, at a warehouse.
+func (rest *rest) AdjustStock(c echo.Context) error {
+	var request stockAdjustmentRequest
+
+	err := c.Bind(&request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	err = c.Validate(&request)
+	if err != nil {
+		return err
+	}
+
+	movement := canonical.StockMovement{
+		Warehouse: c.Param("warehouse"),
+		Type:      request.Type,
+		Quantity:  request.Quantity,
+		Reference: request.Reference,
+	}
+
+	stock, err := rest.service.AdjustStock(c.Request().Context(), c.Param("id"), movement)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, stock.Version)
+
+	return c.JSON(http.StatusOK, toStockResponse(stock))
+}
+
+// ReserveStock holds stock of a product for a checkout. The hold lasts for
+// ttl_seconds, or for the configured default if it is left out.
+func (rest *rest) ReserveStock(c echo.Context) error {
+	var request reservationRequest
+
+	err := c.Bind(&request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	err = c.Validate(&request)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Duration(request.TTLSeconds) * time.Second
+
+	reservation, err := rest.service.ReserveStock(c.Request().Context(), c.Param("id"), request.Quantity, ttl)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, toReservationResponse(reservation))
+}
+
+func (rest *rest) ConfirmReservation(c echo.Context) error {
+	reservation, err := rest.service.ConfirmReservation(c.Request().Context(), c.Param("id"), c.Param("reservation"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toReservationResponse(reservation))
+}
+
+func (rest *rest) ReleaseReservation(c echo.Context) error {
+	reservation, err := rest.service.ReleaseReservation(c.Request().Context(), c.Param("id"), c.Param("reservation"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toReservationResponse(reservation))
+}
@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+)
+
+func (rest *rest) GetVariants(c echo.Context) error {
+	variants, err := rest.service.GetVariants(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, toVariantListResponse(variants))
+}
+
+// CreateVariant adds a variant to the product, which takes its name and
+// category from it.
+func (rest *rest) CreateVariant(c echo.Context) error {
+	var request variantRequest
+
+	err := c.Bind(&request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	err = c.Validate(&request)
+	if err != nil {
+		return err
+	}
+
+	variant, err := rest.service.CreateVariant(c.Request().Context(), c.Param("id"), canonical.Product{
+		SKU:               request.SKU,
+		VariantAttributes: request.VariantAttributes,
+		Price:             request.Price,
+		Stock:             request.Stock,
+		Backorders:        request.Backorders,
+		ReorderThreshold:  request.ReorderThreshold,
+	})
+	if err != nil {
+		return err
+	}
+
+	setETag(c, variant.Version)
+
+	return c.JSON(http.StatusCreated, toResponse(variant))
+}
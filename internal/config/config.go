@@ -2,27 +2,142 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/kkyr/fig"
+	"github.com/nelsonalves117/go-products-api/internal/money"
 )
 
-var (
-	config cfg
+const (
+	StorageMongo  = "mongo"
+	StorageMemory = "memory"
 )
 
-type cfg struct {
-	Port             string `fig:"port"`
-	ConnectionString string `fig:"connection_string"`
+const (
+	NotifierLog     = "log"
+	NotifierWebhook = "webhook"
+)
+
+type Config struct {
+	Port             string   `fig:"port"`
+	ConnectionString string   `fig:"connection_string"`
+	Database         string   `fig:"database" default:"product_db"`
+	Storage          string   `fig:"storage" default:"mongo"`
+	Timeouts         Timeouts `fig:"timeouts"`
+
+	// Currency is the one listing price filters are in unless another is
+	// asked for, and the one prices stored before they carried a currency are
+	// read in.
+	Currency money.Currency `fig:"currency" default:"BRL"`
+
+	ShutdownGracePeriod time.Duration `fig:"shutdown_grace_period" default:"15s"`
+
+	Trash  Trash  `fig:"trash"`
+	Prices Prices `fig:"prices"`
+	FX     FX     `fig:"fx"`
+	Stock  Stock  `fig:"stock"`
+
+	Reservations Reservations `fig:"reservations"`
+	Alerts       Alerts       `fig:"alerts"`
 }
 
-func Parse() error {
-	err := fig.Load(&config, fig.File("config/config.yaml"))
-	if err != nil {
-		return fmt.Errorf("an error occurred while trying to parse config file %w", err)
+// Trash controls how long deleted products can still be restored.
+type Trash struct {
+	Retention     time.Duration `fig:"retention" default:"720h"`
+	PurgeInterval time.Duration `fig:"purge_interval" default:"1h"`
+}
+
+// Prices controls how often scheduled price changes are checked for being due.
+type Prices struct {
+	SchedulerInterval time.Duration `fig:"scheduler_interval" default:"1m"`
+}
+
+// FX configures the exchange rates prices are converted with.
+type FX struct {
+	RatesFile string        `fig:"rates_file" default:"config/rates.json"`
+	CacheTTL  time.Duration `fig:"cache_ttl" default:"1h"`
+}
+
+// Stock lists the warehouses products are stocked at. The first one is the
+// default: stock set through product writes, and stock stored before it was
+// kept per warehouse, belongs to it.
+type Stock struct {
+	Warehouses []string `fig:"warehouses" default:"[main]"`
+}
+
+// Reservations bounds how long stock can be held for a checkout and controls
+// how often expired holds are released.
+type Reservations struct {
+	DefaultTTL     time.Duration `fig:"default_ttl" default:"15m"`
+	MaxTTL         time.Duration `fig:"max_ttl" default:"1h"`
+	ReaperInterval time.Duration `fig:"reaper_interval" default:"30s"`
+}
+
+// Alerts selects where low-stock alerts are sent: to the log or, as JSON, to
+// a webhook. Alerts are sent, and those that failed retried, every
+// SendInterval.
+type Alerts struct {
+	Notifier       string        `fig:"notifier" default:"log"`
+	WebhookURL     string        `fig:"webhook_url"`
+	WebhookTimeout time.Duration `fig:"webhook_timeout" default:"5s"`
+	SendInterval   time.Duration `fig:"send_interval" default:"30s"`
+}
+
+// Timeouts bounds how long each service operation may run. Operations without
+// an entry use Default; a zero duration disables the timeout.
+type Timeouts struct {
+	Default    time.Duration            `fig:"default" default:"5s"`
+	Operations map[string]time.Duration `fig:"operations"`
+}
+
+func (timeouts Timeouts) For(operation string) time.Duration {
+	if timeout, ok := timeouts.Operations[operation]; ok {
+		return timeout
 	}
-	return nil
+
+	return timeouts.Default
 }
 
-func Get() cfg {
-	return config
+// Parse loads the config from file, filling in defaults for missing keys.
+func Parse(file string) (Config, error) {
+	var config Config
+
+	err := fig.Load(&config, fig.File(file))
+	if err != nil {
+		return Config{}, fmt.Errorf("an error occurred while trying to parse config file %w", err)
+	}
+
+	if config.Storage != StorageMongo && config.Storage != StorageMemory {
+		return Config{}, fmt.Errorf("invalid storage %q, expected %q or %q", config.Storage, StorageMongo, StorageMemory)
+	}
+
+	if !config.Currency.Known() {
+		return Config{}, fmt.Errorf("invalid currency %q", config.Currency)
+	}
+
+	if len(config.Stock.Warehouses) == 0 {
+		return Config{}, fmt.Errorf("at least one warehouse must be configured")
+	}
+
+	if config.Alerts.Notifier != NotifierLog && config.Alerts.Notifier != NotifierWebhook {
+		return Config{}, fmt.Errorf("invalid notifier %q, expected %q or %q", config.Alerts.Notifier, NotifierLog, NotifierWebhook)
+	}
+
+	if config.Alerts.Notifier == NotifierWebhook && config.Alerts.WebhookURL == "" {
+		return Config{}, fmt.Errorf("a webhook url must be configured for the webhook notifier")
+	}
+
+	// Jobs run on a ticker, which cannot tick at a zero or negative interval.
+	for key, interval := range map[string]time.Duration{
+		"trash.purge_interval":         config.Trash.PurgeInterval,
+		"prices.scheduler_interval":    config.Prices.SchedulerInterval,
+		"reservations.reaper_interval": config.Reservations.ReaperInterval,
+		"alerts.send_interval":         config.Alerts.SendInterval,
+	} {
+		if interval <= 0 {
+			return Config{}, fmt.Errorf("invalid %s %s, expected a positive duration", key, interval)
+		}
+	}
+
+	return config, nil
 }
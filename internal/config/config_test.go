@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfig writes content to a config file and returns its path relative to
+// the working directory, which is where Parse looks for it.
+func writeConfig(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+
+	err := os.WriteFile(file, []byte(content), 0o600)
+	assert.Nil(t, err)
+
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+
+	relative, err := filepath.Rel(wd, file)
+	assert.Nil(t, err)
+
+	return relative
+}
+
+func TestParse_Defaults(t *testing.T) {
+	cfg, err := Parse(writeConfig(t, "port: \"8080\"\n"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, StorageMongo, cfg.Storage)
+	assert.Positive(t, cfg.Prices.SchedulerInterval)
+}
+
+func TestParse_RejectsNegativeIntervals(t *testing.T) {
+	for _, content := range []string{
+		"trash:\n  purge_interval: -1h\n",
+		"prices:\n  scheduler_interval: -1m\n",
+		"reservations:\n  reaper_interval: -30s\n",
+		"alerts:\n  send_interval: -5s\n",
+	} {
+		_, err := Parse(writeConfig(t, content))
+
+		assert.NotNil(t, err, content)
+	}
+}
+
+func TestParse_ZeroIntervalTakesDefault(t *testing.T) {
+	cfg, err := Parse(writeConfig(t, "reservations:\n  reaper_interval: 0s\n"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, cfg.Reservations.ReaperInterval)
+}
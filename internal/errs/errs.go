@@ -0,0 +1,158 @@
+package errs
+
+import (
+	"errors"
+)
+
+// Kind classifies an error so that the channels can decide how to report it
+// without knowing anything about the layer it came from.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindBadRequest
+	// KindVersionConflict is a conflict with a newer version of what was
+	// written, which a client can resolve by reading it again.
+	KindVersionConflict
+)
+
+func (kind Kind) String() string {
+	switch kind {
+	case KindNotFound:
+		return "not found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation"
+	case KindBadRequest:
+		return "bad request"
+	case KindVersionConflict:
+		return "version conflict"
+	default:
+		return "internal"
+	}
+}
+
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError describes why a single input field was rejected.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NotFound(message string) error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+func Conflict(message string) error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+// VersionConflict reports a versioned write that lost against another writer.
+func VersionConflict(message string) error {
+	return &Error{Kind: KindVersionConflict, Message: message}
+}
+
+func Validation(message string) error {
+	return &Error{Kind: KindValidation, Message: message}
+}
+
+// BadRequest reports a request that is malformed as a whole, such as one using
+// an unsupported query parameter.
+func BadRequest(message string) error {
+	return &Error{Kind: KindBadRequest, Message: message}
+}
+
+// InvalidFields returns a validation error listing every rejected field.
+func InvalidFields(fields ...FieldError) error {
+	return &Error{Kind: KindValidation, Message: "validation failed", Fields: fields}
+}
+
+func Internal(err error) error {
+	return &Error{Kind: KindInternal, Err: err}
+}
+
+// Wrap adds context to err while keeping its kind. Errors without a kind are
+// treated as internal.
+func Wrap(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Kind: KindOf(err), Message: message, Err: err}
+}
+
+// KindOf returns the kind of the outermost *Error in the chain of err.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return KindInternal
+}
+
+// Detail returns the message of the innermost *Error in the chain of err, which
+// is the one written where the problem was detected and is safe to show to
+// clients.
+func Detail(err error) string {
+	detail := ""
+
+	for err != nil {
+		var e *Error
+		if !errors.As(err, &e) {
+			break
+		}
+
+		if e.Message != "" {
+			detail = e.Message
+		}
+
+		err = e.Err
+	}
+
+	return detail
+}
+
+// Fields returns the field errors carried anywhere in the chain of err.
+func Fields(err error) []FieldError {
+	for err != nil {
+		var e *Error
+		if !errors.As(err, &e) {
+			return nil
+		}
+
+		if len(e.Fields) > 0 {
+			return e.Fields
+		}
+
+		err = e.Err
+	}
+
+	return nil
+}
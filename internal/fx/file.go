@@ -0,0 +1,90 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+)
+
+// rateFile is the format of the file read by the file provider: the price of
+// one unit of base in every other currency, as decimal strings.
+//
+//	{"base": "USD", "as_of": "2026-10-01T00:00:00Z", "rates": {"BRL": "5.4321", "EUR": "0.9210"}}
+type rateFile struct {
+	Base  money.Currency            `json:"base"`
+	AsOf  time.Time                 `json:"as_of"`
+	Rates map[money.Currency]string `json:"rates"`
+}
+
+type fileProvider struct {
+	path string
+}
+
+// NewFileProvider returns a RateProvider that reads rates from the JSON file
+// at path. The file is read on every call, so that it can be updated while
+// the service runs; wrap the provider with NewCache.
+func NewFileProvider(path string) RateProvider {
+	return &fileProvider{path: path}
+}
+
+func (provider *fileProvider) Rate(ctx context.Context, from, to money.Currency) (canonical.ExchangeRate, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.ExchangeRate{}, errs.Internal(err)
+	}
+
+	data, err := os.ReadFile(provider.path)
+	if err != nil {
+		return canonical.ExchangeRate{}, errs.Internal(err)
+	}
+
+	var file rateFile
+
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return canonical.ExchangeRate{}, errs.Internal(fmt.Errorf("reading exchange rates from %s: %w", provider.path, err))
+	}
+
+	fromRate, err := file.rate(from)
+	if err != nil {
+		return canonical.ExchangeRate{}, err
+	}
+
+	toRate, err := file.rate(to)
+	if err != nil {
+		return canonical.ExchangeRate{}, err
+	}
+
+	return canonical.ExchangeRate{
+		From:   from,
+		To:     to,
+		Rate:   new(big.Rat).Quo(toRate, fromRate),
+		AsOf:   file.AsOf,
+		Source: "file",
+	}, nil
+}
+
+// rate returns the price of one unit of base in currency.
+func (file rateFile) rate(currency money.Currency) (*big.Rat, error) {
+	if currency == file.Base {
+		return big.NewRat(1, 1), nil
+	}
+
+	text, ok := file.Rates[currency]
+	if !ok {
+		return nil, errs.Validation(fmt.Sprintf("no exchange rate for %s", currency))
+	}
+
+	rate, ok := new(big.Rat).SetString(text)
+	if !ok || rate.Sign() <= 0 {
+		return nil, errs.Internal(fmt.Errorf("invalid exchange rate %q for %s", text, currency))
+	}
+
+	return rate, nil
+}
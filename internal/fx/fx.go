@@ -0,0 +1,71 @@
+// Package fx provides the exchange rates used to show prices in currencies a
+// product has no price for.
+package fx
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+)
+
+type RateProvider interface {
+	// Rate returns the price of one unit of from in to. It fails with a
+	// validation error if the provider has no rate for the pair.
+	Rate(ctx context.Context, from, to money.Currency) (canonical.ExchangeRate, error)
+}
+
+type pair struct {
+	from money.Currency
+	to   money.Currency
+}
+
+type cachedRate struct {
+	rate      canonical.ExchangeRate
+	expiresAt time.Time
+}
+
+type cache struct {
+	provider RateProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	rates map[pair]cachedRate
+}
+
+// NewCache returns a RateProvider that keeps the rates of provider for ttl.
+// Failures are not cached.
+func NewCache(provider RateProvider, ttl time.Duration) RateProvider {
+	return &cache{
+		provider: provider,
+		ttl:      ttl,
+		now:      time.Now,
+		rates:    map[pair]cachedRate{},
+	}
+}
+
+func (cache *cache) Rate(ctx context.Context, from, to money.Currency) (canonical.ExchangeRate, error) {
+	key := pair{from: from, to: to}
+
+	cache.mu.Lock()
+	cached, ok := cache.rates[key]
+	cache.mu.Unlock()
+
+	if ok && cache.now().Before(cached.expiresAt) {
+		return cached.rate, nil
+	}
+
+	rate, err := cache.provider.Rate(ctx, from, to)
+	if err != nil {
+		return canonical.ExchangeRate{}, err
+	}
+
+	cache.mu.Lock()
+	cache.rates[key] = cachedRate{rate: rate, expiresAt: cache.now().Add(cache.ttl)}
+	cache.mu.Unlock()
+
+	return rate, nil
+}
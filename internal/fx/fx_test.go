@@ -0,0 +1,65 @@
+package fx
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingProvider struct {
+	calls int
+}
+
+func (provider *countingProvider) Rate(_ context.Context, from, to money.Currency) (canonical.ExchangeRate, error) {
+	provider.calls++
+
+	return canonical.ExchangeRate{From: from, To: to, Rate: big.NewRat(int64(provider.calls), 1)}, nil
+}
+
+func TestCache_KeepsRatesForTTL(t *testing.T) {
+	provider := &countingProvider{}
+	now := time.Now()
+
+	cached := NewCache(provider, time.Minute).(*cache)
+	cached.now = func() time.Time { return now }
+
+	first, _ := cached.Rate(context.Background(), "BRL", "EUR")
+	second, _ := cached.Rate(context.Background(), "BRL", "EUR")
+	assert.Equal(t, 1, provider.calls)
+	assert.Equal(t, first, second)
+
+	_, _ = cached.Rate(context.Background(), "BRL", "USD")
+	assert.Equal(t, 2, provider.calls)
+
+	now = now.Add(time.Minute)
+	_, _ = cached.Rate(context.Background(), "BRL", "EUR")
+	assert.Equal(t, 3, provider.calls)
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	err := os.WriteFile(path, []byte(`{"base":"USD","as_of":"2026-10-01T00:00:00Z","rates":{"BRL":"5","EUR":"0.9"}}`), 0o600)
+	assert.Nil(t, err)
+
+	provider := NewFileProvider(path)
+
+	rate, err := provider.Rate(context.Background(), "BRL", "EUR")
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewRat(9, 50), rate.Rate)
+	assert.Equal(t, time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC), rate.AsOf)
+
+	rate, err = provider.Rate(context.Background(), "EUR", "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewRat(10, 9), rate.Rate)
+
+	_, err = provider.Rate(context.Background(), "BRL", "JPY")
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+}
@@ -0,0 +1,169 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Hook ties a component to the application lifecycle. OnStart hooks run in
+// the order they were appended and must return promptly; long running work
+// goes in Run, which is started once every OnStart hook succeeded. OnStop hooks
+// run in reverse order when the application stops.
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	Run     func() error
+	OnStop  func(ctx context.Context) error
+}
+
+type Lifecycle struct {
+	hooks       []Hook
+	gracePeriod time.Duration
+	logger      logrus.FieldLogger
+}
+
+// New returns a Lifecycle whose stop hooks share gracePeriod to finish.
+func New(gracePeriod time.Duration, logger logrus.FieldLogger) *Lifecycle {
+	return &Lifecycle{
+		gracePeriod: gracePeriod,
+		logger:      logger,
+	}
+}
+
+func (lifecycle *Lifecycle) Append(hook Hook) {
+	lifecycle.hooks = append(lifecycle.hooks, hook)
+}
+
+// Run starts every hook and blocks until ctx is done or a Run function fails,
+// then stops the started hooks within the grace period.
+func (lifecycle *Lifecycle) Run(ctx context.Context) error {
+	started, err := lifecycle.start(ctx)
+	if err != nil {
+		return errors.Join(err, lifecycle.stop(started))
+	}
+
+	failed := make(chan error, len(started))
+	for _, hook := range started {
+		if hook.Run == nil {
+			continue
+		}
+
+		go func(hook Hook) {
+			err := hook.Run()
+			if err != nil {
+				failed <- fmt.Errorf("%s: %w", hook.Name, err)
+			}
+		}(hook)
+	}
+
+	select {
+	case <-ctx.Done():
+		lifecycle.logger.Info("shutting down")
+	case err = <-failed:
+		lifecycle.logger.WithError(err).Error("a component failed, shutting down")
+	}
+
+	return errors.Join(err, lifecycle.stop(started))
+}
+
+// RunOnce starts every hook, calls fn instead of the Run functions of the
+// hooks and stops them again. It serves one-off commands that need the same
+// components as the application.
+func (lifecycle *Lifecycle) RunOnce(ctx context.Context, fn func(ctx context.Context) error) error {
+	started, err := lifecycle.start(ctx)
+	if err == nil {
+		err = fn(ctx)
+	}
+
+	return errors.Join(err, lifecycle.stop(started))
+}
+
+func (lifecycle *Lifecycle) start(ctx context.Context) ([]Hook, error) {
+	var started []Hook
+
+	for _, hook := range lifecycle.hooks {
+		if hook.OnStart != nil {
+			err := hook.OnStart(ctx)
+			if err != nil {
+				return started, fmt.Errorf("error occurred while trying to start %s: %w", hook.Name, err)
+			}
+		}
+
+		started = append(started, hook)
+	}
+
+	return started, nil
+}
+
+func (lifecycle *Lifecycle) stop(started []Hook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), lifecycle.gracePeriod)
+	defer cancel()
+
+	var errs []error
+
+	for i := len(started) - 1; i >= 0; i-- {
+		hook := started[i]
+		if hook.OnStop == nil {
+			continue
+		}
+
+		err := hook.OnStop(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error occurred while trying to stop %s: %w", hook.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// AppendPeriodic appends a hook that calls fn every interval until the
+// application stops. Errors returned by fn are logged and do not stop the
+// application.
+func (lifecycle *Lifecycle) AppendPeriodic(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	running := make(chan struct{})
+	done := make(chan struct{})
+
+	lifecycle.Append(Hook{
+		Name: name,
+		Run: func() error {
+			close(running)
+			defer close(done)
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+					err := fn(ctx)
+					if err != nil {
+						lifecycle.logger.WithError(err).Errorf("error occurred while running %s", name)
+					}
+				}
+			}
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+
+			select {
+			case <-running:
+			default:
+				return nil
+			}
+
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+}
@@ -0,0 +1,117 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun_StartsInOrderAndStopsInReverse(t *testing.T) {
+	var calls []string
+
+	lc := New(time.Second, logrus.New())
+	for _, name := range []string{"first", "second"} {
+		name := name
+		lc.Append(Hook{
+			Name: name,
+			OnStart: func(context.Context) error {
+				calls = append(calls, "start "+name)
+				return nil
+			},
+			OnStop: func(context.Context) error {
+				calls = append(calls, "stop "+name)
+				return nil
+			},
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := lc.Run(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"start first", "start second", "stop second", "stop first"}, calls)
+}
+
+func TestRun_StopsStartedHooksWhenStartFails(t *testing.T) {
+	var calls []string
+
+	lc := New(time.Second, logrus.New())
+	lc.Append(Hook{
+		Name: "first",
+		OnStop: func(context.Context) error {
+			calls = append(calls, "stop first")
+			return nil
+		},
+	})
+	lc.Append(Hook{
+		Name: "second",
+		OnStart: func(context.Context) error {
+			return errors.New("boom")
+		},
+		OnStop: func(context.Context) error {
+			calls = append(calls, "stop second")
+			return nil
+		},
+	})
+
+	err := lc.Run(context.Background())
+
+	assert.ErrorContains(t, err, "error occurred while trying to start second: boom")
+	assert.Equal(t, []string{"stop first"}, calls)
+}
+
+func TestRun_StopsWhenRunFails(t *testing.T) {
+	stopped := false
+
+	lc := New(time.Second, logrus.New())
+	lc.Append(Hook{
+		Name: "server",
+		Run: func() error {
+			return errors.New("address already in use")
+		},
+		OnStop: func(context.Context) error {
+			stopped = true
+			return nil
+		},
+	})
+
+	err := lc.Run(context.Background())
+
+	assert.ErrorContains(t, err, "server: address already in use")
+	assert.True(t, stopped)
+}
+
+func TestRunOnce_StopsAfterFn(t *testing.T) {
+	var calls []string
+
+	lc := New(time.Second, logrus.New())
+	lc.Append(Hook{
+		Name: "database",
+		OnStart: func(context.Context) error {
+			calls = append(calls, "start")
+			return nil
+		},
+		Run: func() error {
+			calls = append(calls, "run")
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			calls = append(calls, "stop")
+			return nil
+		},
+	})
+
+	err := lc.RunOnce(context.Background(), func(context.Context) error {
+		calls = append(calls, "fn")
+		return errors.New("boom")
+	})
+
+	assert.ErrorContains(t, err, "boom")
+	assert.Equal(t, []string{"start", "fn", "stop"}, calls)
+}
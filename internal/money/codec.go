@@ -0,0 +1,84 @@
+package money
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+)
+
+// Codec is a BSON codec for Money that also reads the prices written before
+// they carried a currency, which were stored as plain numbers. Those are taken
+// to be amounts in Legacy, rounded to its minor unit.
+type Codec struct {
+	Legacy Currency
+}
+
+var moneyType = reflect.TypeOf(Money{})
+
+// Register makes registry encode and decode Money with codec.
+func (codec Codec) Register(registry *bsoncodec.Registry) {
+	registry.RegisterTypeEncoder(moneyType, codec)
+	registry.RegisterTypeDecoder(moneyType, codec)
+}
+
+func (codec Codec) EncodeValue(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	if val.Type() != moneyType {
+		return bsoncodec.ValueEncoderError{Name: "money.Codec", Types: []reflect.Type{moneyType}, Received: val}
+	}
+
+	t, data, err := val.Interface().(Money).MarshalBSONValue()
+	if err != nil {
+		return err
+	}
+
+	return bsonrw.Copier{}.CopyValueFromBytes(vw, t, data)
+}
+
+func (codec Codec) DecodeValue(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	if !val.CanSet() || val.Type() != moneyType {
+		return bsoncodec.ValueDecoderError{Name: "money.Codec", Types: []reflect.Type{moneyType}, Received: val}
+	}
+
+	var money Money
+
+	switch vr.Type() {
+	case bson.TypeDouble:
+		amount, err := vr.ReadDouble()
+		if err != nil {
+			return err
+		}
+
+		money = FromFloat(amount, codec.Legacy)
+	case bson.TypeInt32:
+		amount, err := vr.ReadInt32()
+		if err != nil {
+			return err
+		}
+
+		money = FromFloat(float64(amount), codec.Legacy)
+	case bson.TypeInt64:
+		amount, err := vr.ReadInt64()
+		if err != nil {
+			return err
+		}
+
+		money = FromFloat(float64(amount), codec.Legacy)
+	default:
+		t, data, err := bsonrw.Copier{}.CopyValueToBytes(vr)
+		if err != nil {
+			return err
+		}
+
+		err = money.UnmarshalBSONValue(t, data)
+		if err != nil {
+			return fmt.Errorf("money.Codec: %w", err)
+		}
+	}
+
+	val.Set(reflect.ValueOf(money))
+
+	return nil
+}
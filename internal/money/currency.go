@@ -0,0 +1,57 @@
+package money
+
+import "fmt"
+
+// Currency is an ISO 4217 currency code, such as BRL.
+type Currency string
+
+// exponents holds the currencies the catalog knows about and the number of
+// digits of their minor unit.
+var exponents = map[Currency]int{
+	"ARS": 2,
+	"AUD": 2,
+	"BRL": 2,
+	"CAD": 2,
+	"CHF": 2,
+	"CLP": 0,
+	"CNY": 2,
+	"COP": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"INR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"MXN": 2,
+	"PYG": 0,
+	"USD": 2,
+	"UYU": 2,
+}
+
+// ParseCurrency returns the currency with code, which must be a known ISO 4217
+// code in upper case.
+func ParseCurrency(code string) (Currency, error) {
+	currency := Currency(code)
+	if !currency.Known() {
+		return "", fmt.Errorf("unknown currency %q", code)
+	}
+
+	return currency, nil
+}
+
+// Known reports whether currency is one the catalog can price in.
+func (currency Currency) Known() bool {
+	_, ok := exponents[currency]
+
+	return ok
+}
+
+// Exponent returns the number of digits of the minor unit of currency: 2 for
+// BRL, whose minor unit is the centavo, 0 for JPY.
+func (currency Currency) Exponent() int {
+	return exponents[currency]
+}
+
+func (currency Currency) String() string {
+	return string(currency)
+}
@@ -0,0 +1,262 @@
+// Package money represents amounts of money exactly, as an integer number of
+// minor units of an ISO 4217 currency.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Money is an amount in the minor unit of its currency: BRL 19.99 is
+// Money{Amount: 1999, Currency: "BRL"}. The zero value has no currency and
+// stands for a missing amount.
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+// maxDigits keeps parsed amounts within the range of an int64.
+const maxDigits = 18
+
+// Parse reads a decimal amount such as "19.99" in currency. The amount may not
+// have more fractional digits than the minor unit of currency.
+func Parse(amount string, currency Currency) (Money, error) {
+	if !currency.Known() {
+		return Money{}, fmt.Errorf("unknown currency %q", currency)
+	}
+
+	digits, negative := strings.CutPrefix(amount, "-")
+
+	whole, fraction, hasPoint := strings.Cut(digits, ".")
+	if whole == "" || (hasPoint && fraction == "") || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	exponent := currency.Exponent()
+	if len(fraction) > exponent {
+		return Money{}, fmt.Errorf("amount %q has more than %d decimal places for %s", amount, exponent, currency)
+	}
+
+	digits = whole + fraction + strings.Repeat("0", exponent-len(fraction))
+	if len(strings.TrimLeft(digits, "0")) > maxDigits {
+		return Money{}, fmt.Errorf("amount %q is too large", amount)
+	}
+
+	value, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	if negative {
+		value = -value
+	}
+
+	return Money{Amount: value, Currency: currency}, nil
+}
+
+func isDigits(text string) bool {
+	for _, r := range text {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FromFloat rounds amount to the nearest minor unit of currency. It exists to
+// read prices stored as floating point numbers before this package existed.
+func FromFloat(amount float64, currency Currency) Money {
+	return Money{
+		Amount:   int64(math.Round(amount * math.Pow10(currency.Exponent()))),
+		Currency: currency,
+	}
+}
+
+// Convert returns money in currency to at rate, the price of one major unit of
+// the currency of money in to. The result is rounded half away from zero to
+// the minor unit of to.
+func (money Money) Convert(to Currency, rate *big.Rat) Money {
+	value := new(big.Rat).SetInt64(money.Amount)
+	value.Mul(value, rate)
+	value.Mul(value, new(big.Rat).SetFrac(pow10(to.Exponent()), pow10(money.Currency.Exponent())))
+
+	return Money{Amount: round(value), Currency: to}
+}
+
+func pow10(exponent int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+}
+
+// round rounds value half away from zero.
+func round(value *big.Rat) int64 {
+	half := big.NewRat(1, 2)
+	if value.Sign() < 0 {
+		half.Neg(half)
+	}
+
+	shifted := new(big.Rat).Add(value, half)
+
+	return new(big.Int).Quo(shifted.Num(), shifted.Denom()).Int64()
+}
+
+// IsZero reports whether money is the zero value, which has no currency.
+func (money Money) IsZero() bool {
+	return money == Money{}
+}
+
+// Decimal formats the amount without its currency, such as "19.99".
+func (money Money) Decimal() string {
+	exponent := money.Currency.Exponent()
+
+	sign := ""
+	amount := money.Amount
+	if amount < 0 {
+		sign = "-"
+	}
+
+	digits := strconv.FormatUint(absolute(amount), 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	point := len(digits) - exponent
+
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+func absolute(amount int64) uint64 {
+	if amount < 0 {
+		return uint64(-(amount + 1)) + 1
+	}
+
+	return uint64(amount)
+}
+
+// String formats money as the amount followed by its currency, such as
+// "19.99 BRL".
+func (money Money) String() string {
+	if money.IsZero() {
+		return ""
+	}
+
+	return money.Decimal() + " " + money.Currency.String()
+}
+
+// Float64 returns the amount in major units. It is approximate and only meant
+// for comparisons such as the min and max validation rules.
+func (money Money) Float64() float64 {
+	return float64(money.Amount) / math.Pow10(money.Currency.Exponent())
+}
+
+// jsonMoney is the JSON form of Money. The amount is written as a decimal
+// string so that clients do not read it into a float; numbers are accepted
+// too and parsed from their literal text.
+type jsonMoney struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+func (money Money) MarshalJSON() ([]byte, error) {
+	if money.IsZero() {
+		return []byte("null"), nil
+	}
+
+	amount, err := json.Marshal(money.Decimal())
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonMoney{Amount: amount, Currency: money.Currency.String()})
+}
+
+func (money *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*money = Money{}
+		return nil
+	}
+
+	var document jsonMoney
+
+	err := json.Unmarshal(data, &document)
+	if err != nil {
+		return err
+	}
+
+	if len(document.Amount) == 0 || document.Currency == "" {
+		return errors.New("money needs an amount and a currency")
+	}
+
+	amount := string(document.Amount)
+	if strings.HasPrefix(amount, `"`) {
+		err = json.Unmarshal(document.Amount, &amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	parsed, err := Parse(amount, Currency(document.Currency))
+	if err != nil {
+		return err
+	}
+
+	*money = parsed
+
+	return nil
+}
+
+// bsonMoney is the BSON form of Money. The amount is stored in minor units so
+// that MongoDB can sort and filter on it exactly.
+type bsonMoney struct {
+	Amount   int64  `bson:"amount"`
+	Currency string `bson:"currency"`
+}
+
+func (money Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if money.IsZero() {
+		return bson.TypeNull, nil, nil
+	}
+
+	return bson.MarshalValue(bsonMoney{Amount: money.Amount, Currency: money.Currency.String()})
+}
+
+// UnmarshalBSONValue reads the document written by MarshalBSONValue. Prices
+// stored as plain numbers have no currency and are read by Codec instead.
+func (money *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	switch t {
+	case bson.TypeNull:
+		*money = Money{}
+		return nil
+	case bson.TypeEmbeddedDocument:
+	default:
+		return fmt.Errorf("cannot read money from BSON %s", t)
+	}
+
+	var document bsonMoney
+
+	err := bson.Unmarshal(data, &document)
+	if err != nil {
+		return err
+	}
+
+	currency, err := ParseCurrency(document.Currency)
+	if err != nil {
+		return err
+	}
+
+	*money = Money{Amount: document.Amount, Currency: currency}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package money
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		amount   string
+		currency Currency
+		want     Money
+	}{
+		{"19.99", "BRL", Money{Amount: 1999, Currency: "BRL"}},
+		{"19.9", "BRL", Money{Amount: 1990, Currency: "BRL"}},
+		{"19", "BRL", Money{Amount: 1900, Currency: "BRL"}},
+		{"-0.05", "USD", Money{Amount: -5, Currency: "USD"}},
+		{"1500", "JPY", Money{Amount: 1500, Currency: "JPY"}},
+		{"1.234", "KWD", Money{Amount: 1234, Currency: "KWD"}},
+	}
+
+	for _, c := range cases {
+		got, err := Parse(c.amount, c.currency)
+
+		assert.Nil(t, err, c.amount)
+		assert.Equal(t, c.want, got, c.amount)
+		assert.Equal(t, c.want.Amount, must(Parse(got.Decimal(), c.currency)).Amount, c.amount)
+	}
+}
+
+func TestParse_Rejects(t *testing.T) {
+	cases := []struct {
+		amount   string
+		currency Currency
+	}{
+		{"19.999", "BRL"},
+		{"1.5", "JPY"},
+		{"", "BRL"},
+		{"1.", "BRL"},
+		{".5", "BRL"},
+		{"1e3", "BRL"},
+		{"19.99", "XYZ"},
+		{"19.99", "brl"},
+		{"99999999999999999999", "BRL"},
+	}
+
+	for _, c := range cases {
+		_, err := Parse(c.amount, c.currency)
+
+		assert.NotNil(t, err, c.amount+" "+string(c.currency))
+	}
+}
+
+func TestFromFloat_RoundsLegacyPrices(t *testing.T) {
+	assert.Equal(t, Money{Amount: 1999, Currency: "BRL"}, FromFloat(float64(float32(19.99)), "BRL"))
+}
+
+func TestJSON(t *testing.T) {
+	data, err := json.Marshal(Money{Amount: 1999, Currency: "BRL"})
+
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"amount":"19.99","currency":"BRL"}`, string(data))
+
+	var decoded Money
+
+	assert.Nil(t, json.Unmarshal([]byte(`{"amount":19.99,"currency":"BRL"}`), &decoded))
+	assert.Equal(t, Money{Amount: 1999, Currency: "BRL"}, decoded)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"amount":"19.99","currency":"ZZZ"}`), &decoded))
+	assert.NotNil(t, json.Unmarshal([]byte(`{"amount":"19.99"}`), &decoded))
+	assert.NotNil(t, json.Unmarshal([]byte(`19.99`), &decoded))
+}
+
+func TestBSON(t *testing.T) {
+	type document struct {
+		Price Money `bson:"price"`
+	}
+
+	data, err := bson.Marshal(document{Price: Money{Amount: 1999, Currency: "BRL"}})
+	assert.Nil(t, err)
+
+	var raw struct {
+		Price struct {
+			Amount   int64  `bson:"amount"`
+			Currency string `bson:"currency"`
+		} `bson:"price"`
+	}
+	assert.Nil(t, bson.Unmarshal(data, &raw))
+	assert.Equal(t, int64(1999), raw.Price.Amount)
+	assert.Equal(t, "BRL", raw.Price.Currency)
+
+	var decoded document
+	assert.Nil(t, bson.Unmarshal(data, &decoded))
+	assert.Equal(t, Money{Amount: 1999, Currency: "BRL"}, decoded.Price)
+}
+
+func TestCodec_ReadsLegacyFloats(t *testing.T) {
+	registry := bson.NewRegistry()
+	Codec{Legacy: "BRL"}.Register(registry)
+
+	legacy, err := bson.Marshal(bson.D{{Key: "price", Value: float64(float32(19.99))}})
+	assert.Nil(t, err)
+
+	var decoded struct {
+		Price Money `bson:"price"`
+	}
+
+	assert.Nil(t, bson.UnmarshalWithRegistry(registry, legacy, &decoded))
+	assert.Equal(t, Money{Amount: 1999, Currency: "BRL"}, decoded.Price)
+
+	assert.NotNil(t, bson.Unmarshal(legacy, &decoded))
+}
+
+func must(money Money, err error) Money {
+	if err != nil {
+		panic(err)
+	}
+
+	return money
+}
+
+func TestConvert(t *testing.T) {
+	rate, _ := new(big.Rat).SetString("0.1712")
+
+	assert.Equal(t, Money{Amount: 342, Currency: "EUR"}, Money{Amount: 1999, Currency: "BRL"}.Convert("EUR", rate))
+	assert.Equal(t, Money{Amount: 3, Currency: "JPY"}, Money{Amount: 1999, Currency: "BRL"}.Convert("JPY", big.NewRat(3, 20)))
+	assert.Equal(t, Money{Amount: -342, Currency: "EUR"}, Money{Amount: -1999, Currency: "BRL"}.Convert("EUR", rate))
+}
@@ -0,0 +1,35 @@
+// Package notify sends the alerts raised by the service, such as a product
+// running low on stock, to the people who act on them.
+package notify
+
+import (
+	"context"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/sirupsen/logrus"
+)
+
+type Notifier interface {
+	NotifyLowStock(ctx context.Context, alert canonical.LowStockAlert) error
+}
+
+type logNotifier struct {
+	logger logrus.FieldLogger
+}
+
+// NewLogNotifier returns a Notifier that writes alerts to logger.
+func NewLogNotifier(logger logrus.FieldLogger) Notifier {
+	return &logNotifier{logger: logger}
+}
+
+func (notifier *logNotifier) NotifyLowStock(_ context.Context, alert canonical.LowStockAlert) error {
+	notifier.logger.WithFields(logrus.Fields{
+		"product_id": alert.ProductId,
+		"name":       alert.Name,
+		"category":   alert.Category,
+		"stock":      alert.Stock,
+		"threshold":  alert.Threshold,
+	}).Warn("product is low on stock")
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+)
+
+// lowStockEvent is the body posted to the webhook for a low-stock alert.
+type lowStockEvent struct {
+	Event     string    `json:"event"`
+	ProductId string    `json:"product_id"`
+	Name      string    `json:"name"`
+	Category  string    `json:"category"`
+	Stock     int       `json:"stock"`
+	Threshold int       `json:"threshold"`
+	RaisedAt  time.Time `json:"raised_at"`
+}
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier returns a Notifier that posts alerts as JSON to url. Any
+// response other than a 2xx is an error.
+func NewWebhookNotifier(url string, timeout time.Duration) Notifier {
+	return &webhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (notifier *webhookNotifier) NotifyLowStock(ctx context.Context, alert canonical.LowStockAlert) error {
+	return notifier.post(ctx, lowStockEvent{
+		Event:     "low_stock",
+		ProductId: alert.ProductId,
+		Name:      alert.Name,
+		Category:  alert.Category,
+		Stock:     alert.Stock,
+		Threshold: alert.Threshold,
+		RaisedAt:  alert.CreatedAt,
+	})
+}
+
+func (notifier *webhookNotifier) post(ctx context.Context, event any) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errs.Internal(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, notifier.url, bytes.NewReader(body))
+	if err != nil {
+		return errs.Internal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := notifier.client.Do(req)
+	if err != nil {
+		return errs.Internal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return errs.Internal(fmt.Errorf("webhook %s responded with %s", notifier.url, res.Status))
+	}
+
+	return nil
+}
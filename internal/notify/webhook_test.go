@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookNotifier_PostsAlert(t *testing.T) {
+	var event lowStockEvent
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&event))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, time.Second)
+
+	err := notifier.NotifyLowStock(context.Background(), canonical.LowStockAlert{ProductId: "xpto", Name: "test", Stock: 2, Threshold: 5})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "low_stock", event.Event)
+	assert.Equal(t, "xpto", event.ProductId)
+	assert.Equal(t, 2, event.Stock)
+	assert.Equal(t, 5, event.Threshold)
+}
+
+func TestWebhookNotifier_FailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL, time.Second)
+
+	err := notifier.NotifyLowStock(context.Background(), canonical.LowStockAlert{ProductId: "xpto"})
+
+	assert.ErrorContains(t, err, "502 Bad Gateway")
+}
@@ -0,0 +1,324 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AlertRepository stores the reorder thresholds of categories and the open
+// low-stock alerts, one per product.
+type AlertRepository interface {
+	// SetCategoryThreshold sets the reorder threshold of category; zero
+	// removes it.
+	SetCategoryThreshold(ctx context.Context, category string, threshold int) error
+	// GetCategoryThreshold returns the reorder threshold of category, or zero
+	// if it has none.
+	GetCategoryThreshold(ctx context.Context, category string) (int, error)
+	// OpenLowStockAlert opens alert unless its product already has an open
+	// one, in which case only the stock and threshold of that one are
+	// updated. It reports whether the alert was opened.
+	OpenLowStockAlert(ctx context.Context, alert canonical.LowStockAlert) (bool, error)
+	// CloseLowStockAlert closes the alert of a product, if it has one.
+	CloseLowStockAlert(ctx context.Context, productId string) error
+	// GetUnsentLowStockAlerts returns up to limit open alerts that have not
+	// been sent yet, oldest first.
+	GetUnsentLowStockAlerts(ctx context.Context, limit int) ([]canonical.LowStockAlert, error)
+	// MarkLowStockAlertSent records that alert was sent at sentAt. An alert
+	// closed, or closed and opened again, since it was read is left alone.
+	MarkLowStockAlertSent(ctx context.Context, alert canonical.LowStockAlert, sentAt time.Time) error
+	GetLowStockAlerts(ctx context.Context, page canonical.PageRequest) (canonical.LowStockAlertPage, error)
+}
+
+func newAlertPage(alerts []canonical.LowStockAlert, limit int) canonical.LowStockAlertPage {
+	if len(alerts) <= limit {
+		return canonical.LowStockAlertPage{Alerts: alerts}
+	}
+
+	alerts = alerts[:limit]
+
+	return canonical.LowStockAlertPage{
+		Alerts:     alerts,
+		NextCursor: encodeIdCursor(alerts[len(alerts)-1].ProductId),
+		HasMore:    true,
+	}
+}
+
+func (repo *repository) SetCategoryThreshold(ctx context.Context, category string, threshold int) error {
+	filter := bson.D{{Key: "_id", Value: category}}
+
+	var err error
+	if threshold == 0 {
+		_, err = repo.thresholds.DeleteOne(ctx, filter)
+	} else {
+		opts := options.Replace().SetUpsert(true)
+		_, err = repo.thresholds.ReplaceOne(ctx, filter, canonical.CategoryThreshold{Category: category, Threshold: threshold}, opts)
+	}
+
+	if err != nil {
+		return errs.Internal(err)
+	}
+
+	return nil
+}
+
+func (repo *repository) GetCategoryThreshold(ctx context.Context, category string) (int, error) {
+	var threshold canonical.CategoryThreshold
+
+	err := repo.thresholds.FindOne(ctx, bson.D{{Key: "_id", Value: category}}).Decode(&threshold)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, errs.Internal(err)
+	}
+
+	return threshold.Threshold, nil
+}
+
+func (repo *repository) OpenLowStockAlert(ctx context.Context, alert canonical.LowStockAlert) (bool, error) {
+	update := bson.M{
+		"$set": bson.M{"stock": alert.Stock, "threshold": alert.Threshold},
+		"$setOnInsert": bson.M{
+			"name":       alert.Name,
+			"category":   alert.Category,
+			"created_at": alert.CreatedAt,
+		},
+	}
+
+	opts := options.Update().SetUpsert(true)
+
+	res, err := repo.alerts.UpdateOne(ctx, bson.D{{Key: "_id", Value: alert.ProductId}}, update, opts)
+	if err != nil {
+		return false, errs.Internal(err)
+	}
+
+	return res.UpsertedCount > 0, nil
+}
+
+func (repo *repository) CloseLowStockAlert(ctx context.Context, productId string) error {
+	_, err := repo.alerts.DeleteOne(ctx, bson.D{{Key: "_id", Value: productId}})
+	if err != nil {
+		return errs.Internal(err)
+	}
+
+	return nil
+}
+
+func (repo *repository) GetUnsentLowStockAlerts(ctx context.Context, limit int) ([]canonical.LowStockAlert, error) {
+	filter := bson.D{{Key: "notified_at", Value: bson.D{{Key: "$exists", Value: false}}}}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+
+	res, err := repo.alerts.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	alerts := []canonical.LowStockAlert{}
+
+	err = res.All(ctx, &alerts)
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	return alerts, nil
+}
+
+func (repo *repository) MarkLowStockAlertSent(ctx context.Context, alert canonical.LowStockAlert, sentAt time.Time) error {
+	filter := bson.D{{Key: "_id", Value: alert.ProductId}, {Key: "created_at", Value: alert.CreatedAt}}
+
+	_, err := repo.alerts.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"notified_at": sentAt}})
+	if err != nil {
+		return errs.Internal(err)
+	}
+
+	return nil
+}
+
+func (repo *repository) GetLowStockAlerts(ctx context.Context, page canonical.PageRequest) (canonical.LowStockAlertPage, error) {
+	filter := bson.D{}
+
+	if page.Cursor != "" {
+		last, err := decodeIdCursor(page.Cursor)
+		if err != nil {
+			return canonical.LowStockAlertPage{}, err
+		}
+
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$gt", Value: last}}})
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(int64(page.Limit + 1))
+
+	res, err := repo.alerts.Find(ctx, filter, opts)
+	if err != nil {
+		return canonical.LowStockAlertPage{}, errs.Internal(err)
+	}
+
+	alerts := []canonical.LowStockAlert{}
+
+	err = res.All(ctx, &alerts)
+	if err != nil {
+		return canonical.LowStockAlertPage{}, errs.Internal(err)
+	}
+
+	return newAlertPage(alerts, page.Limit), nil
+}
+
+func (repo *memoryRepository) SetCategoryThreshold(ctx context.Context, category string, threshold int) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if threshold == 0 {
+		delete(repo.thresholds, category)
+	} else {
+		repo.thresholds[category] = threshold
+	}
+
+	return nil
+}
+
+func (repo *memoryRepository) GetCategoryThreshold(ctx context.Context, category string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, errs.Internal(err)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.thresholds[category], nil
+}
+
+func (repo *memoryRepository) OpenLowStockAlert(ctx context.Context, alert canonical.LowStockAlert) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	open, ok := repo.alerts[alert.ProductId]
+	if ok {
+		open.Stock = alert.Stock
+		open.Threshold = alert.Threshold
+		repo.alerts[alert.ProductId] = open
+
+		return false, nil
+	}
+
+	repo.alerts[alert.ProductId] = alert
+
+	return true, nil
+}
+
+func (repo *memoryRepository) CloseLowStockAlert(ctx context.Context, productId string) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.alerts, productId)
+
+	return nil
+}
+
+func (repo *memoryRepository) GetUnsentLowStockAlerts(ctx context.Context, limit int) ([]canonical.LowStockAlert, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	repo.mu.RLock()
+	alerts := []canonical.LowStockAlert{}
+	for _, alert := range repo.alerts {
+		if alert.NotifiedAt == nil {
+			alerts = append(alerts, alert)
+		}
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].CreatedAt.Equal(alerts[j].CreatedAt) {
+			return alerts[i].CreatedAt.Before(alerts[j].CreatedAt)
+		}
+
+		return alerts[i].ProductId < alerts[j].ProductId
+	})
+
+	if len(alerts) > limit {
+		alerts = alerts[:limit]
+	}
+
+	return alerts, nil
+}
+
+func (repo *memoryRepository) MarkLowStockAlertSent(ctx context.Context, alert canonical.LowStockAlert, sentAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	open, ok := repo.alerts[alert.ProductId]
+	if !ok || !open.CreatedAt.Equal(alert.CreatedAt) {
+		return nil
+	}
+
+	open.NotifiedAt = &sentAt
+	repo.alerts[alert.ProductId] = open
+
+	return nil
+}
+
+func (repo *memoryRepository) GetLowStockAlerts(ctx context.Context, page canonical.PageRequest) (canonical.LowStockAlertPage, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.LowStockAlertPage{}, errs.Internal(err)
+	}
+
+	var last string
+	if page.Cursor != "" {
+		var err error
+
+		last, err = decodeIdCursor(page.Cursor)
+		if err != nil {
+			return canonical.LowStockAlertPage{}, err
+		}
+	}
+
+	repo.mu.RLock()
+	alerts := []canonical.LowStockAlert{}
+	for _, alert := range repo.alerts {
+		if alert.ProductId > last {
+			alerts = append(alerts, alert)
+		}
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].ProductId < alerts[j].ProductId
+	})
+
+	if len(alerts) > page.Limit+1 {
+		alerts = alerts[:page.Limit+1]
+	}
+
+	return newAlertPage(alerts, page.Limit), nil
+}
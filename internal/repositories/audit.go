@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditRepository stores the audit log. Entries are only ever appended; their
+// ids are UUIDv7, so ordering by id orders them by time.
+type AuditRepository interface {
+	AppendAuditEntry(ctx context.Context, entry canonical.AuditEntry) error
+	GetAuditEntries(ctx context.Context, productId string, page canonical.PageRequest) (canonical.AuditPage, error)
+}
+
+// Id cursors carry the id of the last entry of a page of an append-only log
+// with UUIDv7 ids. Logs are listed newest first, so the next page starts below
+// that id.
+func encodeIdCursor(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+func decodeIdCursor(cursor string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(data) == 0 {
+		return "", errs.Validation(fmt.Sprintf("invalid cursor %q", cursor))
+	}
+
+	return string(data), nil
+}
+
+// newAuditPage trims a result set fetched with limit+1 entries down to the page
+// size and builds the cursor for the next page.
+func newAuditPage(entries []canonical.AuditEntry, limit int) canonical.AuditPage {
+	if len(entries) <= limit {
+		return canonical.AuditPage{Entries: entries}
+	}
+
+	entries = entries[:limit]
+
+	return canonical.AuditPage{
+		Entries:    entries,
+		NextCursor: encodeIdCursor(entries[len(entries)-1].Id),
+		HasMore:    true,
+	}
+}
+
+func (repo *repository) AppendAuditEntry(ctx context.Context, entry canonical.AuditEntry) error {
+	_, err := repo.audit.InsertOne(ctx, entry)
+	if err != nil {
+		return translateError(err, entry.Id)
+	}
+
+	return nil
+}
+
+func (repo *repository) GetAuditEntries(ctx context.Context, productId string, page canonical.PageRequest) (canonical.AuditPage, error) {
+	filter := bson.D{{Key: "product_id", Value: productId}}
+
+	if page.Cursor != "" {
+		last, err := decodeIdCursor(page.Cursor)
+		if err != nil {
+			return canonical.AuditPage{}, err
+		}
+
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$lt", Value: last}}})
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(int64(page.Limit + 1))
+
+	res, err := repo.audit.Find(ctx, filter, opts)
+	if err != nil {
+		return canonical.AuditPage{}, errs.Internal(err)
+	}
+
+	entries := []canonical.AuditEntry{}
+
+	err = res.All(ctx, &entries)
+	if err != nil {
+		return canonical.AuditPage{}, errs.Internal(err)
+	}
+
+	return newAuditPage(entries, page.Limit), nil
+}
+
+func (repo *memoryRepository) AppendAuditEntry(ctx context.Context, entry canonical.AuditEntry) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.audit = append(repo.audit, entry)
+
+	return nil
+}
+
+func (repo *memoryRepository) GetAuditEntries(ctx context.Context, productId string, page canonical.PageRequest) (canonical.AuditPage, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.AuditPage{}, errs.Internal(err)
+	}
+
+	var last string
+	if page.Cursor != "" {
+		var err error
+
+		last, err = decodeIdCursor(page.Cursor)
+		if err != nil {
+			return canonical.AuditPage{}, err
+		}
+	}
+
+	repo.mu.RLock()
+	entries := []canonical.AuditEntry{}
+	for _, entry := range repo.audit {
+		if entry.ProductId == productId && (last == "" || entry.Id < last) {
+			entries = append(entries, entry)
+		}
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Id > entries[j].Id
+	})
+
+	if len(entries) > page.Limit+1 {
+		entries = entries[:page.Limit+1]
+	}
+
+	return newAuditPage(entries, page.Limit), nil
+}
@@ -0,0 +1,298 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CategoryRepository stores the category tree. Writes are versioned like
+// product writes.
+type CategoryRepository interface {
+	CreateCategory(ctx context.Context, category canonical.Category) (canonical.Category, error)
+	GetCategory(ctx context.Context, slug string) (canonical.Category, error)
+	// GetCategories returns every category in tree order, that is sorted by
+	// path.
+	GetCategories(ctx context.Context) ([]canonical.Category, error)
+	// GetCategorySubtree returns the category at path and all of its
+	// descendants in tree order.
+	GetCategorySubtree(ctx context.Context, path string) ([]canonical.Category, error)
+	// UpdateCategory stores the name, parent, path and attribute schema of
+	// category. When the path changes, the paths of its descendants are moved
+	// along with it.
+	UpdateCategory(ctx context.Context, slug string, version int64, category canonical.Category) (canonical.Category, error)
+	DeleteCategory(ctx context.Context, slug string, version int64) error
+}
+
+func categoryError(err error, slug string) error {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return errs.NotFound("category " + slug + " not found")
+	case mongo.IsDuplicateKeyError(err):
+		return errs.Conflict("category " + slug + " already exists")
+	default:
+		return errs.Internal(err)
+	}
+}
+
+func categoryVersionConflict(slug string, version int64) error {
+	return errs.VersionConflict(fmt.Sprintf("category %s is no longer at version %d", slug, version))
+}
+
+func subtreeFilter(path string) bson.D {
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "path", Value: path}},
+		bson.D{{Key: "path", Value: bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(path+"/")}}}},
+	}}}
+}
+
+func (repo *repository) CreateCategory(ctx context.Context, category canonical.Category) (canonical.Category, error) {
+	_, err := repo.categories.InsertOne(ctx, category)
+	if err != nil {
+		return canonical.Category{}, categoryError(err, category.Slug)
+	}
+
+	return category, nil
+}
+
+func (repo *repository) GetCategory(ctx context.Context, slug string) (canonical.Category, error) {
+	var category canonical.Category
+
+	err := repo.categories.FindOne(ctx, bson.D{{Key: "_id", Value: slug}}).Decode(&category)
+	if err != nil {
+		return canonical.Category{}, categoryError(err, slug)
+	}
+
+	return category, nil
+}
+
+func (repo *repository) GetCategories(ctx context.Context) ([]canonical.Category, error) {
+	return repo.findCategories(ctx, bson.D{})
+}
+
+func (repo *repository) GetCategorySubtree(ctx context.Context, path string) ([]canonical.Category, error) {
+	return repo.findCategories(ctx, subtreeFilter(path))
+}
+
+func (repo *repository) findCategories(ctx context.Context, filter bson.D) ([]canonical.Category, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "path", Value: 1}})
+
+	res, err := repo.categories.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	categories := []canonical.Category{}
+
+	err = res.All(ctx, &categories)
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	return categories, nil
+}
+
+func (repo *repository) UpdateCategory(ctx context.Context, slug string, version int64, category canonical.Category) (canonical.Category, error) {
+	filter := bson.D{{Key: "_id", Value: slug}, {Key: "version", Value: version}}
+
+	update := bson.M{
+		"$set": bson.M{
+			"name":       category.Name,
+			"parent":     category.Parent,
+			"path":       category.Path,
+			"attributes": category.Attributes,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	var before canonical.Category
+
+	err := repo.categories.FindOneAndUpdate(ctx, filter, update).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return canonical.Category{}, repo.missingOrStaleCategory(ctx, slug, version)
+	}
+
+	if err != nil {
+		return canonical.Category{}, categoryError(err, slug)
+	}
+
+	if before.Path != category.Path {
+		// Descendants keep the part of their path below the moved category.
+		move := mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "path", Value: bson.D{{Key: "$concat", Value: bson.A{
+			category.Path,
+			bson.D{{Key: "$substrCP", Value: bson.A{"$path", len([]rune(before.Path)), bson.D{{Key: "$strLenCP", Value: "$path"}}}}},
+		}}}}}}}}
+
+		descendants := bson.D{{Key: "path", Value: bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(before.Path+"/")}}}}
+
+		_, err = repo.categories.UpdateMany(ctx, descendants, move)
+		if err != nil {
+			return canonical.Category{}, errs.Internal(err)
+		}
+	}
+
+	return repo.GetCategory(ctx, slug)
+}
+
+func (repo *repository) DeleteCategory(ctx context.Context, slug string, version int64) error {
+	res, err := repo.categories.DeleteOne(ctx, bson.D{{Key: "_id", Value: slug}, {Key: "version", Value: version}})
+	if err != nil {
+		return categoryError(err, slug)
+	}
+
+	if res.DeletedCount == 0 {
+		return repo.missingOrStaleCategory(ctx, slug, version)
+	}
+
+	return nil
+}
+
+// missingOrStaleCategory tells apart a versioned write that matched nothing
+// because the category does not exist from one that lost a race.
+func (repo *repository) missingOrStaleCategory(ctx context.Context, slug string, version int64) error {
+	count, err := repo.categories.CountDocuments(ctx, bson.D{{Key: "_id", Value: slug}})
+	if err != nil {
+		return errs.Internal(err)
+	}
+
+	if count == 0 {
+		return categoryError(mongo.ErrNoDocuments, slug)
+	}
+
+	return categoryVersionConflict(slug, version)
+}
+
+func (repo *memoryRepository) CreateCategory(ctx context.Context, category canonical.Category) (canonical.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Category{}, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.categories[category.Slug]; ok {
+		return canonical.Category{}, errs.Conflict("category " + category.Slug + " already exists")
+	}
+
+	repo.categories[category.Slug] = category
+
+	return category, nil
+}
+
+func (repo *memoryRepository) GetCategory(ctx context.Context, slug string) (canonical.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Category{}, errs.Internal(err)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	category, ok := repo.categories[slug]
+	if !ok {
+		return canonical.Category{}, errs.NotFound("category " + slug + " not found")
+	}
+
+	return category, nil
+}
+
+func (repo *memoryRepository) GetCategories(ctx context.Context) ([]canonical.Category, error) {
+	return repo.findCategories(ctx, func(canonical.Category) bool { return true })
+}
+
+func (repo *memoryRepository) GetCategorySubtree(ctx context.Context, path string) ([]canonical.Category, error) {
+	return repo.findCategories(ctx, func(category canonical.Category) bool {
+		return canonical.InSubtree(category.Path, path)
+	})
+}
+
+func (repo *memoryRepository) findCategories(ctx context.Context, match func(canonical.Category) bool) ([]canonical.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	repo.mu.RLock()
+	categories := []canonical.Category{}
+	for _, category := range repo.categories {
+		if match(category) {
+			categories = append(categories, category)
+		}
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Path < categories[j].Path
+	})
+
+	return categories, nil
+}
+
+func (repo *memoryRepository) UpdateCategory(ctx context.Context, slug string, version int64, category canonical.Category) (canonical.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Category{}, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, err := repo.getCategoryForWrite(slug, version)
+	if err != nil {
+		return canonical.Category{}, err
+	}
+
+	if stored.Path != category.Path {
+		for key, descendant := range repo.categories {
+			if key != slug && canonical.InSubtree(descendant.Path, stored.Path) {
+				descendant.Path = category.Path + descendant.Path[len(stored.Path):]
+				repo.categories[key] = descendant
+			}
+		}
+	}
+
+	stored.Name = category.Name
+	stored.Parent = category.Parent
+	stored.Path = category.Path
+	stored.Attributes = category.Attributes
+	stored.Version++
+	repo.categories[slug] = stored
+
+	return stored, nil
+}
+
+func (repo *memoryRepository) DeleteCategory(ctx context.Context, slug string, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	_, err := repo.getCategoryForWrite(slug, version)
+	if err != nil {
+		return err
+	}
+
+	delete(repo.categories, slug)
+
+	return nil
+}
+
+func (repo *memoryRepository) getCategoryForWrite(slug string, version int64) (canonical.Category, error) {
+	stored, ok := repo.categories[slug]
+	if !ok {
+		return canonical.Category{}, errs.NotFound("category " + slug + " not found")
+	}
+
+	if stored.Version != version {
+		return canonical.Category{}, categoryVersionConflict(slug, version)
+	}
+
+	return stored, nil
+}
@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// keyset is the position of the last product of a page: the values it holds
+// for every sort field, followed by its _id, which breaks ties. Listings
+// resume after that position, so pages stay stable while products are added.
+type keyset struct {
+	Sort   string `bson:"s"`
+	Values bson.A `bson:"v"`
+	Id     string `bson:"id"`
+}
+
+// Cursors are opaque to clients: they carry a BSON encoded keyset, which keeps
+// the type of each sort value.
+func encodeKeyset(sort []canonical.SortField, product canonical.Product) string {
+	position := keyset{
+		Sort:   formatSort(sort),
+		Values: sortValues(product, sort),
+		Id:     product.Id,
+	}
+
+	data, err := bson.Marshal(position)
+	if err != nil {
+		panic(fmt.Sprintf("repositories: encoding cursor: %v", err))
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeKeyset returns the position encoded in cursor, or nil for the first
+// page. A cursor is only valid for the sort order it was issued for.
+func decodeKeyset(cursor string, sort []canonical.SortField) (*keyset, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errs.BadRequest(fmt.Sprintf("invalid cursor %q", cursor))
+	}
+
+	var position keyset
+
+	err = bson.Unmarshal(data, &position)
+	if err != nil || len(position.Values) != len(sort) {
+		return nil, errs.BadRequest(fmt.Sprintf("invalid cursor %q", cursor))
+	}
+
+	if position.Sort != formatSort(sort) {
+		return nil, errs.BadRequest("cursor was issued for a different sort order")
+	}
+
+	return &position, nil
+}
+
+// sortValue returns the value of field in product, typed as MongoDB stores it.
+func sortValue(product canonical.Product, field string) any {
+	switch field {
+	case canonical.FieldName:
+		return product.Name
+	case canonical.FieldCategory:
+		return product.Category
+	case canonical.FieldPrice:
+		return product.Price.Amount
+	case canonical.FieldStock:
+		return int64(product.Stock)
+	case canonical.FieldCreatedAt:
+		return product.CreatedAt
+	default:
+		panic(fmt.Sprintf("repositories: unknown sort field %q", field))
+	}
+}
+
+func formatSort(sort []canonical.SortField) string {
+	fields := make([]string, 0, len(sort))
+	for _, field := range sort {
+		if field.Descending {
+			fields = append(fields, "-"+field.Field)
+		} else {
+			fields = append(fields, field.Field)
+		}
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// newPage trims a result set fetched with limit+1 documents down to the page
+// size and builds the cursor for the next page.
+func newPage(productSlice []canonical.Product, limit int, sort []canonical.SortField) canonical.ProductPage {
+	if len(productSlice) <= limit {
+		return canonical.ProductPage{Products: productSlice}
+	}
+
+	productSlice = productSlice[:limit]
+
+	return canonical.ProductPage{
+		Products:   productSlice,
+		NextCursor: encodeKeyset(sort, productSlice[len(productSlice)-1]),
+		HasMore:    true,
+	}
+}
+
+// Search results are ordered by relevance, which is not a stable key, so their
+// cursors carry the offset of the next page instead.
+func encodeOffsetCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeOffsetCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errs.BadRequest(fmt.Sprintf("invalid cursor %q", cursor))
+	}
+
+	offset, err := strconv.Atoi(string(data))
+	if err != nil || offset < 0 {
+		return 0, errs.BadRequest(fmt.Sprintf("invalid cursor %q", cursor))
+	}
+
+	return offset, nil
+}
+
+// newSearchPage trims a result set fetched with limit+1 documents starting at
+// offset down to the page size and builds the cursor for the next page.
+func newSearchPage(results []canonical.SearchResult, offset, limit int) canonical.SearchPage {
+	if len(results) <= limit {
+		return canonical.SearchPage{Results: results}
+	}
+
+	return canonical.SearchPage{
+		Results:    results[:limit],
+		NextCursor: encodeOffsetCursor(offset + limit),
+		HasMore:    true,
+	}
+}
+
+// compareValues orders two sort values of the same field. Values decoded from
+// a cursor are normalized first; times are compared at the millisecond
+// precision MongoDB stores them with.
+func compareValues(a, b any) int {
+	a, b = normalizeValue(a), normalizeValue(b)
+
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case time.Time:
+		return cmp.Compare(a.UnixMilli(), b.(time.Time).UnixMilli())
+	default:
+		panic(fmt.Sprintf("repositories: cannot compare %T", a))
+	}
+}
+
+func normalizeValue(value any) any {
+	switch value := value.(type) {
+	case int32:
+		return int64(value)
+	case interface{ Time() time.Time }:
+		return value.Time()
+	default:
+		return value
+	}
+}
@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// translateError maps driver errors to the kinds the other layers understand.
+func translateError(err error, id string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return errs.NotFound("product " + id + " not found")
+	case mongo.IsDuplicateKeyError(err):
+		return errs.Conflict("product " + id + " already exists")
+	default:
+		return errs.Internal(err)
+	}
+}
+
+func skuConflict(sku string) error {
+	return errs.Conflict("sku " + sku + " already exists")
+}
+
+func versionConflict(id string, version int64) error {
+	return errs.VersionConflict(fmt.Sprintf("product %s is no longer at version %d", id, version))
+}
@@ -0,0 +1,427 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+)
+
+type memoryRepository struct {
+	mu       sync.RWMutex
+	products map[string]canonical.Product
+	audit    []canonical.AuditEntry
+	prices   map[string]canonical.PriceChange
+
+	reservations map[string]canonical.Reservation
+	movements    []canonical.StockMovement
+
+	thresholds map[string]int
+	alerts     map[string]canonical.LowStockAlert
+	categories map[string]canonical.Category
+}
+
+// NewMemory returns a Repository that keeps products in process memory. It
+// behaves like the MongoDB repository and is meant for local runs and tests.
+func NewMemory() Repository {
+	return &memoryRepository{
+		products: map[string]canonical.Product{},
+		prices:   map[string]canonical.PriceChange{},
+
+		reservations: map[string]canonical.Reservation{},
+
+		thresholds: map[string]int{},
+		alerts:     map[string]canonical.LowStockAlert{},
+		categories: map[string]canonical.Category{},
+	}
+}
+
+func (repo *memoryRepository) GetAllProducts(ctx context.Context, query canonical.ProductQuery, page canonical.PageRequest) (canonical.ProductPage, error) {
+	return repo.findPage(ctx, query, page)
+}
+
+func (repo *memoryRepository) GetProductsByCategory(ctx context.Context, categories, statuses []string, page canonical.PageRequest) (canonical.ProductPage, error) {
+	return repo.findPage(ctx, canonical.ProductQuery{Categories: categories, Statuses: statuses, ParentsOnly: true}, page)
+}
+
+func (repo *memoryRepository) findPage(ctx context.Context, query canonical.ProductQuery, page canonical.PageRequest) (canonical.ProductPage, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.ProductPage{}, errs.Internal(err)
+	}
+
+	position, err := decodeKeyset(page.Cursor, query.Sort)
+	if err != nil {
+		return canonical.ProductPage{}, err
+	}
+
+	scope := visibility.FromContext(ctx)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	productSlice := []canonical.Product{}
+	for _, product := range repo.products {
+		if !matchesQuery(product, query) || !query.Deleted && !published(scope, product) {
+			continue
+		}
+
+		if position != nil && compareToKeyset(product, query.Sort, position) <= 0 {
+			continue
+		}
+
+		productSlice = append(productSlice, product)
+	}
+
+	sort.Slice(productSlice, func(i, j int) bool {
+		return compareProducts(productSlice[i], productSlice[j], query.Sort) < 0
+	})
+
+	if len(productSlice) > page.Limit+1 {
+		productSlice = productSlice[:page.Limit+1]
+	}
+
+	return newPage(productSlice, page.Limit, query.Sort), nil
+}
+
+func matchesQuery(product canonical.Product, query canonical.ProductQuery) bool {
+	switch {
+	case query.Deleted != (product.DeletedAt != nil):
+		return false
+	case query.Category != "" && product.Category != query.Category:
+		return false
+	case query.Categories != nil && !slices.Contains(query.Categories, product.Category):
+		return false
+	case query.Statuses != nil && !slices.Contains(query.Statuses, product.Status):
+		return false
+	case query.ParentId != "" && product.ParentId != query.ParentId:
+		return false
+	case query.ParentIds != nil && !slices.Contains(query.ParentIds, product.ParentId):
+		return false
+	case query.ParentsOnly && product.ParentId != "":
+		return false
+	case query.SKU != "" && product.SKU != query.SKU:
+		return false
+	case !matchesAttributes(product, query.Attributes):
+		return false
+	case query.NamePrefix != "" && !strings.HasPrefix(product.Name, query.NamePrefix):
+		return false
+	case query.PriceMin != nil && (product.Price.Currency != query.PriceMin.Currency || product.Price.Amount < query.PriceMin.Amount):
+		return false
+	case query.PriceMax != nil && (product.Price.Currency != query.PriceMax.Currency || product.Price.Amount > query.PriceMax.Amount):
+		return false
+	case query.StockLt != nil && product.Stock >= *query.StockLt:
+		return false
+	case query.CreatedAfter != nil && !product.CreatedAt.After(*query.CreatedAfter):
+		return false
+	default:
+		return true
+	}
+}
+
+func matchesAttributes(product canonical.Product, filters []canonical.AttributeFilter) bool {
+	for _, filter := range filters {
+		value, ok := product.Attributes[filter.Name]
+		if !ok || !slices.ContainsFunc(filter.Values, func(candidate any) bool { return canonical.AttributeEquals(value, candidate) }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func compareProducts(a, b canonical.Product, sort []canonical.SortField) int {
+	return compareToKeyset(a, sort, &keyset{Values: sortValues(b, sort), Id: b.Id})
+}
+
+// compareToKeyset orders product against position the way sortDocument orders
+// documents in MongoDB.
+func compareToKeyset(product canonical.Product, sort []canonical.SortField, position *keyset) int {
+	for i, field := range sort {
+		result := compareValues(sortValue(product, field.Field), position.Values[i])
+		if field.Descending {
+			result = -result
+		}
+
+		if result != 0 {
+			return result
+		}
+	}
+
+	return strings.Compare(product.Id, position.Id)
+}
+
+func sortValues(product canonical.Product, sort []canonical.SortField) []any {
+	values := make([]any, 0, len(sort))
+	for _, field := range sort {
+		values = append(values, sortValue(product, field.Field))
+	}
+
+	return values
+}
+
+func (repo *memoryRepository) GetProductById(ctx context.Context, id string) (canonical.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Product{}, errs.Internal(err)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	product, ok := repo.products[id]
+	if !ok || product.DeletedAt != nil || !published(visibility.FromContext(ctx), product) {
+		return canonical.Product{}, errs.NotFound("product " + id + " not found")
+	}
+
+	return product, nil
+}
+
+func (repo *memoryRepository) GetDeletedProductById(ctx context.Context, id string) (canonical.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Product{}, errs.Internal(err)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	product, ok := repo.products[id]
+	if !ok || product.DeletedAt == nil {
+		return canonical.Product{}, errs.NotFound("product " + id + " not found")
+	}
+
+	return product, nil
+}
+
+func (repo *memoryRepository) CreateProduct(ctx context.Context, product canonical.Product) (canonical.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Product{}, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.products[product.Id]; ok {
+		return canonical.Product{}, errs.Conflict("product " + product.Id + " already exists")
+	}
+
+	if product.SKU != "" {
+		for _, stored := range repo.products {
+			if stored.SKU == product.SKU {
+				return canonical.Product{}, skuConflict(product.SKU)
+			}
+		}
+	}
+
+	repo.products[product.Id] = product
+
+	return product, nil
+}
+
+func (repo *memoryRepository) UpdateProduct(ctx context.Context, id string, version int64, product canonical.Product) (canonical.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Product{}, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, err := repo.getForWrite(id, version, false)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	stored.Name = product.Name
+	stored.Category = product.Category
+	stored.Price = product.Price
+	stored.Stock = product.Stock
+	stored.PriceOverrides = product.PriceOverrides
+	stored.StockLevels = product.StockLevels
+	stored.Backorders = product.Backorders
+	stored.InheritsPrice = product.InheritsPrice
+	stored.ReorderThreshold = product.ReorderThreshold
+	stored.Attributes = product.Attributes
+	stored.PublishAt = product.PublishAt
+	stored.UnpublishAt = product.UnpublishAt
+	stored.Version++
+	repo.products[id] = stored
+
+	return stored, nil
+}
+
+func (repo *memoryRepository) PatchProduct(ctx context.Context, id string, version int64, patch canonical.ProductPatch) (canonical.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Product{}, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, err := repo.getForWrite(id, version, false)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	stored = patch.Apply(stored)
+	stored.Version++
+	repo.products[id] = stored
+
+	return stored, nil
+}
+
+func (repo *memoryRepository) DeleteProduct(ctx context.Context, id string, version int64, deletedAt time.Time, deletedBy string) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, err := repo.getForWrite(id, version, false)
+	if err != nil {
+		return err
+	}
+
+	stored.DeletedAt = &deletedAt
+	stored.DeletedBy = deletedBy
+	stored.Version++
+	repo.products[id] = stored
+
+	return nil
+}
+
+func (repo *memoryRepository) RestoreProduct(ctx context.Context, id string, version int64) (canonical.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Product{}, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, err := repo.getForWrite(id, version, true)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	stored.DeletedAt = nil
+	stored.DeletedBy = ""
+	stored.Version++
+	repo.products[id] = stored
+
+	return stored, nil
+}
+
+func (repo *memoryRepository) PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	ids := []string{}
+	for id, product := range repo.products {
+		if product.DeletedAt != nil && !product.DeletedAt.After(deletedBefore) {
+			delete(repo.products, id)
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// getForWrite returns the stored product if it is still at version and, as
+// requested by inTrash, either live or in the trash. Callers must hold the
+// write lock.
+func (repo *memoryRepository) getForWrite(id string, version int64, inTrash bool) (canonical.Product, error) {
+	stored, ok := repo.products[id]
+	if !ok || (stored.DeletedAt != nil) != inTrash {
+		return canonical.Product{}, errs.NotFound("product " + id + " not found")
+	}
+
+	if stored.Version != version {
+		return canonical.Product{}, versionConflict(id, version)
+	}
+
+	return stored, nil
+}
+
+// SearchProducts scores products by how many query terms appear in their name
+// and category, an approximation of the MongoDB text search.
+func (repo *memoryRepository) SearchProducts(ctx context.Context, query string, statuses []string, page canonical.PageRequest) (canonical.SearchPage, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.SearchPage{}, errs.Internal(err)
+	}
+
+	offset, err := decodeOffsetCursor(page.Cursor)
+	if err != nil {
+		return canonical.SearchPage{}, err
+	}
+
+	terms := tokenize(query)
+	scope := visibility.FromContext(ctx)
+
+	repo.mu.RLock()
+	results := []canonical.SearchResult{}
+	for _, product := range repo.products {
+		if product.DeletedAt != nil || product.ParentId != "" || !published(scope, product) {
+			continue
+		}
+
+		if statuses != nil && !slices.Contains(statuses, product.Status) {
+			continue
+		}
+
+		score := nameWeight*matches(terms, product.Name) + categoryWeight*matches(terms, product.Category)
+		if score > 0 {
+			results = append(results, canonical.SearchResult{Product: product, Score: float64(score)})
+		}
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		return results[i].Product.Id < results[j].Product.Id
+	})
+
+	if offset > len(results) {
+		offset = len(results)
+	}
+
+	results = results[offset:]
+	if len(results) > page.Limit+1 {
+		results = results[:page.Limit+1]
+	}
+
+	return newSearchPage(results, offset, page.Limit), nil
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func matches(terms []string, text string) int {
+	count := 0
+
+	for _, word := range tokenize(text) {
+		for _, term := range terms {
+			if word == term {
+				count++
+			}
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,593 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRepository_Pagination(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	for i := 0; i < 5; i++ {
+		_, err := repo.CreateProduct(ctx, canonical.Product{Id: fmt.Sprintf("id-%d", i), Category: "testCategory"})
+		assert.Nil(t, err)
+	}
+
+	first, err := repo.GetAllProducts(ctx, canonical.ProductQuery{}, canonical.PageRequest{Limit: 3})
+
+	assert.Nil(t, err)
+	assert.Len(t, first.Products, 3)
+	assert.True(t, first.HasMore)
+	assert.Equal(t, "id-2", first.Products[2].Id)
+
+	second, err := repo.GetAllProducts(ctx, canonical.ProductQuery{}, canonical.PageRequest{Limit: 3, Cursor: first.NextCursor})
+
+	assert.Nil(t, err)
+	assert.Len(t, second.Products, 2)
+	assert.False(t, second.HasMore)
+	assert.Empty(t, second.NextCursor)
+	assert.Equal(t, "id-3", second.Products[0].Id)
+}
+
+func TestMemoryRepository_GetProductsByCategory(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "a", Category: "testCategory"})
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "b", Category: "otherCategory"})
+
+	page, err := repo.GetProductsByCategory(ctx, []string{"testCategory"}, nil, canonical.PageRequest{Limit: 10})
+
+	assert.Nil(t, err)
+	assert.Len(t, page.Products, 1)
+	assert.Equal(t, "a", page.Products[0].Id)
+}
+
+func TestMemoryRepository_MoveCategory(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	for _, category := range []canonical.Category{
+		{Slug: "electronics", Path: "/electronics", Version: 1},
+		{Slug: "phones", Parent: "electronics", Path: "/electronics/phones", Version: 1},
+		{Slug: "android", Parent: "phones", Path: "/electronics/phones/android", Version: 1},
+		{Slug: "gadgets", Path: "/gadgets", Version: 1},
+	} {
+		_, err := repo.CreateCategory(ctx, category)
+		assert.Nil(t, err)
+	}
+
+	_, err := repo.UpdateCategory(ctx, "phones", 2, canonical.Category{Parent: "gadgets", Path: "/gadgets/phones"})
+	assert.Equal(t, errs.KindVersionConflict, errs.KindOf(err))
+
+	moved, err := repo.UpdateCategory(ctx, "phones", 1, canonical.Category{Parent: "gadgets", Path: "/gadgets/phones"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), moved.Version)
+
+	subtree, err := repo.GetCategorySubtree(ctx, "/gadgets")
+	assert.Nil(t, err)
+
+	var paths []string
+	for _, category := range subtree {
+		paths = append(paths, category.Path)
+	}
+	assert.Equal(t, []string{"/gadgets", "/gadgets/phones", "/gadgets/phones/android"}, paths)
+
+	subtree, _ = repo.GetCategorySubtree(ctx, "/electronics")
+	assert.Len(t, subtree, 1)
+}
+
+func TestMemoryRepository_UpdateCategorySchema(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	_, err := repo.CreateCategory(ctx, canonical.Category{Slug: "shirts", Path: "/shirts", Version: 1})
+	assert.Nil(t, err)
+
+	schema := []canonical.AttributeDefinition{
+		{Name: "color", Type: canonical.AttributeEnum, Required: true, Values: []string{"red", "blue"}},
+		{Name: "cotton", Type: canonical.AttributeBool},
+	}
+
+	_, err = repo.UpdateCategory(ctx, "shirts", 1, canonical.Category{Name: "Shirts", Path: "/shirts", Attributes: schema})
+	assert.Nil(t, err)
+
+	category, err := repo.GetCategory(ctx, "shirts")
+	assert.Nil(t, err)
+	assert.Equal(t, "Shirts", category.Name)
+	assert.Equal(t, schema, category.Attributes)
+	assert.Equal(t, int64(2), category.Version)
+}
+
+func TestMemoryRepository_UpdateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "xpto", Name: "test", Stock: 10, Version: 1})
+
+	_, err := repo.UpdateProduct(ctx, "xpto", 1, canonical.Product{Name: "updated", Stock: 5})
+	assert.Nil(t, err)
+
+	product, err := repo.GetProductById(ctx, "xpto")
+	assert.Nil(t, err)
+	assert.Equal(t, "xpto", product.Id)
+	assert.Equal(t, "updated", product.Name)
+	assert.Equal(t, 5, product.Stock)
+	assert.Equal(t, int64(2), product.Version)
+
+	_, err = repo.UpdateProduct(ctx, "xpto", 1, canonical.Product{Name: "stale"})
+	assert.Equal(t, errs.KindVersionConflict, errs.KindOf(err))
+	assert.Equal(t, errs.KindVersionConflict, errs.KindOf(repo.DeleteProduct(ctx, "xpto", 1, time.Now(), "tester")))
+
+	assert.Nil(t, repo.DeleteProduct(ctx, "xpto", 2, time.Now(), "tester"))
+	assert.Equal(t, errs.KindNotFound, errs.KindOf(repo.DeleteProduct(ctx, "xpto", 2, time.Now(), "tester")))
+
+	_, err = repo.GetProductById(ctx, "xpto")
+	assert.Equal(t, errs.KindNotFound, errs.KindOf(err))
+
+	_, err = repo.UpdateProduct(ctx, "xpto", 2, canonical.Product{})
+	assert.Equal(t, errs.KindNotFound, errs.KindOf(err))
+}
+
+func TestMemoryRepository_CreateDuplicate(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	_, err := repo.CreateProduct(ctx, canonical.Product{Id: "xpto"})
+	assert.Nil(t, err)
+
+	_, err = repo.CreateProduct(ctx, canonical.Product{Id: "xpto"})
+	assert.Equal(t, errs.KindConflict, errs.KindOf(err))
+}
+
+func TestMemoryRepository_CreateDuplicateSKU(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	_, err := repo.CreateProduct(ctx, canonical.Product{Id: "xpto", ParentId: "parent", SKU: "TS-RED-M"})
+	assert.Nil(t, err)
+
+	_, err = repo.CreateProduct(ctx, canonical.Product{Id: "abcd", ParentId: "parent", SKU: "TS-RED-M"})
+	assert.Equal(t, errs.KindConflict, errs.KindOf(err))
+	assert.Equal(t, "sku TS-RED-M already exists", errs.Detail(err))
+
+	page, err := repo.GetAllProducts(ctx, canonical.ProductQuery{ParentId: "parent"}, canonical.PageRequest{Limit: 10})
+	assert.Nil(t, err)
+	assert.Len(t, page.Products, 1)
+}
+
+func TestMemoryRepository_ConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _ = repo.CreateProduct(ctx, canonical.Product{Id: fmt.Sprintf("id-%02d", i)})
+			_, _ = repo.GetAllProducts(ctx, canonical.ProductQuery{}, canonical.PageRequest{Limit: 10})
+		}(i)
+	}
+	wg.Wait()
+
+	page, err := repo.GetAllProducts(ctx, canonical.ProductQuery{}, canonical.PageRequest{Limit: 100})
+
+	assert.Nil(t, err)
+	assert.Len(t, page.Products, 50)
+}
+
+func TestMemoryRepository_SearchProducts(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "a", Name: "Blue Phone Case", Category: "Accessories"})
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "b", Name: "Smartphone", Category: "Phone"})
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "c", Name: "Phone", Category: "Phone"})
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "d", Name: "Laptop", Category: "Computers"})
+
+	first, err := repo.SearchProducts(ctx, "phone", nil, canonical.PageRequest{Limit: 2})
+
+	assert.Nil(t, err)
+	assert.Len(t, first.Results, 2)
+	assert.Equal(t, "c", first.Results[0].Product.Id)
+	assert.Equal(t, float64(3), first.Results[0].Score)
+	assert.Equal(t, "a", first.Results[1].Product.Id)
+	assert.True(t, first.HasMore)
+
+	second, err := repo.SearchProducts(ctx, "phone", nil, canonical.PageRequest{Limit: 2, Cursor: first.NextCursor})
+
+	assert.Nil(t, err)
+	assert.Len(t, second.Results, 1)
+	assert.Equal(t, "b", second.Results[0].Product.Id)
+	assert.False(t, second.HasMore)
+}
+
+func TestMemoryRepository_FilterAndSort(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	now := time.Now()
+	for i, price := range []int64{3000, 1000, 2000, 1000, 5000} {
+		_, _ = repo.CreateProduct(ctx, canonical.Product{
+			Id:        fmt.Sprintf("id-%d", i),
+			Name:      fmt.Sprintf("phone %d", i),
+			Category:  "phones",
+			Price:     money.Money{Amount: price, Currency: "BRL"},
+			Stock:     i,
+			CreatedAt: now.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "other", Name: "phone case", Category: "accessories", Price: money.Money{Amount: 1500, Currency: "BRL"}})
+
+	priceMax := money.Money{Amount: 3000, Currency: "BRL"}
+	query := canonical.ProductQuery{
+		Category: "phones",
+		PriceMax: &priceMax,
+		Sort: []canonical.SortField{
+			{Field: canonical.FieldPrice},
+			{Field: canonical.FieldCreatedAt, Descending: true},
+		},
+	}
+
+	var ids []string
+	page := canonical.PageRequest{Limit: 2}
+	for {
+		productPage, err := repo.GetAllProducts(ctx, query, page)
+		assert.Nil(t, err)
+
+		for _, product := range productPage.Products {
+			ids = append(ids, product.Id)
+		}
+
+		if !productPage.HasMore {
+			break
+		}
+
+		page.Cursor = productPage.NextCursor
+	}
+
+	assert.Equal(t, []string{"id-3", "id-1", "id-2", "id-0"}, ids)
+}
+
+func TestMemoryRepository_FilterByAttribute(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	for _, product := range []canonical.Product{
+		{Id: "a", Attributes: map[string]any{"voltage": float64(220), "wireless": true}},
+		{Id: "b", Attributes: map[string]any{"voltage": int32(110)}},
+		{Id: "c", Attributes: map[string]any{"voltage": "220"}},
+		{Id: "d"},
+	} {
+		_, err := repo.CreateProduct(ctx, product)
+		assert.Nil(t, err)
+	}
+
+	ids := func(query canonical.ProductQuery) []string {
+		page, err := repo.GetAllProducts(ctx, query, canonical.PageRequest{Limit: 10})
+		assert.Nil(t, err)
+
+		var ids []string
+		for _, product := range page.Products {
+			ids = append(ids, product.Id)
+		}
+
+		return ids
+	}
+
+	assert.Equal(t, []string{"a", "c"}, ids(canonical.ProductQuery{Attributes: []canonical.AttributeFilter{{Name: "voltage", Values: []any{"220", 220.0}}}}))
+	assert.Equal(t, []string{"b"}, ids(canonical.ProductQuery{Attributes: []canonical.AttributeFilter{{Name: "voltage", Values: []any{110.0}}}}))
+	assert.Equal(t, []string{"a"}, ids(canonical.ProductQuery{Attributes: []canonical.AttributeFilter{
+		{Name: "voltage", Values: []any{220.0}},
+		{Name: "wireless", Values: []any{true}},
+	}}))
+}
+
+func TestMemoryRepository_PublicationWindows(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	for _, product := range []canonical.Product{
+		{Id: "live", Name: "Phone"},
+		{Id: "scheduled", Name: "Phone", PublishAt: &future},
+		{Id: "ended", Name: "Phone", PublishAt: &past, UnpublishAt: &past},
+	} {
+		_, err := repo.CreateProduct(ctx, product)
+		assert.Nil(t, err)
+	}
+
+	ids := func(ctx context.Context) []string {
+		page, err := repo.GetAllProducts(ctx, canonical.ProductQuery{}, canonical.PageRequest{Limit: 10})
+		assert.Nil(t, err)
+
+		var ids []string
+		for _, product := range page.Products {
+			ids = append(ids, product.Id)
+		}
+
+		return ids
+	}
+
+	assert.Equal(t, []string{"live"}, ids(ctx))
+	assert.Equal(t, []string{"ended", "live", "scheduled"}, ids(visibility.Admin(ctx)))
+	assert.Equal(t, []string{"live", "scheduled"}, ids(visibility.Preview(ctx, future)))
+
+	_, err := repo.GetProductById(ctx, "scheduled")
+	assert.Equal(t, errs.KindNotFound, errs.KindOf(err))
+
+	_, err = repo.GetProductById(visibility.Preview(ctx, future), "scheduled")
+	assert.Nil(t, err)
+
+	searchPage, err := repo.SearchProducts(ctx, "phone", nil, canonical.PageRequest{Limit: 10})
+	assert.Nil(t, err)
+	assert.Len(t, searchPage.Results, 1)
+}
+
+func TestMemoryRepository_CursorIsBoundToSort(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	for i := 0; i < 3; i++ {
+		_, _ = repo.CreateProduct(ctx, canonical.Product{Id: fmt.Sprintf("id-%d", i)})
+	}
+
+	first, err := repo.GetAllProducts(ctx, canonical.ProductQuery{}, canonical.PageRequest{Limit: 1})
+	assert.Nil(t, err)
+
+	sorted := canonical.ProductQuery{Sort: []canonical.SortField{{Field: canonical.FieldName}}}
+	_, err = repo.GetAllProducts(ctx, sorted, canonical.PageRequest{Limit: 1, Cursor: first.NextCursor})
+
+	assert.Equal(t, errs.KindBadRequest, errs.KindOf(err))
+}
+
+func TestMemoryRepository_TrashAndRestore(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "xpto", Name: "test", Version: 1})
+
+	deletedAt := time.Now()
+	assert.Nil(t, repo.DeleteProduct(ctx, "xpto", 1, deletedAt, "tester"))
+
+	live, _ := repo.GetAllProducts(ctx, canonical.ProductQuery{}, canonical.PageRequest{Limit: 10})
+	assert.Empty(t, live.Products)
+
+	trash, _ := repo.GetAllProducts(ctx, canonical.ProductQuery{Deleted: true}, canonical.PageRequest{Limit: 10})
+	assert.Len(t, trash.Products, 1)
+	assert.Equal(t, "tester", trash.Products[0].DeletedBy)
+
+	search, _ := repo.SearchProducts(ctx, "test", nil, canonical.PageRequest{Limit: 10})
+	assert.Empty(t, search.Results)
+
+	restored, err := repo.RestoreProduct(ctx, "xpto", 2)
+	assert.Nil(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Empty(t, restored.DeletedBy)
+	assert.Equal(t, int64(3), restored.Version)
+
+	_, err = repo.RestoreProduct(ctx, "xpto", 3)
+	assert.Equal(t, errs.KindNotFound, errs.KindOf(err))
+}
+
+func TestMemoryRepository_PurgeDeletedProducts(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	now := time.Now()
+	for i, deletedAt := range []time.Time{now.Add(-48 * time.Hour), now.Add(-time.Hour)} {
+		id := fmt.Sprintf("id-%d", i)
+		_, _ = repo.CreateProduct(ctx, canonical.Product{Id: id, Version: 1})
+		_ = repo.DeleteProduct(ctx, id, 1, deletedAt, "tester")
+	}
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "live", Version: 1})
+
+	purged, err := repo.PurgeDeletedProducts(ctx, now.Add(-24*time.Hour))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id-0"}, purged)
+
+	trash, _ := repo.GetAllProducts(ctx, canonical.ProductQuery{Deleted: true}, canonical.PageRequest{Limit: 10})
+	assert.Len(t, trash.Products, 1)
+	assert.Equal(t, "id-1", trash.Products[0].Id)
+
+	_, err = repo.GetProductById(ctx, "live")
+	assert.Nil(t, err)
+}
+
+func TestMemoryRepository_GetAuditEntries(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	for i := 0; i < 3; i++ {
+		_ = repo.AppendAuditEntry(ctx, canonical.AuditEntry{Id: fmt.Sprintf("entry-%d", i), ProductId: "xpto"})
+	}
+	_ = repo.AppendAuditEntry(ctx, canonical.AuditEntry{Id: "entry-9", ProductId: "other"})
+
+	first, err := repo.GetAuditEntries(ctx, "xpto", canonical.PageRequest{Limit: 2})
+
+	assert.Nil(t, err)
+	assert.True(t, first.HasMore)
+	assert.Equal(t, "entry-2", first.Entries[0].Id)
+	assert.Equal(t, "entry-1", first.Entries[1].Id)
+
+	second, err := repo.GetAuditEntries(ctx, "xpto", canonical.PageRequest{Limit: 2, Cursor: first.NextCursor})
+
+	assert.Nil(t, err)
+	assert.False(t, second.HasMore)
+	assert.Len(t, second.Entries, 1)
+	assert.Equal(t, "entry-0", second.Entries[0].Id)
+}
+
+func TestMemoryRepository_DuePriceChanges(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	now := time.Now()
+	_ = repo.CreatePriceChange(ctx, canonical.PriceChange{Id: "late", ProductId: "xpto", EffectiveAt: now.Add(-time.Minute), Status: canonical.PriceScheduled})
+	_ = repo.CreatePriceChange(ctx, canonical.PriceChange{Id: "early", ProductId: "xpto", EffectiveAt: now.Add(-time.Hour), Status: canonical.PriceScheduled})
+	_ = repo.CreatePriceChange(ctx, canonical.PriceChange{Id: "future", ProductId: "xpto", EffectiveAt: now.Add(time.Hour), Status: canonical.PriceScheduled})
+
+	due, err := repo.GetDuePriceChanges(ctx, now, 10)
+
+	assert.Nil(t, err)
+	assert.Len(t, due, 2)
+	assert.Equal(t, "early", due[0].Id)
+
+	err = repo.CompletePriceChange(ctx, "early", canonical.PriceApplied, now)
+	assert.Nil(t, err)
+
+	err = repo.CompletePriceChange(ctx, "early", canonical.PriceApplied, now)
+	assert.Equal(t, errs.KindConflict, errs.KindOf(err))
+
+	due, _ = repo.GetDuePriceChanges(ctx, now, 10)
+	assert.Len(t, due, 1)
+	assert.Equal(t, "late", due[0].Id)
+
+	timeline, _ := repo.GetPriceChanges(ctx, "xpto")
+	assert.Len(t, timeline, 3)
+	assert.Equal(t, canonical.PriceApplied, timeline[0].Status)
+}
+
+func TestMemoryRepository_SetStockLevels(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "xpto", Version: 1})
+
+	levels := []canonical.StockLevel{{Warehouse: "main", Quantity: 7}, {Warehouse: "north", Quantity: 3}}
+
+	product, err := repo.SetStockLevels(ctx, "xpto", 1, levels)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, product.Stock)
+	assert.Equal(t, 3, canonical.StockAt(product.StockLevels, "north"))
+	assert.Equal(t, int64(2), product.Version)
+
+	_, err = repo.SetStockLevels(ctx, "xpto", 1, levels)
+	assert.Equal(t, errs.KindVersionConflict, errs.KindOf(err))
+}
+
+func TestMemoryRepository_ReserveStockConcurrently(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "xpto", Stock: 5, Version: 1})
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.ReserveStock(ctx, "xpto", 1)
+			if err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			} else {
+				assert.Equal(t, errs.KindConflict, errs.KindOf(err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 5, reserved)
+
+	product, _ := repo.GetProductById(ctx, "xpto")
+	assert.Equal(t, 0, product.Available())
+}
+
+func TestMemoryRepository_ReservationsMoveTheVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "xpto", Stock: 5, Version: 1})
+
+	product, err := repo.ReserveStock(ctx, "xpto", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), product.Version)
+
+	err = repo.ReleaseStock(ctx, "xpto", 2)
+	assert.Nil(t, err)
+
+	product, _ = repo.GetProductById(ctx, "xpto")
+	assert.Equal(t, 0, product.Reserved)
+	assert.Equal(t, int64(3), product.Version)
+}
+
+func TestMemoryRepository_WritesFromBeforeAReservationConflict(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	_, _ = repo.CreateProduct(ctx, canonical.Product{Id: "xpto", Stock: 5, Version: 1})
+
+	read, _ := repo.GetProductById(ctx, "xpto")
+
+	_, err := repo.ReserveStock(ctx, "xpto", 4)
+	assert.Nil(t, err)
+
+	// Stock checked against the reservations of read must not be written.
+	read.Stock = 2
+
+	_, err = repo.UpdateProduct(ctx, "xpto", read.Version, read)
+	assert.Equal(t, errs.KindVersionConflict, errs.KindOf(err))
+
+	_, err = repo.PatchProduct(ctx, "xpto", read.Version, canonical.ProductPatch{Stock: &read.Stock})
+	assert.Equal(t, errs.KindVersionConflict, errs.KindOf(err))
+
+	_, err = repo.SetStockLevels(ctx, "xpto", read.Version, []canonical.StockLevel{{Warehouse: "main", Quantity: 2}})
+	assert.Equal(t, errs.KindVersionConflict, errs.KindOf(err))
+
+	product, _ := repo.GetProductById(ctx, "xpto")
+	assert.Equal(t, 5, product.Stock)
+	assert.Equal(t, 4, product.Reserved)
+}
+
+func TestMemoryRepository_StockMovements(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemory()
+
+	err := repo.AppendStockMovements(ctx, []canonical.StockMovement{
+		{Id: "1", ProductId: "xpto", Warehouse: "main", Type: canonical.MovementReceipt, Quantity: 10},
+		{Id: "2", ProductId: "xpto", Type: canonical.MovementReservation, Quantity: 2},
+		{Id: "3", ProductId: "other", Warehouse: "main", Type: canonical.MovementReceipt, Quantity: 1},
+		{Id: "4", ProductId: "xpto", Warehouse: "north", Type: canonical.MovementReceipt, Quantity: 4},
+		{Id: "5", ProductId: "xpto", Warehouse: "main", Type: canonical.MovementSale, Quantity: -3},
+	})
+	assert.Nil(t, err)
+
+	first, err := repo.GetStockMovements(ctx, "xpto", canonical.PageRequest{Limit: 3})
+	assert.Nil(t, err)
+	assert.Len(t, first.Movements, 3)
+	assert.Equal(t, "5", first.Movements[0].Id)
+	assert.True(t, first.HasMore)
+
+	second, err := repo.GetStockMovements(ctx, "xpto", canonical.PageRequest{Limit: 3, Cursor: first.NextCursor})
+	assert.Nil(t, err)
+	assert.Len(t, second.Movements, 1)
+	assert.Equal(t, "1", second.Movements[0].Id)
+
+	balances, err := repo.GetStockBalances(ctx, []string{"xpto"})
+	assert.Nil(t, err)
+	assert.Equal(t, []canonical.StockBalance{{
+		ProductId: "xpto",
+		Levels:    []canonical.StockLevel{{Warehouse: "main", Quantity: 7}, {Warehouse: "north", Quantity: 4}},
+		Reserved:  2,
+	}}, balances)
+}
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoRegistry returns the BSON registry the MongoDB client has to be
+// created with. Prices stored as plain numbers, before they carried a
+// currency, are read as amounts in legacyCurrency.
+func NewMongoRegistry(legacyCurrency money.Currency) *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	money.Codec{Legacy: legacyCurrency}.Register(registry)
+
+	return registry
+}
+
+// MigrateLegacyPrices rewrites the prices stored as plain numbers into money
+// documents in legacyCurrency, so that filters and sorts on the amount see
+// them. It only touches documents still in the old format and is safe to run
+// on every startup.
+func MigrateLegacyPrices(ctx context.Context, db *mongo.Database, legacyCurrency money.Currency) (int64, error) {
+	filter := bson.D{{Key: "price", Value: bson.D{{Key: "$type", Value: bson.A{"double", "int", "long", "decimal"}}}}}
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "price", Value: bson.D{
+		{Key: "amount", Value: bson.D{{Key: "$toLong", Value: bson.D{{Key: "$round", Value: bson.A{
+			bson.D{{Key: "$multiply", Value: bson.A{"$price", math.Pow10(legacyCurrency.Exponent())}}},
+			0,
+		}}}}}},
+		{Key: "currency", Value: legacyCurrency.String()},
+	}}}}}}
+
+	var migrated int64
+
+	for _, collection := range []string{"productSlice", "productPrices"} {
+		res, err := db.Collection(collection).UpdateMany(ctx, filter, update)
+		if err != nil {
+			return migrated, err
+		}
+
+		migrated += res.ModifiedCount
+	}
+
+	return migrated, nil
+}
+
+// MigrateLegacyStock puts the stock of products stored before it was kept per
+// warehouse into warehouse. It only touches documents without stock levels
+// and is safe to run on every startup.
+func MigrateLegacyStock(ctx context.Context, db *mongo.Database, warehouse string) (int64, error) {
+	filter := bson.D{
+		{Key: "stock_levels", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "stock", Value: bson.D{{Key: "$nin", Value: bson.A{0, nil}}}},
+	}
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "stock_levels", Value: bson.A{
+		bson.D{{Key: "warehouse", Value: warehouse}, {Key: "quantity", Value: "$stock"}},
+	}}}}}}
+
+	res, err := db.Collection("productSlice").UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.ModifiedCount, nil
+}
+
+// MigrateLegacyStatus makes the products stored before they had a lifecycle
+// status active, which is what they were sold as. It is safe to run on every
+// startup.
+func MigrateLegacyStatus(ctx context.Context, db *mongo.Database) (int64, error) {
+	filter := bson.D{{Key: "status", Value: bson.D{{Key: "$exists", Value: false}}}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: canonical.StatusActive}}}}
+
+	res, err := db.Collection("productSlice").UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.ModifiedCount, nil
+}
+
+// MigrateLegacyCategories creates a root category for every category products
+// were stored with before categories were managed and moves the products to
+// its slug, which merges names that only differ in case or punctuation. It is
+// safe to run on every startup.
+func MigrateLegacyCategories(ctx context.Context, db *mongo.Database) (int64, error) {
+	products := db.Collection("productSlice")
+	categories := db.Collection("productCategories")
+
+	names, err := products.Distinct(ctx, "category", bson.D{})
+	if err != nil {
+		return 0, err
+	}
+
+	var migrated int64
+
+	for _, value := range names {
+		name, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		slug := canonical.Slugify(name)
+		if slug == "" {
+			continue
+		}
+
+		category := bson.M{"$setOnInsert": bson.M{
+			"name":       name,
+			"path":       canonical.CategoryPath("", slug),
+			"created_at": time.Now(),
+			"version":    int64(1),
+		}}
+
+		res, err := categories.UpdateOne(ctx, bson.D{{Key: "_id", Value: slug}}, category, options.Update().SetUpsert(true))
+		if err != nil {
+			return migrated, err
+		}
+
+		migrated += res.UpsertedCount
+
+		if name == slug {
+			continue
+		}
+
+		moved, err := products.UpdateMany(ctx, bson.D{{Key: "category", Value: name}}, bson.M{"$set": bson.M{"category": slug}})
+		if err != nil {
+			return migrated, err
+		}
+
+		migrated += moved.ModifiedCount
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,239 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StockMovementRepository stores the stock ledger. Like the audit log it is
+// only ever appended to and ordered by UUIDv7 ids.
+type StockMovementRepository interface {
+	AppendStockMovements(ctx context.Context, movements []canonical.StockMovement) error
+	GetStockMovements(ctx context.Context, productId string, page canonical.PageRequest) (canonical.StockMovementPage, error)
+	// GetStockBalances sums up the movements of each of productIds. Products
+	// without movements are left out.
+	GetStockBalances(ctx context.Context, productIds []string) ([]canonical.StockBalance, error)
+}
+
+func newMovementPage(movements []canonical.StockMovement, limit int) canonical.StockMovementPage {
+	if len(movements) <= limit {
+		return canonical.StockMovementPage{Movements: movements}
+	}
+
+	movements = movements[:limit]
+
+	return canonical.StockMovementPage{
+		Movements:  movements,
+		NextCursor: encodeIdCursor(movements[len(movements)-1].Id),
+		HasMore:    true,
+	}
+}
+
+// balanceBuilder adds movements up into balances in the order their products
+// were first seen.
+type balanceBuilder struct {
+	balances []canonical.StockBalance
+	index    map[string]int
+}
+
+func (builder *balanceBuilder) add(productId, warehouse string, quantity int) {
+	if builder.index == nil {
+		builder.index = map[string]int{}
+	}
+
+	i, ok := builder.index[productId]
+	if !ok {
+		i = len(builder.balances)
+		builder.index[productId] = i
+		builder.balances = append(builder.balances, canonical.StockBalance{ProductId: productId})
+	}
+
+	balance := &builder.balances[i]
+
+	if warehouse == "" {
+		balance.Reserved += quantity
+		return
+	}
+
+	for j := range balance.Levels {
+		if balance.Levels[j].Warehouse == warehouse {
+			balance.Levels[j].Quantity += quantity
+			return
+		}
+	}
+
+	balance.Levels = append(balance.Levels, canonical.StockLevel{Warehouse: warehouse, Quantity: quantity})
+}
+
+func (builder *balanceBuilder) build() []canonical.StockBalance {
+	balances := builder.balances
+	if balances == nil {
+		balances = []canonical.StockBalance{}
+	}
+
+	for _, balance := range balances {
+		sort.Slice(balance.Levels, func(i, j int) bool {
+			return balance.Levels[i].Warehouse < balance.Levels[j].Warehouse
+		})
+	}
+
+	return balances
+}
+
+func (repo *repository) AppendStockMovements(ctx context.Context, movements []canonical.StockMovement) error {
+	documents := make([]any, 0, len(movements))
+	for _, movement := range movements {
+		documents = append(documents, movement)
+	}
+
+	_, err := repo.movements.InsertMany(ctx, documents)
+	if err != nil {
+		return errs.Internal(err)
+	}
+
+	return nil
+}
+
+func (repo *repository) GetStockMovements(ctx context.Context, productId string, page canonical.PageRequest) (canonical.StockMovementPage, error) {
+	filter := bson.D{{Key: "product_id", Value: productId}}
+
+	if page.Cursor != "" {
+		last, err := decodeIdCursor(page.Cursor)
+		if err != nil {
+			return canonical.StockMovementPage{}, err
+		}
+
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$lt", Value: last}}})
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(int64(page.Limit + 1))
+
+	res, err := repo.movements.Find(ctx, filter, opts)
+	if err != nil {
+		return canonical.StockMovementPage{}, errs.Internal(err)
+	}
+
+	movements := []canonical.StockMovement{}
+
+	err = res.All(ctx, &movements)
+	if err != nil {
+		return canonical.StockMovementPage{}, errs.Internal(err)
+	}
+
+	return newMovementPage(movements, page.Limit), nil
+}
+
+func (repo *repository) GetStockBalances(ctx context.Context, productIds []string) ([]canonical.StockBalance, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "product_id", Value: bson.D{{Key: "$in", Value: productIds}}}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "product_id", Value: "$product_id"}, {Key: "warehouse", Value: "$warehouse"}}},
+			{Key: "quantity", Value: bson.D{{Key: "$sum", Value: "$quantity"}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.product_id", Value: 1}}}},
+	}
+
+	res, err := repo.movements.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	var sums []struct {
+		Key struct {
+			ProductId string `bson:"product_id"`
+			Warehouse string `bson:"warehouse"`
+		} `bson:"_id"`
+		Quantity int `bson:"quantity"`
+	}
+
+	err = res.All(ctx, &sums)
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	var builder balanceBuilder
+	for _, sum := range sums {
+		builder.add(sum.Key.ProductId, sum.Key.Warehouse, sum.Quantity)
+	}
+
+	return builder.build(), nil
+}
+
+func (repo *memoryRepository) AppendStockMovements(ctx context.Context, movements []canonical.StockMovement) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.movements = append(repo.movements, movements...)
+
+	return nil
+}
+
+func (repo *memoryRepository) GetStockMovements(ctx context.Context, productId string, page canonical.PageRequest) (canonical.StockMovementPage, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.StockMovementPage{}, errs.Internal(err)
+	}
+
+	var last string
+	if page.Cursor != "" {
+		var err error
+
+		last, err = decodeIdCursor(page.Cursor)
+		if err != nil {
+			return canonical.StockMovementPage{}, err
+		}
+	}
+
+	repo.mu.RLock()
+	movements := []canonical.StockMovement{}
+	for _, movement := range repo.movements {
+		if movement.ProductId == productId && (last == "" || movement.Id < last) {
+			movements = append(movements, movement)
+		}
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(movements, func(i, j int) bool {
+		return movements[i].Id > movements[j].Id
+	})
+
+	if len(movements) > page.Limit+1 {
+		movements = movements[:page.Limit+1]
+	}
+
+	return newMovementPage(movements, page.Limit), nil
+}
+
+func (repo *memoryRepository) GetStockBalances(ctx context.Context, productIds []string) ([]canonical.StockBalance, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	wanted := make(map[string]bool, len(productIds))
+	for _, id := range productIds {
+		wanted[id] = true
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var builder balanceBuilder
+	for _, movement := range repo.movements {
+		if wanted[movement.ProductId] {
+			builder.add(movement.ProductId, movement.Warehouse, movement.Quantity)
+		}
+	}
+
+	return builder.build(), nil
+}
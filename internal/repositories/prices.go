@@ -0,0 +1,192 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PriceRepository stores the price timelines of products.
+type PriceRepository interface {
+	CreatePriceChange(ctx context.Context, change canonical.PriceChange) error
+	// GetPriceChanges returns the timeline of a product ordered by the time
+	// each change takes effect.
+	GetPriceChanges(ctx context.Context, productId string) ([]canonical.PriceChange, error)
+	// GetDuePriceChanges returns up to limit scheduled changes that took effect
+	// at or before now, oldest first.
+	GetDuePriceChanges(ctx context.Context, now time.Time, limit int) ([]canonical.PriceChange, error)
+	// CompletePriceChange moves a scheduled change to status. It fails with a
+	// conflict if the change is no longer scheduled.
+	CompletePriceChange(ctx context.Context, id, status string, completedAt time.Time) error
+}
+
+func (repo *repository) CreatePriceChange(ctx context.Context, change canonical.PriceChange) error {
+	_, err := repo.prices.InsertOne(ctx, change)
+	if err != nil {
+		return priceChangeError(err, change.Id)
+	}
+
+	return nil
+}
+
+func (repo *repository) GetPriceChanges(ctx context.Context, productId string) ([]canonical.PriceChange, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "effective_at", Value: 1}, {Key: "_id", Value: 1}})
+
+	return repo.findPriceChanges(ctx, bson.D{{Key: "product_id", Value: productId}}, opts)
+}
+
+func (repo *repository) GetDuePriceChanges(ctx context.Context, now time.Time, limit int) ([]canonical.PriceChange, error) {
+	filter := bson.D{
+		{Key: "status", Value: canonical.PriceScheduled},
+		{Key: "effective_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "effective_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+
+	return repo.findPriceChanges(ctx, filter, opts)
+}
+
+func (repo *repository) findPriceChanges(ctx context.Context, filter bson.D, opts *options.FindOptions) ([]canonical.PriceChange, error) {
+	res, err := repo.prices.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	changes := []canonical.PriceChange{}
+
+	err = res.All(ctx, &changes)
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	return changes, nil
+}
+
+func (repo *repository) CompletePriceChange(ctx context.Context, id, status string, completedAt time.Time) error {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "status", Value: canonical.PriceScheduled}}
+	update := bson.M{"$set": bson.M{"status": status, "applied_at": completedAt}}
+
+	res, err := repo.prices.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errs.Internal(err)
+	}
+
+	if res.MatchedCount == 0 {
+		count, err := repo.prices.CountDocuments(ctx, bson.D{{Key: "_id", Value: id}})
+		if err != nil {
+			return errs.Internal(err)
+		}
+
+		if count == 0 {
+			return priceChangeError(mongo.ErrNoDocuments, id)
+		}
+
+		return errs.Conflict("price change " + id + " is no longer scheduled")
+	}
+
+	return nil
+}
+
+func priceChangeError(err error, id string) error {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return errs.NotFound("price change " + id + " not found")
+	case mongo.IsDuplicateKeyError(err):
+		return errs.Conflict("price change " + id + " already exists")
+	default:
+		return errs.Internal(err)
+	}
+}
+
+func (repo *memoryRepository) CreatePriceChange(ctx context.Context, change canonical.PriceChange) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.prices[change.Id]; ok {
+		return errs.Conflict("price change " + change.Id + " already exists")
+	}
+
+	repo.prices[change.Id] = change
+
+	return nil
+}
+
+func (repo *memoryRepository) GetPriceChanges(ctx context.Context, productId string) ([]canonical.PriceChange, error) {
+	return repo.filterPriceChanges(ctx, -1, func(change canonical.PriceChange) bool {
+		return change.ProductId == productId
+	})
+}
+
+func (repo *memoryRepository) GetDuePriceChanges(ctx context.Context, now time.Time, limit int) ([]canonical.PriceChange, error) {
+	return repo.filterPriceChanges(ctx, limit, func(change canonical.PriceChange) bool {
+		return change.Status == canonical.PriceScheduled && !change.EffectiveAt.After(now)
+	})
+}
+
+// filterPriceChanges returns up to limit changes accepted by keep, ordered the
+// way the MongoDB repository orders them. A negative limit returns them all.
+func (repo *memoryRepository) filterPriceChanges(ctx context.Context, limit int, keep func(canonical.PriceChange) bool) ([]canonical.PriceChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	repo.mu.RLock()
+	changes := []canonical.PriceChange{}
+	for _, change := range repo.prices {
+		if keep(change) {
+			changes = append(changes, change)
+		}
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].EffectiveAt.Equal(changes[j].EffectiveAt) {
+			return changes[i].EffectiveAt.Before(changes[j].EffectiveAt)
+		}
+
+		return changes[i].Id < changes[j].Id
+	})
+
+	if limit >= 0 && len(changes) > limit {
+		changes = changes[:limit]
+	}
+
+	return changes, nil
+}
+
+func (repo *memoryRepository) CompletePriceChange(ctx context.Context, id, status string, completedAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	change, ok := repo.prices[id]
+	if !ok {
+		return errs.NotFound("price change " + id + " not found")
+	}
+
+	if change.Status != canonical.PriceScheduled {
+		return errs.Conflict("price change " + id + " is no longer scheduled")
+	}
+
+	change.Status = status
+	change.AppliedAt = &completedAt
+	repo.prices[id] = change
+
+	return nil
+}
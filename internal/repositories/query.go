@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"regexp"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// windowFilter matches the products published in scope: every product for
+// admins, else those whose publication window holds the time of scope.
+func windowFilter(scope visibility.Scope) bson.D {
+	if scope.Admin {
+		return bson.D{}
+	}
+
+	at := scope.Time()
+
+	return bson.D{{Key: "$and", Value: bson.A{
+		bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "publish_at", Value: nil}},
+			bson.D{{Key: "publish_at", Value: bson.D{{Key: "$lte", Value: at}}}},
+		}}},
+		bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "unpublish_at", Value: nil}},
+			bson.D{{Key: "unpublish_at", Value: bson.D{{Key: "$gt", Value: at}}}},
+		}}},
+	}}}
+}
+
+// published is windowFilter for the in-memory repository.
+func published(scope visibility.Scope, product canonical.Product) bool {
+	return scope.Admin || product.PublishedAt(scope.Time())
+}
+
+// queryFilter translates the criteria of query into a MongoDB filter.
+func queryFilter(query canonical.ProductQuery) bson.D {
+	filter := bson.D{}
+
+	if query.Deleted {
+		filter = append(filter, bson.E{Key: "deleted_at", Value: bson.D{{Key: "$ne", Value: nil}}})
+	} else {
+		filter = append(filter, bson.E{Key: "deleted_at", Value: nil})
+	}
+
+	if query.Category != "" {
+		filter = append(filter, bson.E{Key: "category", Value: query.Category})
+	}
+
+	if query.Categories != nil {
+		filter = append(filter, bson.E{Key: "category", Value: bson.D{{Key: "$in", Value: query.Categories}}})
+	}
+
+	if query.Statuses != nil {
+		filter = append(filter, bson.E{Key: "status", Value: bson.D{{Key: "$in", Value: query.Statuses}}})
+	}
+
+	if query.ParentId != "" {
+		filter = append(filter, bson.E{Key: "parent_id", Value: query.ParentId})
+	}
+
+	if query.ParentIds != nil {
+		filter = append(filter, bson.E{Key: "parent_id", Value: bson.D{{Key: "$in", Value: query.ParentIds}}})
+	}
+
+	if query.ParentsOnly {
+		filter = append(filter, bson.E{Key: "parent_id", Value: nil})
+	}
+
+	if query.SKU != "" {
+		filter = append(filter, bson.E{Key: "sku", Value: query.SKU})
+	}
+
+	for _, attribute := range query.Attributes {
+		filter = append(filter, bson.E{Key: "attributes." + attribute.Name, Value: bson.D{{Key: "$in", Value: attribute.Values}}})
+	}
+
+	if query.NamePrefix != "" {
+		filter = append(filter, bson.E{Key: "name", Value: bson.D{
+			{Key: "$regex", Value: "^" + regexp.QuoteMeta(query.NamePrefix)},
+		}})
+	}
+
+	// Price bounds only match products priced in the currency of the bound.
+	amount := bson.D{}
+	if query.PriceMin != nil {
+		filter = append(filter, bson.E{Key: "price.currency", Value: query.PriceMin.Currency})
+		amount = append(amount, bson.E{Key: "$gte", Value: query.PriceMin.Amount})
+	}
+	if query.PriceMax != nil {
+		if query.PriceMin == nil {
+			filter = append(filter, bson.E{Key: "price.currency", Value: query.PriceMax.Currency})
+		}
+		amount = append(amount, bson.E{Key: "$lte", Value: query.PriceMax.Amount})
+	}
+	if len(amount) > 0 {
+		filter = append(filter, bson.E{Key: "price.amount", Value: amount})
+	}
+
+	if query.StockLt != nil {
+		filter = append(filter, bson.E{Key: "stock", Value: bson.D{{Key: "$lt", Value: *query.StockLt}}})
+	}
+
+	if query.CreatedAfter != nil {
+		filter = append(filter, bson.E{Key: "created_at", Value: bson.D{{Key: "$gt", Value: *query.CreatedAfter}}})
+	}
+
+	return filter
+}
+
+// sortDocument translates sort into a MongoDB sort document that ends with
+// _id so that the order is total.
+func sortDocument(sort []canonical.SortField) bson.D {
+	document := bson.D{}
+	for _, field := range sort {
+		document = append(document, bson.E{Key: sortKey(field.Field), Value: direction(field)})
+	}
+
+	return append(document, bson.E{Key: "_id", Value: 1})
+}
+
+// keysetFilter matches the products that come after position in sort order:
+// those past it on the first sort field, or tied on it and past it on the next
+// one, and so on down to _id.
+func keysetFilter(sort []canonical.SortField, position *keyset) bson.D {
+	branches := bson.A{}
+
+	for i := 0; i <= len(sort); i++ {
+		branch := bson.D{}
+		for j := 0; j < i; j++ {
+			branch = append(branch, bson.E{Key: sortKey(sort[j].Field), Value: position.Values[j]})
+		}
+
+		if i == len(sort) {
+			branch = append(branch, bson.E{Key: "_id", Value: bson.D{{Key: "$gt", Value: position.Id}}})
+		} else {
+			operator := "$gt"
+			if sort[i].Descending {
+				operator = "$lt"
+			}
+
+			branch = append(branch, bson.E{Key: sortKey(sort[i].Field), Value: bson.D{{Key: operator, Value: position.Values[i]}}})
+		}
+
+		branches = append(branches, branch)
+	}
+
+	return bson.D{{Key: "$or", Value: branches}}
+}
+
+// sortKey returns the document key holding the sort value of field. Prices
+// are sorted by their amount in minor units.
+func sortKey(field string) string {
+	if field == canonical.FieldPrice {
+		return "price.amount"
+	}
+
+	return field
+}
+
+func direction(field canonical.SortField) int {
+	if field.Descending {
+		return -1
+	}
+
+	return 1
+}
@@ -2,145 +2,387 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/nelsonalves117/go-products-api/internal/canonical"
-	"github.com/nelsonalves117/go-products-api/internal/config"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Repository is the storage the service works on. Each concern is kept in a
+// collection of its own.
 type Repository interface {
-	GetAllProducts() ([]canonical.Product, error)
-	GetProductsByCategory(category string) ([]canonical.Product, error)
-	GetProductById(id string) (canonical.Product, error)
-	CreateProduct(product canonical.Product) (canonical.Product, error)
-	UpdateProduct(id string, product canonical.Product) (canonical.Product, error)
-	DeleteProduct(id string) error
+	ProductRepository
+	AuditRepository
+	PriceRepository
+	StockRepository
+	ReservationRepository
+	StockMovementRepository
+	AlertRepository
+	CategoryRepository
+}
+
+type ProductRepository interface {
+	GetAllProducts(ctx context.Context, query canonical.ProductQuery, page canonical.PageRequest) (canonical.ProductPage, error)
+	// GetProductsByCategory lists the products in any of categories that are
+	// in any of statuses, or in any status if statuses is nil. Variants are
+	// left out, as by ProductQuery.ParentsOnly.
+	GetProductsByCategory(ctx context.Context, categories, statuses []string, page canonical.PageRequest) (canonical.ProductPage, error)
+	GetProductById(ctx context.Context, id string) (canonical.Product, error)
+	GetDeletedProductById(ctx context.Context, id string) (canonical.Product, error)
+	// SearchProducts finds the products matching query that are in any of
+	// statuses, or in any status if statuses is nil. Variants are left out,
+	// as by ProductQuery.ParentsOnly.
+	SearchProducts(ctx context.Context, query string, statuses []string, page canonical.PageRequest) (canonical.SearchPage, error)
+	CreateProduct(ctx context.Context, product canonical.Product) (canonical.Product, error)
+	UpdateProduct(ctx context.Context, id string, version int64, product canonical.Product) (canonical.Product, error)
+	PatchProduct(ctx context.Context, id string, version int64, patch canonical.ProductPatch) (canonical.Product, error)
+	DeleteProduct(ctx context.Context, id string, version int64, deletedAt time.Time, deletedBy string) error
+	RestoreProduct(ctx context.Context, id string, version int64) (canonical.Product, error)
+	PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) ([]string, error)
 }
 
 type repository struct {
 	collection *mongo.Collection
+	audit      *mongo.Collection
+	prices     *mongo.Collection
+
+	reservations *mongo.Collection
+	movements    *mongo.Collection
+
+	thresholds *mongo.Collection
+	alerts     *mongo.Collection
+	categories *mongo.Collection
+}
+
+// NewMongo returns a Repository backed by the collections of db. The caller
+// owns the client behind db and is responsible for disconnecting it.
+func NewMongo(db *mongo.Database) Repository {
+	return &repository{
+		collection: db.Collection("productSlice"),
+		audit:      db.Collection("productAudit"),
+		prices:     db.Collection("productPrices"),
+
+		reservations: db.Collection("productReservations"),
+		movements:    db.Collection("productStockMovements"),
+
+		thresholds: db.Collection("categoryThresholds"),
+		alerts:     db.Collection("lowStockAlerts"),
+		categories: db.Collection("productCategories"),
+	}
+}
+
+func (repo *repository) GetAllProducts(ctx context.Context, query canonical.ProductQuery, page canonical.PageRequest) (canonical.ProductPage, error) {
+	return repo.findPage(ctx, query, page)
+}
+
+func (repo *repository) GetProductsByCategory(ctx context.Context, categories, statuses []string, page canonical.PageRequest) (canonical.ProductPage, error) {
+	return repo.findPage(ctx, canonical.ProductQuery{Categories: categories, Statuses: statuses, ParentsOnly: true}, page)
 }
 
-func New() Repository {
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(config.Get().ConnectionString))
+// findPage runs a range query on the sort keys of query starting after the
+// page cursor. One extra document is fetched to find out whether there is a
+// next page.
+func (repo *repository) findPage(ctx context.Context, query canonical.ProductQuery, page canonical.PageRequest) (canonical.ProductPage, error) {
+	position, err := decodeKeyset(page.Cursor, query.Sort)
 	if err != nil {
-		panic(err)
+		return canonical.ProductPage{}, err
 	}
 
-	return &repository{
-		collection: client.Database("product_db").Collection("productSlice"),
+	// The trash is only read by admins, whatever the windows of its products.
+	filter := queryFilter(query)
+	if !query.Deleted {
+		filter = append(filter, windowFilter(visibility.FromContext(ctx))...)
 	}
-}
 
-func (repo *repository) GetAllProducts() ([]canonical.Product, error) {
-	var productSlice []canonical.Product
+	if position != nil {
+		filter = append(filter, keysetFilter(query.Sort, position)...)
+	}
 
-	res, err := repo.collection.Find(context.Background(), bson.D{})
+	opts := options.Find().
+		SetSort(sortDocument(query.Sort)).
+		SetLimit(int64(page.Limit + 1))
+
+	res, err := repo.collection.Find(ctx, filter, opts)
 	if err != nil {
-		return nil, err
+		return canonical.ProductPage{}, errs.Internal(err)
 	}
 
-	for res.Next(context.Background()) {
+	productSlice := []canonical.Product{}
+
+	defer res.Close(ctx)
+
+	for res.Next(ctx) {
 		var product canonical.Product
 
 		err := res.Decode(&product)
 		if err != nil {
-			return nil, err
+			return canonical.ProductPage{}, errs.Internal(err)
 		}
 
 		productSlice = append(productSlice, product)
 	}
 
 	if err := res.Err(); err != nil {
-		return nil, err
+		return canonical.ProductPage{}, errs.Internal(err)
 	}
 
-	return productSlice, nil
+	return newPage(productSlice, page.Limit, query.Sort), nil
 }
 
-func (repo *repository) GetProductsByCategory(category string) ([]canonical.Product, error) {
-	var productSlice []canonical.Product
+func (repo *repository) GetProductById(ctx context.Context, id string) (canonical.Product, error) {
+	var product canonical.Product
+
+	filter := append(liveFilter(id), windowFilter(visibility.FromContext(ctx))...)
 
-	filter := bson.D{{Key: "category", Value: category}}
+	err := repo.collection.FindOne(ctx, filter).Decode(&product)
 
-	res, err := repo.collection.Find(context.Background(), filter)
 	if err != nil {
-		return nil, err
+		return canonical.Product{}, translateError(err, id)
 	}
 
-	for res.Next(context.Background()) {
-		var product canonical.Product
+	return product, nil
+}
 
-		err := res.Decode(&product)
-		if err != nil {
-			return nil, err
-		}
+func (repo *repository) GetDeletedProductById(ctx context.Context, id string) (canonical.Product, error) {
+	var product canonical.Product
 
-		productSlice = append(productSlice, product)
+	err := repo.collection.FindOne(ctx, trashFilter(id)).Decode(&product)
+	if err != nil {
+		return canonical.Product{}, translateError(err, id)
 	}
 
-	if err := res.Err(); err != nil {
-		return nil, err
+	return product, nil
+}
+
+func (repo *repository) CreateProduct(ctx context.Context, product canonical.Product) (canonical.Product, error) {
+	_, err := repo.collection.InsertOne(ctx, product)
+	if product.SKU != "" && mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), skuIndex) {
+		return canonical.Product{}, skuConflict(product.SKU)
+	}
+
+	if err != nil {
+		return canonical.Product{}, translateError(err, product.Id)
+	}
+
+	return product, nil
+}
+
+func (repo *repository) UpdateProduct(ctx context.Context, id string, version int64, product canonical.Product) (canonical.Product, error) {
+	fields := bson.M{
+		"$set": bson.M{
+			"name":            product.Name,
+			"category":        product.Category,
+			"price":           product.Price,
+			"stock":           product.Stock,
+			"price_overrides": product.PriceOverrides,
+			"stock_levels":    product.StockLevels,
+			"backorders":      product.Backorders,
+			"inherits_price":  product.InheritsPrice,
+
+			"reorder_threshold": product.ReorderThreshold,
+			"attributes":        product.Attributes,
+			"publish_at":        product.PublishAt,
+			"unpublish_at":      product.UnpublishAt,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	return repo.findOneAndUpdate(ctx, id, version, liveFilter(id), fields)
+}
+
+func (repo *repository) PatchProduct(ctx context.Context, id string, version int64, patch canonical.ProductPatch) (canonical.Product, error) {
+	set := bson.M{}
+	unset := bson.M{}
+	if patch.Name != nil {
+		set["name"] = *patch.Name
+	}
+	if patch.Category != nil {
+		set["category"] = *patch.Category
+	}
+	if patch.Price != nil {
+		set["price"] = *patch.Price
+		unset["inherits_price"] = ""
+	}
+	if patch.Stock != nil {
+		set["stock"] = *patch.Stock
+	}
+	if patch.PriceOverrides != nil {
+		set["price_overrides"] = *patch.PriceOverrides
+	}
+	if patch.StockLevels != nil {
+		set["stock_levels"] = *patch.StockLevels
+	}
+	if patch.Backorders != nil {
+		set["backorders"] = *patch.Backorders
+	}
+	if patch.ReorderThreshold != nil {
+		set["reorder_threshold"] = *patch.ReorderThreshold
+	}
+	if patch.Attributes != nil {
+		mergeAttributes(set, unset, *patch.Attributes)
+	}
+	if patch.Status != nil {
+		set["status"] = *patch.Status
+	}
+	if patch.PublishAt != nil {
+		set["publish_at"] = canonical.OptionalTime(*patch.PublishAt)
+	}
+	if patch.UnpublishAt != nil {
+		set["unpublish_at"] = canonical.OptionalTime(*patch.UnpublishAt)
+	}
+
+	update := bson.M{"$inc": bson.M{"version": 1}}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	return repo.findOneAndUpdate(ctx, id, version, liveFilter(id), update)
+}
+
+// mergeAttributes adds to set and unset the updates that merge members into
+// the attributes of a product, as canonical.MergeAttributes does.
+func mergeAttributes(set, unset bson.M, members map[string]any) {
+	if members == nil {
+		unset["attributes"] = ""
+		return
 	}
 
-	return productSlice, nil
+	for name, value := range members {
+		if value == nil {
+			unset["attributes."+name] = ""
+			continue
+		}
+
+		set["attributes."+name] = value
+	}
 }
 
-func (repo *repository) GetProductById(id string) (canonical.Product, error) {
+// findOneAndUpdate applies update to the product matching filter if it is
+// still at version and returns the document as stored after the update.
+func (repo *repository) findOneAndUpdate(ctx context.Context, id string, version int64, filter bson.D, update any) (canonical.Product, error) {
 	var product canonical.Product
 
-	err := repo.collection.FindOne(context.Background(), bson.D{
-		{
-			Key:   "_id",
-			Value: id,
-		},
-	}).Decode(&product)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := repo.collection.FindOneAndUpdate(ctx, versionFilter(filter, version), update, opts).Decode(&product)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return canonical.Product{}, repo.missingOrConflict(ctx, id, version, filter)
+	}
 
 	if err != nil {
-		return canonical.Product{}, err
+		return canonical.Product{}, translateError(err, id)
 	}
 
 	return product, nil
 }
 
-func (repo *repository) CreateProduct(product canonical.Product) (canonical.Product, error) {
-	_, err := repo.collection.InsertOne(context.Background(), product)
+// missingOrConflict tells apart a versioned write that matched nothing because
+// the product does not exist from one that lost a race with another writer.
+func (repo *repository) missingOrConflict(ctx context.Context, id string, version int64, filter bson.D) error {
+	count, err := repo.collection.CountDocuments(ctx, filter)
 	if err != nil {
-		return canonical.Product{}, err
+		return translateError(err, id)
 	}
 
-	return product, nil
+	if count == 0 {
+		return translateError(mongo.ErrNoDocuments, id)
+	}
+
+	return versionConflict(id, version)
 }
 
-func (repo *repository) UpdateProduct(id string, product canonical.Product) (canonical.Product, error) {
-	filter := bson.D{{Key: "_id", Value: id}}
-	fields := bson.M{
+// versionFilter narrows filter down to the product at version. Documents
+// written before versioning was introduced have no version field and count as
+// version 0.
+func versionFilter(filter bson.D, version int64) bson.D {
+	if version == 0 {
+		return append(filter, bson.E{Key: "version", Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}})
+	}
+
+	return append(filter, bson.E{Key: "version", Value: version})
+}
+
+// liveFilter matches the product with id unless it is in the trash.
+func liveFilter(id string) bson.D {
+	return bson.D{{Key: "_id", Value: id}, {Key: "deleted_at", Value: nil}}
+}
+
+// trashFilter matches the product with id only if it is in the trash.
+func trashFilter(id string) bson.D {
+	return bson.D{{Key: "_id", Value: id}, {Key: "deleted_at", Value: bson.D{{Key: "$ne", Value: nil}}}}
+}
+
+// DeleteProduct moves the product to the trash. It stays there until it is
+// restored or purged.
+func (repo *repository) DeleteProduct(ctx context.Context, id string, version int64, deletedAt time.Time, deletedBy string) error {
+	update := bson.M{
 		"$set": bson.M{
-			"name":     product.Name,
-			"category": product.Category,
-			"price":    product.Price,
-			"stock":    product.Stock,
+			"deleted_at": deletedAt,
+			"deleted_by": deletedBy,
 		},
+		"$inc": bson.M{"version": 1},
 	}
 
-	_, err := repo.collection.UpdateOne(context.Background(), filter, fields)
+	_, err := repo.findOneAndUpdate(ctx, id, version, liveFilter(id), update)
 
-	if err != nil {
-		return canonical.Product{}, err
+	return err
+}
+
+func (repo *repository) RestoreProduct(ctx context.Context, id string, version int64) (canonical.Product, error) {
+	update := bson.M{
+		"$unset": bson.M{
+			"deleted_at": "",
+			"deleted_by": "",
+		},
+		"$inc": bson.M{"version": 1},
 	}
 
-	return product, nil
+	return repo.findOneAndUpdate(ctx, id, version, trashFilter(id), update)
 }
 
-func (repo *repository) DeleteProduct(id string) error {
-	filter := bson.D{{Key: "_id", Value: id}}
+// PurgeDeletedProducts removes for good the products that went to the trash
+// before deletedBefore and returns their ids.
+func (repo *repository) PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	filter := bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$lte", Value: deletedBefore}}}}
+
+	res, err := repo.collection.Find(ctx, filter, options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	var documents []struct {
+		Id string `bson:"_id"`
+	}
+
+	err = res.All(ctx, &documents)
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	ids := make([]string, 0, len(documents))
+	for _, document := range documents {
+		ids = append(ids, document.Id)
+	}
+
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}})
 
-	_, err := repo.collection.DeleteOne(context.Background(), filter)
+	_, err = repo.collection.DeleteMany(ctx, filter)
 	if err != nil {
-		return err
+		return nil, errs.Internal(err)
 	}
 
-	return nil
+	return ids, nil
 }
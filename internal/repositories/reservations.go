@@ -0,0 +1,249 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReservationRepository stores the reservations that hold stock of products.
+// The stock itself is held and released through StockRepository.
+type ReservationRepository interface {
+	CreateReservation(ctx context.Context, reservation canonical.Reservation) error
+	GetReservation(ctx context.Context, id string) (canonical.Reservation, error)
+	// GetExpiredReservations returns up to limit held reservations that
+	// expired at or before now, oldest first.
+	GetExpiredReservations(ctx context.Context, now time.Time, limit int) ([]canonical.Reservation, error)
+	// CompleteReservation moves a held reservation to status and returns it.
+	// It fails with a conflict if the reservation is no longer held.
+	CompleteReservation(ctx context.Context, id, status string, completedAt time.Time) (canonical.Reservation, error)
+	// ReopenReservation moves a confirmed reservation back to held, for when
+	// the stock it holds could not be taken. It fails with a conflict if the
+	// reservation is not confirmed.
+	ReopenReservation(ctx context.Context, id string) (canonical.Reservation, error)
+}
+
+func (repo *repository) CreateReservation(ctx context.Context, reservation canonical.Reservation) error {
+	_, err := repo.reservations.InsertOne(ctx, reservation)
+	if err != nil {
+		return reservationError(err, reservation.Id)
+	}
+
+	return nil
+}
+
+func (repo *repository) GetReservation(ctx context.Context, id string) (canonical.Reservation, error) {
+	var reservation canonical.Reservation
+
+	err := repo.reservations.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&reservation)
+	if err != nil {
+		return canonical.Reservation{}, reservationError(err, id)
+	}
+
+	return reservation, nil
+}
+
+func (repo *repository) GetExpiredReservations(ctx context.Context, now time.Time, limit int) ([]canonical.Reservation, error) {
+	filter := bson.D{
+		{Key: "status", Value: canonical.ReservationHeld},
+		{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "expires_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+
+	res, err := repo.reservations.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	reservations := []canonical.Reservation{}
+
+	err = res.All(ctx, &reservations)
+	if err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	return reservations, nil
+}
+
+func (repo *repository) CompleteReservation(ctx context.Context, id, status string, completedAt time.Time) (canonical.Reservation, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "status", Value: canonical.ReservationHeld}}
+	update := bson.M{"$set": bson.M{"status": status, "completed_at": completedAt}}
+
+	var reservation canonical.Reservation
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := repo.reservations.FindOneAndUpdate(ctx, filter, update, opts).Decode(&reservation)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		count, err := repo.reservations.CountDocuments(ctx, bson.D{{Key: "_id", Value: id}})
+		if err != nil {
+			return canonical.Reservation{}, errs.Internal(err)
+		}
+
+		if count == 0 {
+			return canonical.Reservation{}, reservationError(mongo.ErrNoDocuments, id)
+		}
+
+		return canonical.Reservation{}, errs.Conflict("reservation " + id + " is no longer held")
+	}
+
+	if err != nil {
+		return canonical.Reservation{}, reservationError(err, id)
+	}
+
+	return reservation, nil
+}
+
+func (repo *repository) ReopenReservation(ctx context.Context, id string) (canonical.Reservation, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "status", Value: canonical.ReservationConfirmed}}
+	update := bson.M{
+		"$set":   bson.M{"status": canonical.ReservationHeld},
+		"$unset": bson.M{"completed_at": ""},
+	}
+
+	var reservation canonical.Reservation
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := repo.reservations.FindOneAndUpdate(ctx, filter, update, opts).Decode(&reservation)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return canonical.Reservation{}, errs.Conflict("reservation " + id + " is not confirmed")
+	}
+
+	if err != nil {
+		return canonical.Reservation{}, reservationError(err, id)
+	}
+
+	return reservation, nil
+}
+
+func reservationError(err error, id string) error {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return errs.NotFound("reservation " + id + " not found")
+	case mongo.IsDuplicateKeyError(err):
+		return errs.Conflict("reservation " + id + " already exists")
+	default:
+		return errs.Internal(err)
+	}
+}
+
+func (repo *memoryRepository) CreateReservation(ctx context.Context, reservation canonical.Reservation) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.reservations[reservation.Id]; ok {
+		return errs.Conflict("reservation " + reservation.Id + " already exists")
+	}
+
+	repo.reservations[reservation.Id] = reservation
+
+	return nil
+}
+
+func (repo *memoryRepository) GetReservation(ctx context.Context, id string) (canonical.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Reservation{}, errs.Internal(err)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	reservation, ok := repo.reservations[id]
+	if !ok {
+		return canonical.Reservation{}, errs.NotFound("reservation " + id + " not found")
+	}
+
+	return reservation, nil
+}
+
+func (repo *memoryRepository) GetExpiredReservations(ctx context.Context, now time.Time, limit int) ([]canonical.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errs.Internal(err)
+	}
+
+	repo.mu.RLock()
+	reservations := []canonical.Reservation{}
+	for _, reservation := range repo.reservations {
+		if reservation.Status == canonical.ReservationHeld && !reservation.ExpiresAt.After(now) {
+			reservations = append(reservations, reservation)
+		}
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(reservations, func(i, j int) bool {
+		if !reservations[i].ExpiresAt.Equal(reservations[j].ExpiresAt) {
+			return reservations[i].ExpiresAt.Before(reservations[j].ExpiresAt)
+		}
+
+		return reservations[i].Id < reservations[j].Id
+	})
+
+	if len(reservations) > limit {
+		reservations = reservations[:limit]
+	}
+
+	return reservations, nil
+}
+
+func (repo *memoryRepository) CompleteReservation(ctx context.Context, id, status string, completedAt time.Time) (canonical.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Reservation{}, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	reservation, ok := repo.reservations[id]
+	if !ok {
+		return canonical.Reservation{}, errs.NotFound("reservation " + id + " not found")
+	}
+
+	if reservation.Status != canonical.ReservationHeld {
+		return canonical.Reservation{}, errs.Conflict("reservation " + id + " is no longer held")
+	}
+
+	reservation.Status = status
+	reservation.CompletedAt = &completedAt
+	repo.reservations[id] = reservation
+
+	return reservation, nil
+}
+
+func (repo *memoryRepository) ReopenReservation(ctx context.Context, id string) (canonical.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Reservation{}, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	reservation, ok := repo.reservations[id]
+	if !ok {
+		return canonical.Reservation{}, errs.NotFound("reservation " + id + " not found")
+	}
+
+	if reservation.Status != canonical.ReservationConfirmed {
+		return canonical.Reservation{}, errs.Conflict("reservation " + id + " is not confirmed")
+	}
+
+	reservation.Status = canonical.ReservationHeld
+	reservation.CompletedAt = nil
+	repo.reservations[id] = reservation
+
+	return reservation, nil
+}
@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Matches in the product name weigh more than matches in its category, both in
+// the MongoDB text index and in the in-memory search.
+const (
+	nameWeight     = 2
+	categoryWeight = 1
+)
+
+// skuIndex is the name of the unique index on the SKU of variants, which
+// CreateProduct looks for in duplicate key errors.
+const skuIndex = "products_sku"
+
+// CreateMongoIndexes creates the indexes the MongoDB repository relies on. It
+// is safe to call on every startup.
+func CreateMongoIndexes(ctx context.Context, db *mongo.Database) error {
+	indexes := []struct {
+		collection string
+		model      mongo.IndexModel
+	}{
+		{
+			collection: "productSlice",
+			model: mongo.IndexModel{
+				Keys: bson.D{
+					{Key: "name", Value: "text"},
+					{Key: "category", Value: "text"},
+				},
+				Options: options.Index().
+					SetName("products_text").
+					SetWeights(bson.D{
+						{Key: "name", Value: nameWeight},
+						{Key: "category", Value: categoryWeight},
+					}),
+			},
+		},
+		{
+			collection: "productAudit",
+			model:      mongo.IndexModel{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "_id", Value: -1}}},
+		},
+		{
+			collection: "productPrices",
+			model:      mongo.IndexModel{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "effective_at", Value: 1}}},
+		},
+		{
+			collection: "productPrices",
+			model:      mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "effective_at", Value: 1}}},
+		},
+		{
+			collection: "productReservations",
+			model:      mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}}},
+		},
+		{
+			collection: "productStockMovements",
+			model:      mongo.IndexModel{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "_id", Value: -1}}},
+		},
+		{
+			collection: "productCategories",
+			model:      mongo.IndexModel{Keys: bson.D{{Key: "path", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		{
+			collection: "productSlice",
+			model:      mongo.IndexModel{Keys: bson.D{{Key: "category", Value: 1}}},
+		},
+		{
+			collection: "productSlice",
+			model:      mongo.IndexModel{Keys: bson.D{{Key: "parent_id", Value: 1}}},
+		},
+		{
+			collection: "productSlice",
+			model: mongo.IndexModel{
+				Keys: bson.D{{Key: "sku", Value: 1}},
+				Options: options.Index().
+					SetName(skuIndex).
+					SetUnique(true).
+					SetPartialFilterExpression(bson.D{{Key: "sku", Value: bson.D{{Key: "$exists", Value: true}}}}),
+			},
+		},
+	}
+
+	for _, index := range indexes {
+		_, err := db.Collection(index.collection).Indexes().CreateOne(ctx, index.model)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type scoredProduct struct {
+	canonical.Product `bson:",inline"`
+	Score             float64 `bson:"score"`
+}
+
+func (repo *repository) SearchProducts(ctx context.Context, query string, statuses []string, page canonical.PageRequest) (canonical.SearchPage, error) {
+	offset, err := decodeOffsetCursor(page.Cursor)
+	if err != nil {
+		return canonical.SearchPage{}, err
+	}
+
+	score := bson.D{{Key: "$meta", Value: "textScore"}}
+	filter := bson.D{
+		{Key: "$text", Value: bson.D{{Key: "$search", Value: query}}},
+		{Key: "deleted_at", Value: nil},
+		{Key: "parent_id", Value: nil},
+	}
+	filter = append(filter, windowFilter(visibility.FromContext(ctx))...)
+
+	if statuses != nil {
+		filter = append(filter, bson.E{Key: "status", Value: bson.D{{Key: "$in", Value: statuses}}})
+	}
+
+	opts := options.Find().
+		SetProjection(bson.D{{Key: "score", Value: score}}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(page.Limit + 1))
+
+	res, err := repo.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return canonical.SearchPage{}, errs.Internal(err)
+	}
+
+	defer res.Close(ctx)
+
+	results := []canonical.SearchResult{}
+
+	for res.Next(ctx) {
+		var product scoredProduct
+
+		err := res.Decode(&product)
+		if err != nil {
+			return canonical.SearchPage{}, errs.Internal(err)
+		}
+
+		results = append(results, canonical.SearchResult{Product: product.Product, Score: product.Score})
+	}
+
+	if err := res.Err(); err != nil {
+		return canonical.SearchPage{}, errs.Internal(err)
+	}
+
+	return newSearchPage(results, offset, page.Limit), nil
+}
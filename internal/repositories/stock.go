@@ -0,0 +1,195 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StockRepository stores the stock of products per warehouse.
+type StockRepository interface {
+	// SetStockLevels replaces the stock levels of a product that is still at
+	// version and sets its stock to their sum.
+	SetStockLevels(ctx context.Context, id string, version int64, levels []canonical.StockLevel) (canonical.Product, error)
+	// ReserveStock holds quantity of the stock of a product in a single
+	// conditional write. It fails with a conflict if less than quantity is
+	// available, unless the product allows backorders. Like any other write,
+	// it moves the product to its next version, so that writes made from an
+	// earlier read see the stock it holds.
+	ReserveStock(ctx context.Context, id string, quantity int) (canonical.Product, error)
+	// ReleaseStock gives back quantity held by ReserveStock, moving the
+	// product to its next version. Stock held for products that were deleted
+	// since is released as well.
+	ReleaseStock(ctx context.Context, id string, quantity int) error
+	// TakeReservedStock is SetStockLevels for stock that leaves the warehouses
+	// after being held: the levels are set and quantity is released in the
+	// same write.
+	TakeReservedStock(ctx context.Context, id string, version int64, levels []canonical.StockLevel, quantity int) (canonical.Product, error)
+}
+
+func (repo *repository) SetStockLevels(ctx context.Context, id string, version int64, levels []canonical.StockLevel) (canonical.Product, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"stock_levels": levels,
+			"stock":        canonical.TotalStock(levels),
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	return repo.findOneAndUpdate(ctx, id, version, liveFilter(id), update)
+}
+
+func (repo *repository) ReserveStock(ctx context.Context, id string, quantity int) (canonical.Product, error) {
+	available := bson.D{{Key: "$subtract", Value: bson.A{"$stock", bson.D{{Key: "$ifNull", Value: bson.A{"$reserved", 0}}}}}}
+
+	filter := append(liveFilter(id), bson.E{Key: "$or", Value: bson.A{
+		bson.D{{Key: "backorders", Value: true}},
+		bson.D{{Key: "$expr", Value: bson.D{{Key: "$gte", Value: bson.A{available, quantity}}}}},
+	}})
+
+	var product canonical.Product
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	err := repo.collection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"reserved": quantity, "version": 1}}, opts).Decode(&product)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		count, err := repo.collection.CountDocuments(ctx, liveFilter(id))
+		if err != nil {
+			return canonical.Product{}, translateError(err, id)
+		}
+
+		if count == 0 {
+			return canonical.Product{}, translateError(mongo.ErrNoDocuments, id)
+		}
+
+		return canonical.Product{}, insufficientStock(id, quantity)
+	}
+
+	if err != nil {
+		return canonical.Product{}, translateError(err, id)
+	}
+
+	return product, nil
+}
+
+func (repo *repository) ReleaseStock(ctx context.Context, id string, quantity int) error {
+	res, err := repo.collection.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, bson.M{"$inc": bson.M{"reserved": -quantity, "version": 1}})
+	if err != nil {
+		return translateError(err, id)
+	}
+
+	if res.MatchedCount == 0 {
+		return translateError(mongo.ErrNoDocuments, id)
+	}
+
+	return nil
+}
+
+func (repo *repository) TakeReservedStock(ctx context.Context, id string, version int64, levels []canonical.StockLevel, quantity int) (canonical.Product, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"stock_levels": levels,
+			"stock":        canonical.TotalStock(levels),
+		},
+		"$inc": bson.M{"version": 1, "reserved": -quantity},
+	}
+
+	return repo.findOneAndUpdate(ctx, id, version, liveFilter(id), update)
+}
+
+func insufficientStock(id string, quantity int) error {
+	return errs.Conflict("product " + id + " has less than " + strconv.Itoa(quantity) + " available")
+}
+
+func (repo *memoryRepository) SetStockLevels(ctx context.Context, id string, version int64, levels []canonical.StockLevel) (canonical.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Product{}, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, err := repo.getForWrite(id, version, false)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	stored.StockLevels = levels
+	stored.Stock = canonical.TotalStock(levels)
+	stored.Version++
+	repo.products[id] = stored
+
+	return stored, nil
+}
+
+func (repo *memoryRepository) ReserveStock(ctx context.Context, id string, quantity int) (canonical.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Product{}, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.products[id]
+	if !ok || stored.DeletedAt != nil {
+		return canonical.Product{}, errs.NotFound("product " + id + " not found")
+	}
+
+	if !stored.Backorders && stored.Available() < quantity {
+		return canonical.Product{}, insufficientStock(id, quantity)
+	}
+
+	stored.Reserved += quantity
+	stored.Version++
+	repo.products[id] = stored
+
+	return stored, nil
+}
+
+func (repo *memoryRepository) ReleaseStock(ctx context.Context, id string, quantity int) error {
+	if err := ctx.Err(); err != nil {
+		return errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.products[id]
+	if !ok {
+		return errs.NotFound("product " + id + " not found")
+	}
+
+	stored.Reserved -= quantity
+	stored.Version++
+	repo.products[id] = stored
+
+	return nil
+}
+
+func (repo *memoryRepository) TakeReservedStock(ctx context.Context, id string, version int64, levels []canonical.StockLevel, quantity int) (canonical.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return canonical.Product{}, errs.Internal(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, err := repo.getForWrite(id, version, false)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	stored.StockLevels = levels
+	stored.Stock = canonical.TotalStock(levels)
+	stored.Reserved -= quantity
+	stored.Version++
+	repo.products[id] = stored
+
+	return stored, nil
+}
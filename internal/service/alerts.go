@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+)
+
+// alertBatchSize bounds how many low-stock alerts a single run sends; the
+// rest are sent by the next run.
+const alertBatchSize = 100
+
+func (service *service) GetLowStockAlerts(ctx context.Context, page canonical.PageRequest) (canonical.LowStockAlertPage, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_low_stock_alerts")
+	defer cancel()
+
+	alertPage, err := service.repo.GetLowStockAlerts(ctx, normalizePage(page))
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get low-stock alerts")
+		return canonical.LowStockAlertPage{}, errs.Wrap(err, "error occurred while trying to get low-stock alerts")
+	}
+
+	return alertPage, nil
+}
+
+// SetCategoryThreshold sets the reorder threshold of the products of category
+// that have none of their own. Zero removes it. Those products are checked
+// against the new threshold right away, so alerts are opened and closed as if
+// their stock had just changed. Thresholds are not inherited by
+// subcategories.
+func (service *service) SetCategoryThreshold(ctx context.Context, category string, threshold int) (canonical.CategoryThreshold, error) {
+	ctx, cancel := service.withTimeout(ctx, "set_category_threshold")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	stored, err := service.getCategory(ctx, category)
+	if err != nil {
+		return canonical.CategoryThreshold{}, err
+	}
+
+	if threshold < 0 {
+		return canonical.CategoryThreshold{}, errs.InvalidFields(errs.FieldError{Field: "threshold", Message: "must not be negative"})
+	}
+
+	err = service.repo.SetCategoryThreshold(ctx, stored.Slug, threshold)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to set a category threshold")
+		return canonical.CategoryThreshold{}, errs.Wrap(err, "error occurred while trying to set a category threshold")
+	}
+
+	page := canonical.PageRequest{Limit: maxPageLimit}
+	for {
+		productPage, err := service.repo.GetProductsByCategory(ctx, []string{stored.Slug}, nil, page)
+		if err != nil {
+			service.logger.WithError(err).Error("error occurred while trying to get products by category")
+			return canonical.CategoryThreshold{}, errs.Wrap(err, "error occurred while trying to get products by category")
+		}
+
+		for _, product := range productPage.Products {
+			if product.ReorderThreshold == 0 {
+				service.evaluateLowStock(ctx, product, threshold)
+			}
+		}
+
+		if !productPage.HasMore {
+			break
+		}
+
+		page.Cursor = productPage.NextCursor
+	}
+
+	return canonical.CategoryThreshold{Category: stored.Slug, Threshold: threshold}, nil
+}
+
+// SendLowStockAlerts sends the open alerts that have not been sent yet and
+// returns how many it sent. An alert that fails to send is left for the next
+// run.
+func (service *service) SendLowStockAlerts(ctx context.Context) (int, error) {
+	if service.notifier == nil {
+		return 0, nil
+	}
+
+	ctx, cancel := service.withTimeout(ctx, "send_low_stock_alerts")
+	defer cancel()
+
+	alerts, err := service.repo.GetUnsentLowStockAlerts(ctx, alertBatchSize)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get unsent low-stock alerts")
+		return 0, errs.Wrap(err, "error occurred while trying to get unsent low-stock alerts")
+	}
+
+	sent := 0
+	for _, alert := range alerts {
+		logger := service.logger.WithField("product_id", alert.ProductId)
+
+		err := service.notifier.NotifyLowStock(ctx, alert)
+		if err != nil {
+			logger.WithError(err).Error("error occurred while trying to send a low-stock alert")
+			continue
+		}
+
+		err = service.repo.MarkLowStockAlertSent(ctx, alert, time.Now())
+		if err != nil {
+			logger.WithError(err).Error("error occurred while trying to mark a low-stock alert as sent")
+			continue
+		}
+
+		sent++
+	}
+
+	if sent > 0 {
+		service.logger.WithField("sent", sent).Info("sent low-stock alerts")
+	}
+
+	return sent, nil
+}
+
+// checkLowStock opens a low-stock alert when a write took the stock of a
+// product below its reorder threshold, and closes it once the product is
+// restocked. Only writes that changed the stock, the threshold or the category
+// are checked. Like audit, failures are only logged.
+func (service *service) checkLowStock(ctx context.Context, before, after canonical.Product) {
+	if before.Stock == after.Stock && before.ReorderThreshold == after.ReorderThreshold && before.Category == after.Category {
+		return
+	}
+
+	threshold := after.ReorderThreshold
+	if threshold == 0 {
+		var err error
+
+		threshold, err = service.repo.GetCategoryThreshold(ctx, after.Category)
+		if err != nil {
+			service.logger.WithError(err).
+				WithField("product_id", after.Id).
+				Error("error occurred while trying to get a category threshold")
+			return
+		}
+	}
+
+	service.evaluateLowStock(ctx, after, threshold)
+}
+
+// evaluateLowStock opens or closes the low-stock alert of product against
+// threshold. Opened alerts are sent later by SendLowStockAlerts, so a slow or
+// failing notifier never holds up a write.
+func (service *service) evaluateLowStock(ctx context.Context, product canonical.Product, threshold int) {
+	if threshold == 0 || product.Stock >= threshold {
+		service.closeLowStockAlert(ctx, product.Id)
+		return
+	}
+
+	alert := canonical.LowStockAlert{
+		ProductId: product.Id,
+		Name:      product.Name,
+		Category:  product.Category,
+		Stock:     product.Stock,
+		Threshold: threshold,
+		CreatedAt: time.Now(),
+	}
+
+	_, err := service.repo.OpenLowStockAlert(ctx, alert)
+	if err != nil {
+		service.logger.WithError(err).
+			WithField("product_id", product.Id).
+			Error("error occurred while trying to open a low-stock alert")
+	}
+}
+
+func (service *service) closeLowStockAlert(ctx context.Context, productId string) {
+	err := service.repo.CloseLowStockAlert(ctx, productId)
+	if err != nil {
+		service.logger.WithError(err).
+			WithField("product_id", productId).
+			Error("error occurred while trying to close a low-stock alert")
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+)
+
+// checkAttributes checks the attributes of a product against the schema of
+// its category: required attributes must be there, values must be of the type
+// of their definition and attributes the schema does not define are rejected.
+func checkAttributes(category canonical.Category, attributes map[string]any) error {
+	var fields []errs.FieldError
+
+	invalid := func(name, message string) {
+		fields = append(fields, errs.FieldError{Field: "attributes." + name, Message: message})
+	}
+
+	defined := map[string]bool{}
+
+	for _, definition := range category.Attributes {
+		defined[definition.Name] = true
+
+		value, ok := attributes[definition.Name]
+		if !ok || value == nil {
+			if definition.Required {
+				invalid(definition.Name, "is required")
+			}
+
+			continue
+		}
+
+		if message := checkAttributeValue(definition, value); message != "" {
+			invalid(definition.Name, message)
+		}
+	}
+
+	var undefined []string
+	for name := range attributes {
+		if !defined[name] {
+			undefined = append(undefined, name)
+		}
+	}
+
+	sort.Strings(undefined)
+
+	for _, name := range undefined {
+		invalid(name, "is not defined for category "+category.Slug)
+	}
+
+	if len(fields) > 0 {
+		return errs.InvalidFields(fields...)
+	}
+
+	return nil
+}
+
+// checkAttributeNames rejects attribute names that could not be defined by any
+// schema, which patches may name even to remove them.
+func checkAttributeNames(attributes map[string]any) error {
+	var fields []errs.FieldError
+
+	for name := range attributes {
+		if !canonical.ValidAttributeName(name) {
+			fields = append(fields, errs.FieldError{Field: "attributes." + name, Message: "is not a valid attribute name"})
+		}
+	}
+
+	if len(fields) > 0 {
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+		return errs.InvalidFields(fields...)
+	}
+
+	return nil
+}
+
+// checkAttributeValue returns what is wrong with value, or an empty string if
+// it fits definition.
+func checkAttributeValue(definition canonical.AttributeDefinition, value any) string {
+	switch definition.Type {
+	case canonical.AttributeString:
+		if _, ok := value.(string); !ok {
+			return "must be a string"
+		}
+	case canonical.AttributeNumber:
+		if _, ok := canonical.AttributeNumberValue(value); !ok {
+			return "must be a number"
+		}
+	case canonical.AttributeBool:
+		if _, ok := value.(bool); !ok {
+			return "must be true or false"
+		}
+	case canonical.AttributeEnum:
+		if text, ok := value.(string); !ok || !slices.Contains(definition.Values, text) {
+			return "must be one of " + strings.Join(definition.Values, ", ")
+		}
+	}
+
+	return ""
+}
+
+// checkSchema rejects attribute schemas with invalid or repeated names,
+// unknown types and enums without values.
+func checkSchema(schema []canonical.AttributeDefinition) error {
+	var fields []errs.FieldError
+
+	invalid := func(i int, message string) {
+		fields = append(fields, errs.FieldError{Field: fmt.Sprintf("attributes[%d]", i), Message: message})
+	}
+
+	seen := map[string]bool{}
+
+	for i, definition := range schema {
+		switch {
+		case !canonical.ValidAttributeName(definition.Name):
+			invalid(i, "name must be up to 40 lower case letters, digits and underscores, starting with a letter")
+		case seen[definition.Name]:
+			invalid(i, "name "+definition.Name+" is already defined")
+		case !slices.Contains(canonical.AttributeTypes, definition.Type):
+			invalid(i, "type must be one of "+strings.Join(canonical.AttributeTypes, ", "))
+		case definition.Type == canonical.AttributeEnum && len(definition.Values) == 0:
+			invalid(i, "values must not be empty for an enum")
+		case definition.Type != canonical.AttributeEnum && len(definition.Values) > 0:
+			invalid(i, "values are only allowed for an enum")
+		}
+
+		seen[definition.Name] = true
+	}
+
+	if len(fields) > 0 {
+		return errs.InvalidFields(fields...)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+)
+
+func (service *service) GetProductHistory(ctx context.Context, id string, page canonical.PageRequest) (canonical.AuditPage, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_product_history")
+	defer cancel()
+
+	auditPage, err := service.repo.GetAuditEntries(ctx, id, normalizePage(page))
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get product history")
+		return canonical.AuditPage{}, errs.Wrap(err, "error occurred while trying to get product history")
+	}
+
+	return auditPage, nil
+}
+
+// audit records that operation took product from before to after. The
+// mutation has already been stored by then, so a failure to write the entry
+// is logged rather than returned to the caller.
+func (service *service) audit(ctx context.Context, operation, name string, before, after canonical.Product) {
+	entry := canonical.AuditEntry{
+		Id:        uuid.Must(uuid.NewV7()).String(),
+		ProductId: after.Id,
+		Operation: operation,
+		Actor:     name,
+		Timestamp: time.Now(),
+		Changes:   diffProducts(before, after),
+	}
+
+	if entry.ProductId == "" {
+		entry.ProductId = before.Id
+	}
+
+	err := service.repo.AppendAuditEntry(ctx, entry)
+	if err != nil {
+		service.logger.WithError(err).
+			WithField("product_id", entry.ProductId).
+			WithField("operation", operation).
+			Error("error occurred while trying to write an audit entry")
+	}
+}
+
+// diffProducts lists the fields that differ between before and after. A zero
+// product stands for one that did not exist.
+func diffProducts(before, after canonical.Product) []canonical.FieldChange {
+	changes := []canonical.FieldChange{}
+
+	add := func(field string, before, after any, changed bool) {
+		if changed {
+			changes = append(changes, canonical.FieldChange{Field: field, Before: before, After: after})
+		}
+	}
+
+	add(canonical.FieldName, before.Name, after.Name, before.Name != after.Name)
+	add(canonical.FieldCategory, before.Category, after.Category, before.Category != after.Category)
+	add(canonical.FieldPrice, moneyValue(before.Price), moneyValue(after.Price), before.Price != after.Price)
+	add(canonical.FieldStock, before.Stock, after.Stock, before.Stock != after.Stock)
+	add("status", before.Status, after.Status, before.Status != after.Status)
+	add("price_overrides", moneyValues(before.PriceOverrides), moneyValues(after.PriceOverrides), !slices.Equal(before.PriceOverrides, after.PriceOverrides))
+	add("stock_levels", stockValues(before.StockLevels), stockValues(after.StockLevels), !slices.Equal(before.StockLevels, after.StockLevels))
+	add("reserved", before.Reserved, after.Reserved, before.Reserved != after.Reserved)
+	add("backorders", before.Backorders, after.Backorders, before.Backorders != after.Backorders)
+	add("reorder_threshold", before.ReorderThreshold, after.ReorderThreshold, before.ReorderThreshold != after.ReorderThreshold)
+	add("attributes", before.Attributes, after.Attributes, !canonical.EqualAttributes(before.Attributes, after.Attributes))
+	add("publish_at", timeValue(before.PublishAt), timeValue(after.PublishAt), !equalTimes(before.PublishAt, after.PublishAt))
+	add("unpublish_at", timeValue(before.UnpublishAt), timeValue(after.UnpublishAt), !equalTimes(before.UnpublishAt, after.UnpublishAt))
+	add("deleted_at", timeValue(before.DeletedAt), timeValue(after.DeletedAt), !equalTimes(before.DeletedAt, after.DeletedAt))
+	add("deleted_by", before.DeletedBy, after.DeletedBy, before.DeletedBy != after.DeletedBy)
+
+	return changes
+}
+
+// moneyValue keeps prices readable in the audit log, such as "19.99 BRL".
+func moneyValue(m money.Money) any {
+	if m.IsZero() {
+		return nil
+	}
+
+	return m.String()
+}
+
+func moneyValues(prices []money.Money) any {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(prices))
+	for _, price := range prices {
+		values = append(values, price.String())
+	}
+
+	return values
+}
+
+// stockValues shows stock levels as quantities by warehouse.
+func stockValues(levels []canonical.StockLevel) any {
+	if len(levels) == 0 {
+		return nil
+	}
+
+	values := make(map[string]int, len(levels))
+	for _, level := range levels {
+		values[level.Warehouse] = level.Quantity
+	}
+
+	return values
+}
+
+func timeValue(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return *t
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
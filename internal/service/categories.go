@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/validation"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+)
+
+// maxSlugLength matches the longest category products could be stored with.
+const maxSlugLength = 60
+
+func (service *service) GetCategories(ctx context.Context) ([]canonical.Category, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_categories")
+	defer cancel()
+
+	categories, err := service.repo.GetCategories(ctx)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get categories")
+		return nil, errs.Wrap(err, "error occurred while trying to get categories")
+	}
+
+	return categories, nil
+}
+
+func (service *service) GetCategory(ctx context.Context, slug string) (canonical.Category, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_category")
+	defer cancel()
+
+	return service.getCategory(ctx, slug)
+}
+
+// CreateCategory adds category under its parent, or as a root category if it
+// has none. Without a slug, one is made from the name.
+func (service *service) CreateCategory(ctx context.Context, category canonical.Category) (canonical.Category, error) {
+	ctx, cancel := service.withTimeout(ctx, "create_category")
+	defer cancel()
+
+	if category.Slug == "" {
+		category.Slug = canonical.Slugify(category.Name)
+	}
+
+	err := validateCategory(category)
+	if err != nil {
+		return canonical.Category{}, err
+	}
+
+	category.Parent = canonical.Slugify(category.Parent)
+
+	parentPath, err := service.parentPath(ctx, category.Parent)
+	if err != nil {
+		return canonical.Category{}, err
+	}
+
+	category.Path = canonical.CategoryPath(parentPath, category.Slug)
+	category.CreatedAt = time.Now()
+	category.Version = 1
+
+	category, err = service.repo.CreateCategory(ctx, category)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to create a category")
+		return canonical.Category{}, errs.Wrap(err, "error occurred while trying to create a category")
+	}
+
+	return category, nil
+}
+
+// UpdateCategory renames a category, changes its attribute schema and moves
+// it, with its subtree, under another parent. The slug cannot be changed,
+// since products refer to it. Products are checked against a new schema the
+// next time they are written.
+func (service *service) UpdateCategory(ctx context.Context, slug string, version int64, category canonical.Category) (canonical.Category, error) {
+	ctx, cancel := service.withTimeout(ctx, "update_category")
+	defer cancel()
+
+	slug = canonical.Slugify(slug)
+	category.Slug = slug
+
+	err := validateCategory(category)
+	if err != nil {
+		return canonical.Category{}, err
+	}
+
+	before, err := service.getCategory(ctx, slug)
+	if err != nil {
+		return canonical.Category{}, err
+	}
+
+	category.Parent = canonical.Slugify(category.Parent)
+
+	parentPath, err := service.parentPath(ctx, category.Parent)
+	if err != nil {
+		return canonical.Category{}, err
+	}
+
+	if category.Parent != "" && canonical.InSubtree(parentPath, before.Path) {
+		return canonical.Category{}, errs.InvalidFields(errs.FieldError{Field: "parent", Message: "must not be the category itself or one of its subcategories"})
+	}
+
+	category.Path = canonical.CategoryPath(parentPath, slug)
+
+	category, err = service.repo.UpdateCategory(ctx, slug, version, category)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to update a category")
+		return canonical.Category{}, errs.Wrap(err, "error occurred while trying to update a category")
+	}
+
+	return category, nil
+}
+
+// DeleteCategory removes a category that has neither subcategories nor
+// products, counting those in the trash, which could otherwise be restored
+// into a category that no longer exists.
+func (service *service) DeleteCategory(ctx context.Context, slug string, version int64) error {
+	ctx, cancel := service.withTimeout(ctx, "delete_category")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	slug = canonical.Slugify(slug)
+
+	category, err := service.getCategory(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	subtree, err := service.repo.GetCategorySubtree(ctx, category.Path)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get subcategories")
+		return errs.Wrap(err, "error occurred while trying to get subcategories")
+	}
+
+	if len(subtree) > 1 {
+		return errs.Conflict("category " + slug + " still has subcategories")
+	}
+
+	for _, deleted := range []bool{false, true} {
+		productPage, err := service.repo.GetAllProducts(ctx, canonical.ProductQuery{Category: slug, Deleted: deleted}, canonical.PageRequest{Limit: 1})
+		if err != nil {
+			service.logger.WithError(err).Error("error occurred while trying to get all products")
+			return errs.Wrap(err, "error occurred while trying to get all products")
+		}
+
+		if len(productPage.Products) > 0 {
+			return errs.Conflict("category " + slug + " still has products")
+		}
+	}
+
+	err = service.repo.DeleteCategory(ctx, slug, version)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to delete a category")
+		return errs.Wrap(err, "error occurred while trying to delete a category")
+	}
+
+	return nil
+}
+
+func (service *service) getCategory(ctx context.Context, slug string) (canonical.Category, error) {
+	category, err := service.repo.GetCategory(ctx, canonical.Slugify(slug))
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a category")
+		return canonical.Category{}, errs.Wrap(err, "error occurred while trying to get a category")
+	}
+
+	return category, nil
+}
+
+// parentPath returns the path of the parent of a category, which must exist.
+func (service *service) parentPath(ctx context.Context, parent string) (string, error) {
+	if parent == "" {
+		return "", nil
+	}
+
+	category, err := service.repo.GetCategory(ctx, parent)
+	if errs.KindOf(err) == errs.KindNotFound {
+		return "", errs.InvalidFields(errs.FieldError{Field: "parent", Message: "must be an existing category"})
+	}
+
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a category")
+		return "", errs.Wrap(err, "error occurred while trying to get a category")
+	}
+
+	return category.Path, nil
+}
+
+// categoryOf returns the category a product is put in, which must exist.
+// Names are accepted too, so "Electronics" is put in electronics.
+func (service *service) categoryOf(ctx context.Context, category string) (canonical.Category, error) {
+	stored, err := service.repo.GetCategory(ctx, canonical.Slugify(category))
+	if errs.KindOf(err) == errs.KindNotFound {
+		return canonical.Category{}, errs.InvalidFields(errs.FieldError{Field: canonical.FieldCategory, Message: "must be an existing category"})
+	}
+
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a category")
+		return canonical.Category{}, errs.Wrap(err, "error occurred while trying to get a category")
+	}
+
+	return stored, nil
+}
+
+func validateCategory(category canonical.Category) error {
+	err := validation.Struct(category)
+	if err == nil {
+		err = checkSlug(category.Slug)
+	}
+
+	if err == nil {
+		err = checkSchema(category.Attributes)
+	}
+
+	if err != nil {
+		return errs.Wrap(err, "invalid category")
+	}
+
+	return nil
+}
+
+// checkSlug rejects slugs that Slugify would not have produced.
+func checkSlug(slug string) error {
+	var message string
+
+	switch {
+	case slug == "":
+		message = "is required"
+	case utf8.RuneCountInString(slug) > maxSlugLength:
+		message = fmt.Sprintf("must have at most %d characters", maxSlugLength)
+	case slug != canonical.Slugify(slug):
+		message = "must be lower case letters and digits separated by hyphens"
+	default:
+		return nil
+	}
+
+	return errs.InvalidFields(errs.FieldError{Field: "slug", Message: message})
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+)
+
+// GetLocalizedProduct returns a product with its price in currency: the base
+// price if it is in that currency, else the override for it, else the base
+// price converted at the rate of the FX rate provider.
+func (service *service) GetLocalizedProduct(ctx context.Context, id string, currency money.Currency) (canonical.LocalizedProduct, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_localized_product")
+	defer cancel()
+
+	product, err := service.repo.GetProductById(ctx, id)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return canonical.LocalizedProduct{}, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	product, err = service.withVariants(ctx, product)
+	if err != nil {
+		return canonical.LocalizedProduct{}, err
+	}
+
+	localized := canonical.LocalizedProduct{Product: product}
+
+	if product.Price.Currency == currency {
+		localized.Price = product.Price
+		localized.PriceSource = canonical.PriceSourceBase
+
+		return localized, nil
+	}
+
+	for _, override := range product.PriceOverrides {
+		if override.Currency == currency {
+			localized.Price = override
+			localized.PriceSource = canonical.PriceSourceOverride
+
+			return localized, nil
+		}
+	}
+
+	rate, err := service.rates.Rate(ctx, product.Price.Currency, currency)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get an exchange rate")
+		return canonical.LocalizedProduct{}, errs.Wrap(err, "error occurred while trying to get an exchange rate")
+	}
+
+	localized.Price = product.Price.Convert(currency, rate.Rate)
+	localized.PriceSource = canonical.PriceSourceConverted
+	localized.Rate = &rate
+
+	return localized, nil
+}
+
+// checkPriceOverrides rejects overrides that are negative or that repeat a
+// currency, including the one of the base price.
+func checkPriceOverrides(product canonical.Product) error {
+	seen := map[money.Currency]bool{product.Price.Currency: true}
+
+	for _, override := range product.PriceOverrides {
+		switch {
+		case override.IsZero():
+			return errs.InvalidFields(errs.FieldError{Field: "price_overrides", Message: "must not contain null prices"})
+		case override.Amount < 0:
+			return errs.InvalidFields(errs.FieldError{Field: "price_overrides", Message: "must not contain negative prices"})
+		case seen[override.Currency]:
+			return errs.InvalidFields(errs.FieldError{Field: "price_overrides", Message: fmt.Sprintf("has more than one price in %s", override.Currency)})
+		}
+
+		seen[override.Currency] = true
+	}
+
+	return nil
+}
@@ -1,6 +1,10 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/nelsonalves117/go-products-api/internal/canonical"
 	"github.com/stretchr/testify/mock"
 )
@@ -9,32 +13,228 @@ type MockRepository struct {
 	mock.Mock
 }
 
-func (m *MockRepository) GetAllProducts() ([]canonical.Product, error) {
-	args := m.Called()
-	return args.Get(0).([]canonical.Product), args.Error(1)
+func (m *MockRepository) GetAllProducts(ctx context.Context, query canonical.ProductQuery, page canonical.PageRequest) (canonical.ProductPage, error) {
+	args := m.Called(ctx, query, page)
+	return args.Get(0).(canonical.ProductPage), args.Error(1)
+}
+
+func (m *MockRepository) GetProductsByCategory(ctx context.Context, categories, statuses []string, page canonical.PageRequest) (canonical.ProductPage, error) {
+	args := m.Called(ctx, categories, statuses, page)
+	return args.Get(0).(canonical.ProductPage), args.Error(1)
+}
+
+func (m *MockRepository) GetProductById(ctx context.Context, id string) (canonical.Product, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(canonical.Product), args.Error(1)
+}
+
+func (m *MockRepository) SearchProducts(ctx context.Context, query string, statuses []string, page canonical.PageRequest) (canonical.SearchPage, error) {
+	args := m.Called(ctx, query, statuses, page)
+	return args.Get(0).(canonical.SearchPage), args.Error(1)
+}
+
+func (m *MockRepository) CreateProduct(ctx context.Context, product canonical.Product) (canonical.Product, error) {
+	args := m.Called(ctx, product)
+	return args.Get(0).(canonical.Product), args.Error(1)
 }
 
-func (m *MockRepository) GetProductsByCategory(category string) ([]canonical.Product, error) {
-	args := m.Called(category)
-	return args.Get(0).([]canonical.Product), args.Error(1)
+func (m *MockRepository) UpdateProduct(ctx context.Context, id string, version int64, product canonical.Product) (canonical.Product, error) {
+	args := m.Called(ctx, id, version, product)
+	return args.Get(0).(canonical.Product), args.Error(1)
 }
 
-func (m *MockRepository) GetProductById(id string) (canonical.Product, error) {
-	args := m.Called(id)
+func (m *MockRepository) PatchProduct(ctx context.Context, id string, version int64, patch canonical.ProductPatch) (canonical.Product, error) {
+	args := m.Called(ctx, id, version, patch)
 	return args.Get(0).(canonical.Product), args.Error(1)
 }
 
-func (m *MockRepository) CreateProduct(product canonical.Product) (canonical.Product, error) {
-	args := m.Called(product)
+func (m *MockRepository) DeleteProduct(ctx context.Context, id string, version int64, deletedAt time.Time, deletedBy string) error {
+	args := m.Called(ctx, id, version, deletedAt, deletedBy)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RestoreProduct(ctx context.Context, id string, version int64) (canonical.Product, error) {
+	args := m.Called(ctx, id, version)
 	return args.Get(0).(canonical.Product), args.Error(1)
 }
 
-func (m *MockRepository) UpdateProduct(id string, product canonical.Product) (canonical.Product, error) {
-	args := m.Called(id, product)
+func (m *MockRepository) PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepository) GetDeletedProductById(ctx context.Context, id string) (canonical.Product, error) {
+	args := m.Called(ctx, id)
 	return args.Get(0).(canonical.Product), args.Error(1)
 }
 
-func (m *MockRepository) DeleteProduct(id string) error {
-	args := m.Called(id)
+func (m *MockRepository) AppendAuditEntry(ctx context.Context, entry canonical.AuditEntry) error {
+	args := m.Called(ctx, entry)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetAuditEntries(ctx context.Context, productId string, page canonical.PageRequest) (canonical.AuditPage, error) {
+	args := m.Called(ctx, productId, page)
+	return args.Get(0).(canonical.AuditPage), args.Error(1)
+}
+
+func (m *MockRepository) CreatePriceChange(ctx context.Context, change canonical.PriceChange) error {
+	args := m.Called(ctx, change)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetPriceChanges(ctx context.Context, productId string) ([]canonical.PriceChange, error) {
+	args := m.Called(ctx, productId)
+	return args.Get(0).([]canonical.PriceChange), args.Error(1)
+}
+
+func (m *MockRepository) GetDuePriceChanges(ctx context.Context, now time.Time, limit int) ([]canonical.PriceChange, error) {
+	args := m.Called(ctx, now, limit)
+	return args.Get(0).([]canonical.PriceChange), args.Error(1)
+}
+
+func (m *MockRepository) CompletePriceChange(ctx context.Context, id, status string, completedAt time.Time) error {
+	args := m.Called(ctx, id, status, completedAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SetStockLevels(ctx context.Context, id string, version int64, levels []canonical.StockLevel) (canonical.Product, error) {
+	args := m.Called(ctx, id, version, levels)
+	return args.Get(0).(canonical.Product), args.Error(1)
+}
+
+func (m *MockRepository) ReserveStock(ctx context.Context, id string, quantity int) (canonical.Product, error) {
+	args := m.Called(ctx, id, quantity)
+	return args.Get(0).(canonical.Product), args.Error(1)
+}
+
+func (m *MockRepository) ReleaseStock(ctx context.Context, id string, quantity int) error {
+	args := m.Called(ctx, id, quantity)
+	return args.Error(0)
+}
+
+func (m *MockRepository) TakeReservedStock(ctx context.Context, id string, version int64, levels []canonical.StockLevel, quantity int) (canonical.Product, error) {
+	args := m.Called(ctx, id, version, levels, quantity)
+	return args.Get(0).(canonical.Product), args.Error(1)
+}
+
+func (m *MockRepository) CreateReservation(ctx context.Context, reservation canonical.Reservation) error {
+	args := m.Called(ctx, reservation)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetReservation(ctx context.Context, id string) (canonical.Reservation, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(canonical.Reservation), args.Error(1)
+}
+
+func (m *MockRepository) GetExpiredReservations(ctx context.Context, now time.Time, limit int) ([]canonical.Reservation, error) {
+	args := m.Called(ctx, now, limit)
+	return args.Get(0).([]canonical.Reservation), args.Error(1)
+}
+
+func (m *MockRepository) CompleteReservation(ctx context.Context, id, status string, completedAt time.Time) (canonical.Reservation, error) {
+	args := m.Called(ctx, id, status, completedAt)
+	return args.Get(0).(canonical.Reservation), args.Error(1)
+}
+
+func (m *MockRepository) ReopenReservation(ctx context.Context, id string) (canonical.Reservation, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(canonical.Reservation), args.Error(1)
+}
+
+func (m *MockRepository) AppendStockMovements(ctx context.Context, movements []canonical.StockMovement) error {
+	args := m.Called(ctx, movements)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetStockMovements(ctx context.Context, productId string, page canonical.PageRequest) (canonical.StockMovementPage, error) {
+	args := m.Called(ctx, productId, page)
+	return args.Get(0).(canonical.StockMovementPage), args.Error(1)
+}
+
+func (m *MockRepository) GetStockBalances(ctx context.Context, productIds []string) ([]canonical.StockBalance, error) {
+	args := m.Called(ctx, productIds)
+	return args.Get(0).([]canonical.StockBalance), args.Error(1)
+}
+
+func (m *MockRepository) SetCategoryThreshold(ctx context.Context, category string, threshold int) error {
+	args := m.Called(ctx, category, threshold)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetCategoryThreshold(ctx context.Context, category string) (int, error) {
+	args := m.Called(ctx, category)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) OpenLowStockAlert(ctx context.Context, alert canonical.LowStockAlert) (bool, error) {
+	args := m.Called(ctx, alert)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) CloseLowStockAlert(ctx context.Context, productId string) error {
+	args := m.Called(ctx, productId)
 	return args.Error(0)
 }
+
+func (m *MockRepository) GetUnsentLowStockAlerts(ctx context.Context, limit int) ([]canonical.LowStockAlert, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]canonical.LowStockAlert), args.Error(1)
+}
+
+func (m *MockRepository) MarkLowStockAlertSent(ctx context.Context, alert canonical.LowStockAlert, sentAt time.Time) error {
+	args := m.Called(ctx, alert, sentAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetLowStockAlerts(ctx context.Context, page canonical.PageRequest) (canonical.LowStockAlertPage, error) {
+	args := m.Called(ctx, page)
+	return args.Get(0).(canonical.LowStockAlertPage), args.Error(1)
+}
+
+func (m *MockRepository) CreateCategory(ctx context.Context, category canonical.Category) (canonical.Category, error) {
+	args := m.Called(ctx, category)
+	return args.Get(0).(canonical.Category), args.Error(1)
+}
+
+func (m *MockRepository) GetCategory(ctx context.Context, slug string) (canonical.Category, error) {
+	args := m.Called(ctx, slug)
+	return args.Get(0).(canonical.Category), args.Error(1)
+}
+
+func (m *MockRepository) GetCategories(ctx context.Context) ([]canonical.Category, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]canonical.Category), args.Error(1)
+}
+
+func (m *MockRepository) GetCategorySubtree(ctx context.Context, path string) ([]canonical.Category, error) {
+	args := m.Called(ctx, path)
+	return args.Get(0).([]canonical.Category), args.Error(1)
+}
+
+func (m *MockRepository) UpdateCategory(ctx context.Context, slug string, version int64, category canonical.Category) (canonical.Category, error) {
+	args := m.Called(ctx, slug, version, category)
+	return args.Get(0).(canonical.Category), args.Error(1)
+}
+
+func (m *MockRepository) DeleteCategory(ctx context.Context, slug string, version int64) error {
+	args := m.Called(ctx, slug, version)
+	return args.Error(0)
+}
+
+// recordingNotifier keeps the alerts it is asked to send, failing for the
+// products in fail.
+type recordingNotifier struct {
+	alerts []canonical.LowStockAlert
+	fail   map[string]bool
+}
+
+func (n *recordingNotifier) NotifyLowStock(_ context.Context, alert canonical.LowStockAlert) error {
+	if n.fail[alert.ProductId] {
+		return errors.New("webhook unavailable")
+	}
+
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
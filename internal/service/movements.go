@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nelsonalves117/go-products-api/internal/actor"
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+)
+
+// reconciliationReference marks the movements written to bring the ledger in
+// line with the stored stock.
+const reconciliationReference = "reconciliation"
+
+func (service *service) GetStockMovements(ctx context.Context, id string, page canonical.PageRequest) (canonical.StockMovementPage, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_stock_movements")
+	defer cancel()
+
+	movementPage, err := service.repo.GetStockMovements(ctx, id, normalizePage(page))
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get stock movements")
+		return canonical.StockMovementPage{}, errs.Wrap(err, "error occurred while trying to get stock movements")
+	}
+
+	return movementPage, nil
+}
+
+// ReconcileStock compares the stock of every product with the sum of its
+// movements and returns the discrepancies found. With fix, adjustment and
+// reservation movements are appended to make up for them, so that the ledger
+// agrees with the stored stock.
+func (service *service) ReconcileStock(ctx context.Context, fix bool) ([]canonical.StockDiscrepancy, error) {
+	discrepancies := []canonical.StockDiscrepancy{}
+
+	page := canonical.PageRequest{Limit: maxPageLimit}
+	for {
+		productPage, found, err := service.reconcilePage(ctx, page, fix)
+		if err != nil {
+			return nil, err
+		}
+
+		discrepancies = append(discrepancies, found...)
+
+		if !productPage.HasMore {
+			return discrepancies, nil
+		}
+
+		page.Cursor = productPage.NextCursor
+	}
+}
+
+func (service *service) reconcilePage(ctx context.Context, page canonical.PageRequest, fix bool) (canonical.ProductPage, []canonical.StockDiscrepancy, error) {
+	ctx, cancel := service.withTimeout(ctx, "reconcile_stock")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	productPage, err := service.repo.GetAllProducts(ctx, canonical.ProductQuery{}, page)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get all products")
+		return canonical.ProductPage{}, nil, errs.Wrap(err, "error occurred while trying to get all products")
+	}
+
+	ids := make([]string, 0, len(productPage.Products))
+	for _, product := range productPage.Products {
+		ids = append(ids, product.Id)
+	}
+
+	balances, err := service.repo.GetStockBalances(ctx, ids)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get stock balances")
+		return canonical.ProductPage{}, nil, errs.Wrap(err, "error occurred while trying to get stock balances")
+	}
+
+	byProduct := make(map[string]canonical.StockBalance, len(balances))
+	for _, balance := range balances {
+		byProduct[balance.ProductId] = balance
+	}
+
+	var (
+		discrepancies []canonical.StockDiscrepancy
+		corrections   []canonical.StockMovement
+	)
+
+	for _, product := range productPage.Products {
+		found := service.compareWithLedger(product, byProduct[product.Id])
+		discrepancies = append(discrepancies, found...)
+		corrections = append(corrections, correctionsFor(product.Id, found)...)
+	}
+
+	if fix && len(corrections) > 0 {
+		err = service.repo.AppendStockMovements(ctx, newMovements(actor.System, corrections))
+		if err != nil {
+			service.logger.WithError(err).Error("error occurred while trying to record stock movements")
+			return canonical.ProductPage{}, nil, errs.Wrap(err, "error occurred while trying to record stock movements")
+		}
+	}
+
+	return productPage, discrepancies, nil
+}
+
+// compareWithLedger lists where the stock of product differs from balance.
+func (service *service) compareWithLedger(product canonical.Product, balance canonical.StockBalance) []canonical.StockDiscrepancy {
+	var discrepancies []canonical.StockDiscrepancy
+
+	stored := service.stockLevels(product)
+
+	for _, warehouse := range warehousesOf(stored, balance.Levels) {
+		if canonical.StockAt(stored, warehouse) != canonical.StockAt(balance.Levels, warehouse) {
+			discrepancies = append(discrepancies, canonical.StockDiscrepancy{
+				ProductId: product.Id,
+				Field:     canonical.FieldStock,
+				Warehouse: warehouse,
+				Stored:    canonical.StockAt(stored, warehouse),
+				Ledger:    canonical.StockAt(balance.Levels, warehouse),
+			})
+		}
+	}
+
+	if product.Stock != canonical.TotalStock(stored) {
+		discrepancies = append(discrepancies, canonical.StockDiscrepancy{
+			ProductId: product.Id,
+			Field:     canonical.FieldStock,
+			Stored:    product.Stock,
+			Ledger:    canonical.TotalStock(balance.Levels),
+		})
+	}
+
+	if product.Reserved != balance.Reserved {
+		discrepancies = append(discrepancies, canonical.StockDiscrepancy{
+			ProductId: product.Id,
+			Field:     "reserved",
+			Stored:    product.Reserved,
+			Ledger:    balance.Reserved,
+		})
+	}
+
+	return discrepancies
+}
+
+// correctionsFor returns the movements that make up for discrepancies. A total
+// that disagrees with the levels of its own product cannot be fixed through the
+// ledger and is left alone.
+func correctionsFor(productId string, discrepancies []canonical.StockDiscrepancy) []canonical.StockMovement {
+	var movements []canonical.StockMovement
+
+	for _, discrepancy := range discrepancies {
+		movement := canonical.StockMovement{
+			ProductId: productId,
+			Quantity:  discrepancy.Stored - discrepancy.Ledger,
+			Reference: reconciliationReference,
+		}
+
+		switch {
+		case discrepancy.Field == "reserved":
+			movement.Type = canonical.MovementReservation
+		case discrepancy.Warehouse != "":
+			movement.Type = canonical.MovementAdjustment
+			movement.Warehouse = discrepancy.Warehouse
+		default:
+			continue
+		}
+
+		movements = append(movements, movement)
+	}
+
+	return movements
+}
+
+// recordMovements appends movements to the ledger. Like audit, it runs after
+// the stock has been written and only logs failures; ReconcileStock finds the
+// movements that went missing.
+func (service *service) recordMovements(ctx context.Context, name string, movements []canonical.StockMovement) {
+	if len(movements) == 0 {
+		return
+	}
+
+	err := service.repo.AppendStockMovements(ctx, newMovements(name, movements))
+	if err != nil {
+		service.logger.WithError(err).
+			WithField("product_id", movements[0].ProductId).
+			Error("error occurred while trying to record stock movements")
+	}
+}
+
+// newMovements stamps movements with an id, the actor and the current time.
+func newMovements(name string, movements []canonical.StockMovement) []canonical.StockMovement {
+	now := time.Now()
+
+	for i := range movements {
+		movements[i].Id = uuid.Must(uuid.NewV7()).String()
+		movements[i].Actor = name
+		movements[i].Timestamp = now
+	}
+
+	return movements
+}
+
+// levelMovements returns the movements of movementType that take a product
+// from the before to the after stock levels, one per warehouse that changed.
+func levelMovements(productId string, before, after []canonical.StockLevel, movementType, reference string) []canonical.StockMovement {
+	var movements []canonical.StockMovement
+
+	for _, warehouse := range warehousesOf(before, after) {
+		quantity := canonical.StockAt(after, warehouse) - canonical.StockAt(before, warehouse)
+		if quantity == 0 {
+			continue
+		}
+
+		movements = append(movements, canonical.StockMovement{
+			ProductId: productId,
+			Warehouse: warehouse,
+			Type:      movementType,
+			Quantity:  quantity,
+			Reference: reference,
+		})
+	}
+
+	return movements
+}
+
+// warehousesOf lists the warehouses that appear in any of levels, in the order
+// they are first seen.
+func warehousesOf(levels ...[]canonical.StockLevel) []string {
+	warehouses := []string{}
+
+	for _, levels := range levels {
+		for _, level := range levels {
+			if !slices.Contains(warehouses, level.Warehouse) {
+				warehouses = append(warehouses, level.Warehouse)
+			}
+		}
+	}
+
+	return warehouses
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nelsonalves117/go-products-api/internal/actor"
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+	"github.com/nelsonalves117/go-products-api/internal/validation"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+)
+
+// priceBatchSize bounds how many due price changes a single scheduler run
+// applies; the rest are picked up by the next run.
+const priceBatchSize = 100
+
+// SchedulePriceChange adds a change to the price timeline of a product that
+// takes effect at effectiveAt, which must lie in the future. The price must be
+// in the currency of the product.
+func (service *service) SchedulePriceChange(ctx context.Context, productId string, price money.Money, effectiveAt time.Time) (canonical.PriceChange, error) {
+	ctx, cancel := service.withTimeout(ctx, "schedule_price_change")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	now := time.Now()
+	change := canonical.PriceChange{
+		Id:          uuid.Must(uuid.NewV7()).String(),
+		ProductId:   productId,
+		Price:       price,
+		EffectiveAt: effectiveAt,
+		Status:      canonical.PriceScheduled,
+		CreatedAt:   now,
+		CreatedBy:   actor.FromContext(ctx),
+	}
+
+	err := validation.Struct(change)
+	if err != nil {
+		return canonical.PriceChange{}, errs.Wrap(err, "invalid price change")
+	}
+
+	if !effectiveAt.After(now) {
+		return canonical.PriceChange{}, errs.InvalidFields(errs.FieldError{Field: "effective_at", Message: "must be in the future"})
+	}
+
+	product, err := service.repo.GetProductById(ctx, productId)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return canonical.PriceChange{}, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	if price.Currency != product.Price.Currency {
+		return canonical.PriceChange{}, errs.InvalidFields(errs.FieldError{Field: "price", Message: "must be in " + product.Price.Currency.String() + ", the currency of the product"})
+	}
+
+	err = service.repo.CreatePriceChange(ctx, change)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to schedule a price change")
+		return canonical.PriceChange{}, errs.Wrap(err, "error occurred while trying to schedule a price change")
+	}
+
+	return change, nil
+}
+
+func (service *service) GetPriceHistory(ctx context.Context, productId string) ([]canonical.PriceChange, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_price_history")
+	defer cancel()
+
+	_, err := service.repo.GetProductById(ctx, productId)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return nil, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	changes, err := service.repo.GetPriceChanges(ctx, productId)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get price history")
+		return nil, errs.Wrap(err, "error occurred while trying to get price history")
+	}
+
+	return changes, nil
+}
+
+// GetPriceAt returns the price of a product at a point in time. Up to now only
+// applied changes count, so a change the scheduler has yet to apply is not
+// taken for a price the product had. Times to come are projected from the
+// changes scheduled so far.
+func (service *service) GetPriceAt(ctx context.Context, productId string, at time.Time) (canonical.PriceAt, error) {
+	changes, err := service.GetPriceHistory(ctx, productId)
+	if err != nil {
+		return canonical.PriceAt{}, err
+	}
+
+	if len(changes) == 0 {
+		return service.currentPriceAt(ctx, productId, at)
+	}
+
+	projecting := at.After(time.Now())
+
+	var current *canonical.PriceChange
+	for i, change := range changes {
+		if change.EffectiveAt.After(at) {
+			break
+		}
+
+		if change.Status == canonical.PriceApplied || change.Status == canonical.PriceScheduled && projecting {
+			current = &changes[i]
+		}
+	}
+
+	if current == nil {
+		return canonical.PriceAt{}, errs.NotFound("no price recorded for product " + productId + " at " + at.Format(time.RFC3339))
+	}
+
+	return canonical.PriceAt{
+		ProductId: productId,
+		At:        at,
+		Change:    *current,
+		Projected: current.Status == canonical.PriceScheduled,
+	}, nil
+}
+
+// currentPriceAt answers GetPriceAt for a product created before prices were
+// recorded, which has no timeline: the price it has now is the only one known,
+// from its creation on.
+func (service *service) currentPriceAt(ctx context.Context, productId string, at time.Time) (canonical.PriceAt, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_price_at")
+	defer cancel()
+
+	product, err := service.repo.GetProductById(ctx, productId)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return canonical.PriceAt{}, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	if at.Before(product.CreatedAt) {
+		return canonical.PriceAt{}, errs.NotFound("no price recorded for product " + productId + " at " + at.Format(time.RFC3339))
+	}
+
+	product, err = service.inheritPrice(ctx, product)
+	if err != nil {
+		return canonical.PriceAt{}, err
+	}
+
+	return canonical.PriceAt{
+		ProductId: productId,
+		At:        at,
+		Change: canonical.PriceChange{
+			ProductId:   productId,
+			Price:       product.Price,
+			EffectiveAt: product.CreatedAt,
+			Status:      canonical.PriceApplied,
+		},
+	}, nil
+}
+
+// ApplyDuePriceChanges sets the price of every product with a scheduled change
+// that is due and returns how many changes it applied. Changes for products
+// that no longer exist are skipped; changes that lose a race with another
+// write are retried on the next run.
+func (service *service) ApplyDuePriceChanges(ctx context.Context) (int, error) {
+	ctx, cancel := service.withTimeout(ctx, "apply_due_price_changes")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	changes, err := service.repo.GetDuePriceChanges(ctx, time.Now(), priceBatchSize)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get due price changes")
+		return 0, errs.Wrap(err, "error occurred while trying to get due price changes")
+	}
+
+	applied := 0
+	for _, change := range changes {
+		logger := service.logger.WithField("price_change_id", change.Id).WithField("product_id", change.ProductId)
+
+		status, err := service.applyPriceChange(ctx, change)
+		if err != nil {
+			logger.WithError(err).Error("error occurred while trying to apply a price change")
+			continue
+		}
+
+		err = service.repo.CompletePriceChange(ctx, change.Id, status, time.Now())
+		if err != nil {
+			logger.WithError(err).Error("error occurred while trying to complete a price change")
+			continue
+		}
+
+		if status == canonical.PriceApplied {
+			applied++
+		}
+	}
+
+	if applied > 0 {
+		service.logger.WithField("applied", applied).Info("applied scheduled price changes")
+	}
+
+	return applied, nil
+}
+
+// applyPriceChange writes the price of change to its product and returns the
+// status the change ends up in.
+func (service *service) applyPriceChange(ctx context.Context, change canonical.PriceChange) (string, error) {
+	product, err := service.repo.GetProductById(ctx, change.ProductId)
+	if errs.KindOf(err) == errs.KindNotFound {
+		return canonical.PriceSkipped, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	// The product may have been repriced in another currency since.
+	if change.Price.Currency != product.Price.Currency {
+		return canonical.PriceSkipped, nil
+	}
+
+	patched, err := service.repo.PatchProduct(ctx, product.Id, product.Version, canonical.ProductPatch{Price: &change.Price})
+	if err != nil {
+		return "", err
+	}
+
+	service.audit(ctx, canonical.OperationPatch, actor.System, product, patched)
+
+	return canonical.PriceApplied, nil
+}
+
+// recordPrice appends the current price of product to its timeline. Like
+// audit, it runs after the write and only logs failures.
+func (service *service) recordPrice(ctx context.Context, product canonical.Product) {
+	now := time.Now()
+	change := canonical.PriceChange{
+		Id:          uuid.Must(uuid.NewV7()).String(),
+		ProductId:   product.Id,
+		Price:       product.Price,
+		EffectiveAt: now,
+		Status:      canonical.PriceApplied,
+		CreatedAt:   now,
+		CreatedBy:   actor.FromContext(ctx),
+		AppliedAt:   &now,
+	}
+
+	err := service.repo.CreatePriceChange(ctx, change)
+	if err != nil {
+		service.logger.WithError(err).
+			WithField("product_id", product.Id).
+			Error("error occurred while trying to record a price change")
+	}
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nelsonalves117/go-products-api/internal/actor"
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/validation"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+)
+
+// reservationBatchSize bounds how many expired reservations a single reaper
+// run releases; the rest are picked up by the next run.
+const reservationBatchSize = 100
+
+// ReserveStock holds quantity of the stock of a product for ttl, or for the
+// configured default if ttl is zero. The stock is held in a single
+// conditional write, so two checkouts cannot both get the last unit.
+func (service *service) ReserveStock(ctx context.Context, productId string, quantity int, ttl time.Duration) (canonical.Reservation, error) {
+	ctx, cancel := service.withTimeout(ctx, "reserve_stock")
+	defer cancel()
+
+	if ttl == 0 {
+		ttl = service.reservations.DefaultTTL
+	}
+
+	switch {
+	case ttl <= 0:
+		return canonical.Reservation{}, errs.InvalidFields(errs.FieldError{Field: "ttl_seconds", Message: "must be positive"})
+	case service.reservations.MaxTTL > 0 && ttl > service.reservations.MaxTTL:
+		return canonical.Reservation{}, errs.InvalidFields(errs.FieldError{Field: "ttl_seconds", Message: "must not exceed " + service.reservations.MaxTTL.String()})
+	}
+
+	now := time.Now()
+	reservation := canonical.Reservation{
+		Id:        uuid.Must(uuid.NewV7()).String(),
+		ProductId: productId,
+		Quantity:  quantity,
+		Status:    canonical.ReservationHeld,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		CreatedBy: actor.FromContext(ctx),
+	}
+
+	err := validation.Struct(reservation)
+	if err != nil {
+		return canonical.Reservation{}, errs.Wrap(err, "invalid reservation")
+	}
+
+	_, err = service.repo.ReserveStock(ctx, productId, quantity)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to reserve stock")
+		return canonical.Reservation{}, errs.Wrap(err, "error occurred while trying to reserve stock")
+	}
+
+	err = service.repo.CreateReservation(ctx, reservation)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to create a reservation")
+		err = errs.Wrap(err, "error occurred while trying to create a reservation")
+
+		undoErr := service.repo.ReleaseStock(ctx, productId, quantity)
+		if undoErr != nil {
+			service.logger.WithError(undoErr).
+				WithField("product_id", productId).
+				Error("error occurred while trying to release reserved stock")
+		}
+
+		return canonical.Reservation{}, err
+	}
+
+	service.recordMovements(ctx, reservation.CreatedBy, []canonical.StockMovement{{
+		ProductId: productId,
+		Type:      canonical.MovementReservation,
+		Quantity:  quantity,
+		Reference: reservation.Id,
+	}})
+
+	return reservation, nil
+}
+
+// ConfirmReservation turns a held reservation into a sale: the stock it held
+// leaves the warehouses, the default one first. The reservation is confirmed
+// first so that it cannot be released or expire meanwhile; if the stock cannot
+// be taken after all, it is held again and the confirmation can be retried.
+func (service *service) ConfirmReservation(ctx context.Context, productId, id string) (canonical.Reservation, error) {
+	ctx, cancel := service.withTimeout(ctx, "confirm_reservation")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	reservation, err := service.getReservation(ctx, productId, id)
+	if err != nil {
+		return canonical.Reservation{}, err
+	}
+
+	now := time.Now()
+	if reservation.Status == canonical.ReservationHeld && !reservation.ExpiresAt.After(now) {
+		return canonical.Reservation{}, errs.Conflict("reservation " + id + " has expired")
+	}
+
+	reservation, err = service.repo.CompleteReservation(ctx, id, canonical.ReservationConfirmed, now)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to confirm a reservation")
+		return canonical.Reservation{}, errs.Wrap(err, "error occurred while trying to confirm a reservation")
+	}
+
+	err = service.takeReservedStock(ctx, reservation)
+	if err != nil {
+		// The stock is still held, so the reservation must be too, or nothing
+		// would ever give it back.
+		_, undoErr := service.repo.ReopenReservation(context.WithoutCancel(ctx), id)
+		if undoErr != nil {
+			service.logger.WithError(undoErr).
+				WithField("reservation_id", id).
+				Error("error occurred while trying to reopen a reservation")
+		}
+
+		return canonical.Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// takeReservedStock takes the stock held by a confirmed reservation out of the
+// warehouses, retrying when the product changes underneath.
+func (service *service) takeReservedStock(ctx context.Context, reservation canonical.Reservation) error {
+	productId := reservation.ProductId
+
+	for attempt := 1; ; attempt++ {
+		before, err := service.repo.GetProductById(ctx, productId)
+		if err != nil {
+			service.logger.WithError(err).Error("error occurred while trying to get a product")
+			return errs.Wrap(err, "error occurred while trying to get a product")
+		}
+
+		levels := service.takeStock(service.stockLevels(before), reservation.Quantity)
+
+		product, err := service.repo.TakeReservedStock(ctx, productId, before.Version, levels, reservation.Quantity)
+		if errs.KindOf(err) == errs.KindVersionConflict && attempt < stockWriteAttempts {
+			continue
+		}
+
+		if err != nil {
+			service.logger.WithError(err).Error("error occurred while trying to take reserved stock")
+			return errs.Wrap(err, "error occurred while trying to take reserved stock")
+		}
+
+		service.audit(ctx, canonical.OperationConfirmReservation, actor.FromContext(ctx), before, product)
+
+		movements := levelMovements(productId, service.stockLevels(before), service.stockLevels(product), canonical.MovementSale, reservation.Id)
+		movements = append(movements, canonical.StockMovement{
+			ProductId: productId,
+			Type:      canonical.MovementReservation,
+			Quantity:  -reservation.Quantity,
+			Reference: reservation.Id,
+		})
+		service.recordMovements(ctx, actor.FromContext(ctx), movements)
+		service.checkLowStock(ctx, before, product)
+
+		return nil
+	}
+}
+
+// ReleaseReservation gives the stock held by a reservation back.
+func (service *service) ReleaseReservation(ctx context.Context, productId, id string) (canonical.Reservation, error) {
+	ctx, cancel := service.withTimeout(ctx, "release_reservation")
+	defer cancel()
+
+	_, err := service.getReservation(ctx, productId, id)
+	if err != nil {
+		return canonical.Reservation{}, err
+	}
+
+	reservation, err := service.repo.CompleteReservation(ctx, id, canonical.ReservationReleased, time.Now())
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to release a reservation")
+		return canonical.Reservation{}, errs.Wrap(err, "error occurred while trying to release a reservation")
+	}
+
+	service.releaseStock(ctx, actor.FromContext(ctx), reservation)
+
+	return reservation, nil
+}
+
+// ReleaseExpiredReservations gives back the stock of reservations that were
+// neither confirmed nor released in time and returns how many it released.
+func (service *service) ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	ctx, cancel := service.withTimeout(ctx, "release_expired_reservations")
+	defer cancel()
+
+	reservations, err := service.repo.GetExpiredReservations(ctx, time.Now(), reservationBatchSize)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get expired reservations")
+		return 0, errs.Wrap(err, "error occurred while trying to get expired reservations")
+	}
+
+	released := 0
+	for _, reservation := range reservations {
+		expired, err := service.repo.CompleteReservation(ctx, reservation.Id, canonical.ReservationExpired, time.Now())
+		if err != nil {
+			service.logger.WithError(err).
+				WithField("reservation_id", reservation.Id).
+				Error("error occurred while trying to expire a reservation")
+			continue
+		}
+
+		service.releaseStock(ctx, actor.System, expired)
+		released++
+	}
+
+	if released > 0 {
+		service.logger.WithField("released", released).Info("released expired reservations")
+	}
+
+	return released, nil
+}
+
+// getReservation returns the reservation with id if it belongs to productId.
+func (service *service) getReservation(ctx context.Context, productId, id string) (canonical.Reservation, error) {
+	reservation, err := service.repo.GetReservation(ctx, id)
+	if err == nil && reservation.ProductId != productId {
+		err = errs.NotFound("reservation " + id + " not found")
+	}
+
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a reservation")
+		return canonical.Reservation{}, errs.Wrap(err, "error occurred while trying to get a reservation")
+	}
+
+	return reservation, nil
+}
+
+// releaseStock gives back the stock held by reservation once it has been
+// completed. The reservation can no longer be retried by then, so a failure is
+// logged rather than returned.
+func (service *service) releaseStock(ctx context.Context, name string, reservation canonical.Reservation) {
+	err := service.repo.ReleaseStock(ctx, reservation.ProductId, reservation.Quantity)
+	if err != nil {
+		service.logger.WithError(err).
+			WithField("reservation_id", reservation.Id).
+			WithField("product_id", reservation.ProductId).
+			Error("error occurred while trying to release reserved stock")
+
+		return
+	}
+
+	service.recordMovements(ctx, name, []canonical.StockMovement{{
+		ProductId: reservation.ProductId,
+		Type:      canonical.MovementReservation,
+		Quantity:  -reservation.Quantity,
+		Reference: reservation.Id,
+	}})
+}
+
+// takeStock takes quantity out of levels, emptying the configured warehouses
+// in order. What they cannot cover, which only happens with backorders, is
+// taken from the default warehouse.
+func (service *service) takeStock(levels []canonical.StockLevel, quantity int) []canonical.StockLevel {
+	remaining := quantity
+
+	for _, warehouse := range service.warehouses {
+		take := min(canonical.StockAt(levels, warehouse), remaining)
+		if take <= 0 {
+			continue
+		}
+
+		levels = adjustLevel(levels, warehouse, -take)
+		remaining -= take
+	}
+
+	for i := range levels {
+		if slices.Contains(service.warehouses, levels[i].Warehouse) || levels[i].Quantity <= 0 {
+			continue
+		}
+
+		take := min(levels[i].Quantity, remaining)
+		levels[i].Quantity -= take
+		remaining -= take
+	}
+
+	if remaining > 0 {
+		levels = adjustLevel(levels, service.warehouses[0], -remaining)
+	}
+
+	return levels
+}
@@ -1,103 +1,646 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/nelsonalves117/go-products-api/internal/actor"
 	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/config"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/fx"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+	"github.com/nelsonalves117/go-products-api/internal/notify"
 	"github.com/nelsonalves117/go-products-api/internal/repositories"
+	"github.com/nelsonalves117/go-products-api/internal/validation"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
 	"github.com/sirupsen/logrus"
 )
 
 type Service interface {
-	GetAllProducts() ([]canonical.Product, error)
-	GetProductsByCategory(category string) ([]canonical.Product, error)
-	GetProductById(id string) (canonical.Product, error)
-	CreateProduct(product canonical.Product) (canonical.Product, error)
-	UpdateProduct(id string, product canonical.Product) (canonical.Product, error)
-	DeleteProduct(id string) error
+	GetAllProducts(ctx context.Context, query canonical.ProductQuery, page canonical.PageRequest) (canonical.ProductPage, error)
+	GetProductsByCategory(ctx context.Context, category string, includeDescendants bool, page canonical.PageRequest) (canonical.ProductPage, error)
+	GetProductById(ctx context.Context, id string) (canonical.Product, error)
+	GetLocalizedProduct(ctx context.Context, id string, currency money.Currency) (canonical.LocalizedProduct, error)
+	SearchProducts(ctx context.Context, query string, page canonical.PageRequest) (canonical.SearchPage, error)
+	CreateProduct(ctx context.Context, product canonical.Product) (canonical.Product, error)
+	UpdateProduct(ctx context.Context, id string, version int64, product canonical.Product) (canonical.Product, error)
+	PatchProduct(ctx context.Context, id string, version int64, patch canonical.ProductPatch) (canonical.Product, error)
+	DeleteProduct(ctx context.Context, id string, version int64, force bool) error
+	TransitionProduct(ctx context.Context, id string, version int64, status string) (canonical.Product, error)
+	GetDeletedProducts(ctx context.Context, page canonical.PageRequest) (canonical.ProductPage, error)
+	RestoreProduct(ctx context.Context, id string, version int64) (canonical.Product, error)
+	PurgeDeletedProducts(ctx context.Context) (int64, error)
+	GetProductHistory(ctx context.Context, id string, page canonical.PageRequest) (canonical.AuditPage, error)
+	SchedulePriceChange(ctx context.Context, productId string, price money.Money, effectiveAt time.Time) (canonical.PriceChange, error)
+	GetPriceHistory(ctx context.Context, productId string) ([]canonical.PriceChange, error)
+	GetPriceAt(ctx context.Context, productId string, at time.Time) (canonical.PriceAt, error)
+	ApplyDuePriceChanges(ctx context.Context) (int, error)
+	GetStock(ctx context.Context, id string) (canonical.Stock, error)
+	AdjustStock(ctx context.Context, id string, movement canonical.StockMovement) (canonical.Stock, error)
+	GetStockMovements(ctx context.Context, id string, page canonical.PageRequest) (canonical.StockMovementPage, error)
+	ReconcileStock(ctx context.Context, fix bool) ([]canonical.StockDiscrepancy, error)
+	ReserveStock(ctx context.Context, productId string, quantity int, ttl time.Duration) (canonical.Reservation, error)
+	ConfirmReservation(ctx context.Context, productId, id string) (canonical.Reservation, error)
+	ReleaseReservation(ctx context.Context, productId, id string) (canonical.Reservation, error)
+	ReleaseExpiredReservations(ctx context.Context) (int, error)
+	GetLowStockAlerts(ctx context.Context, page canonical.PageRequest) (canonical.LowStockAlertPage, error)
+	SetCategoryThreshold(ctx context.Context, category string, threshold int) (canonical.CategoryThreshold, error)
+	SendLowStockAlerts(ctx context.Context) (int, error)
+	GetVariants(ctx context.Context, parentId string) ([]canonical.Product, error)
+	CreateVariant(ctx context.Context, parentId string, variant canonical.Product) (canonical.Product, error)
+	GetCategories(ctx context.Context) ([]canonical.Category, error)
+	GetCategory(ctx context.Context, slug string) (canonical.Category, error)
+	CreateCategory(ctx context.Context, category canonical.Category) (canonical.Category, error)
+	UpdateCategory(ctx context.Context, slug string, version int64, category canonical.Category) (canonical.Category, error)
+	DeleteCategory(ctx context.Context, slug string, version int64) error
 }
 
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// storefrontStatuses are the statuses of the products listed, searched and
+// browsed by category unless a listing asks for others.
+var storefrontStatuses = []string{canonical.StatusActive}
+
 type service struct {
-	repo repositories.Repository
+	repo           repositories.Repository
+	timeouts       config.Timeouts
+	trashRetention time.Duration
+	rates          fx.RateProvider
+	warehouses     []string
+	reservations   config.Reservations
+	notifier       notify.Notifier
+	logger         logrus.FieldLogger
 }
 
-func New() Service {
+// New returns the Service. A nil notifier leaves low-stock alerts unsent; they
+// are still listed.
+func New(cfg config.Config, repo repositories.Repository, rates fx.RateProvider, notifier notify.Notifier, logger logrus.FieldLogger) Service {
+	warehouses := cfg.Stock.Warehouses
+	if len(warehouses) == 0 {
+		warehouses = []string{"main"}
+	}
+
 	return &service{
-		repo: repositories.New(),
+		repo:           repo,
+		rates:          rates,
+		timeouts:       cfg.Timeouts,
+		trashRetention: cfg.Trash.Retention,
+		warehouses:     warehouses,
+		reservations:   cfg.Reservations,
+		notifier:       notifier,
+		logger:         logger,
 	}
 }
 
-func (service *service) GetAllProducts() ([]canonical.Product, error) {
-	product, err := service.repo.GetAllProducts()
+func (service *service) GetAllProducts(ctx context.Context, query canonical.ProductQuery, page canonical.PageRequest) (canonical.ProductPage, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_all_products")
+	defer cancel()
+
+	err := checkQuery(query)
 	if err != nil {
-		logrus.WithError(err).Error("error occurred while trying to get all products")
-		return []canonical.Product{}, err
+		return canonical.ProductPage{}, err
 	}
 
-	return product, nil
+	if query.Category != "" {
+		query.Category = canonical.Slugify(query.Category)
+	}
+
+	// Listings are for the storefront unless asked otherwise. Variants are
+	// listed through their parent.
+	if query.Statuses == nil {
+		query.Statuses = storefrontStatuses
+	}
+
+	query.ParentsOnly = true
+
+	productPage, err := service.repo.GetAllProducts(ctx, query, normalizePage(page))
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get all products")
+		return canonical.ProductPage{}, errs.Wrap(err, "error occurred while trying to get all products")
+	}
+
+	err = service.summarizeVariants(ctx, productPage.Products)
+	if err != nil {
+		return canonical.ProductPage{}, err
+	}
+
+	return productPage, nil
 }
 
-func (service *service) GetProductsByCategory(category string) ([]canonical.Product, error) {
-	product, err := service.repo.GetProductsByCategory(category)
+// GetProductsByCategory lists the products in category and, with
+// includeDescendants, in any category of its subtree.
+func (service *service) GetProductsByCategory(ctx context.Context, category string, includeDescendants bool, page canonical.PageRequest) (canonical.ProductPage, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_products_by_category")
+	defer cancel()
+
+	stored, err := service.getCategory(ctx, category)
 	if err != nil {
-		logrus.WithError(err).Error("error occurred while trying to get a product")
-		return []canonical.Product{}, err
+		return canonical.ProductPage{}, err
 	}
 
-	return product, nil
+	categories := []canonical.Category{stored}
+	if includeDescendants {
+		categories, err = service.repo.GetCategorySubtree(ctx, stored.Path)
+		if err != nil {
+			service.logger.WithError(err).Error("error occurred while trying to get subcategories")
+			return canonical.ProductPage{}, errs.Wrap(err, "error occurred while trying to get subcategories")
+		}
+	}
+
+	slugs := make([]string, 0, len(categories))
+	for _, category := range categories {
+		slugs = append(slugs, category.Slug)
+	}
+
+	productPage, err := service.repo.GetProductsByCategory(ctx, slugs, storefrontStatuses, normalizePage(page))
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return canonical.ProductPage{}, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	err = service.summarizeVariants(ctx, productPage.Products)
+	if err != nil {
+		return canonical.ProductPage{}, err
+	}
+
+	return productPage, nil
+}
+
+func (service *service) GetProductById(ctx context.Context, id string) (canonical.Product, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_product_by_id")
+	defer cancel()
+
+	product, err := service.repo.GetProductById(ctx, id)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	return service.withVariants(ctx, product)
+}
+
+func (service *service) SearchProducts(ctx context.Context, query string, page canonical.PageRequest) (canonical.SearchPage, error) {
+	ctx, cancel := service.withTimeout(ctx, "search_products")
+	defer cancel()
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return canonical.SearchPage{}, errs.InvalidFields(errs.FieldError{Field: "q", Message: "is required"})
+	}
+
+	searchPage, err := service.repo.SearchProducts(ctx, query, storefrontStatuses, normalizePage(page))
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to search products")
+		return canonical.SearchPage{}, errs.Wrap(err, "error occurred while trying to search products")
+	}
+
+	products := make([]canonical.Product, 0, len(searchPage.Results))
+	for _, result := range searchPage.Results {
+		products = append(products, result.Product)
+	}
+
+	err = service.summarizeVariants(ctx, products)
+	if err != nil {
+		return canonical.SearchPage{}, err
+	}
+
+	for i := range searchPage.Results {
+		searchPage.Results[i].Product = products[i]
+	}
+
+	return searchPage, nil
 }
 
-func (service *service) GetProductById(id string) (canonical.Product, error) {
-	product, err := service.repo.GetProductById(id)
+func (service *service) CreateProduct(ctx context.Context, product canonical.Product) (canonical.Product, error) {
+	ctx, cancel := service.withTimeout(ctx, "create_product")
+	defer cancel()
+
+	err := validateProduct(product)
 	if err != nil {
-		logrus.WithError(err).Error("error occurred while trying to get a product")
 		return canonical.Product{}, err
 	}
 
-	return product, nil
+	if product.Status != "" && product.Status != canonical.StatusDraft && product.Status != canonical.StatusActive {
+		return canonical.Product{}, errs.InvalidFields(errs.FieldError{Field: "status", Message: "must be draft or active"})
+	}
+
+	category, err := service.categoryOf(ctx, product.Category)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	// A null attribute is the same as a missing one.
+	product.Attributes = canonical.MergeAttributes(nil, product.Attributes)
+
+	err = checkAttributes(category, product.Attributes)
+	if err != nil {
+		return canonical.Product{}, errs.Wrap(err, "invalid product")
+	}
+
+	product.Category = category.Slug
+
+	return service.createProduct(ctx, product)
 }
 
-func (service *service) CreateProduct(product canonical.Product) (canonical.Product, error) {
+// createProduct stores a new product that has been validated. Products are
+// active unless they are created as drafts.
+func (service *service) createProduct(ctx context.Context, product canonical.Product) (canonical.Product, error) {
+	if product.Status == "" {
+		product.Status = canonical.StatusActive
+	}
+
 	product.Id = uuid.NewString()
 	product.CreatedAt = time.Now()
+	product.Version = 1
+	product.StockLevels = service.levelsWithStock(canonical.Product{}, product.Stock)
 
-	product, err := service.repo.CreateProduct(product)
+	product, err := service.repo.CreateProduct(ctx, product)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to create a product")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to create a product")
+	}
+
+	service.audit(ctx, canonical.OperationCreate, actor.FromContext(ctx), canonical.Product{}, product)
+	service.recordPrice(ctx, product)
+	service.recordMovements(ctx, actor.FromContext(ctx), levelMovements(product.Id, nil, service.stockLevels(product), canonical.MovementReceipt, ""))
+
+	return product, nil
+}
+
+func (service *service) UpdateProduct(ctx context.Context, id string, version int64, product canonical.Product) (canonical.Product, error) {
+	ctx, cancel := service.withTimeout(ctx, "update_product")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	err := validateProduct(product)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	category, err := service.categoryOf(ctx, product.Category)
 	if err != nil {
-		logrus.WithError(err).Error("error occurred while trying to create a product")
 		return canonical.Product{}, err
 	}
 
+	// A null attribute is the same as a missing one.
+	product.Attributes = canonical.MergeAttributes(nil, product.Attributes)
+
+	err = checkAttributes(category, product.Attributes)
+	if err != nil {
+		return canonical.Product{}, errs.Wrap(err, "invalid product")
+	}
+
+	product.Category = category.Slug
+
+	before, err := service.repo.GetProductById(ctx, id)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	// The status only changes through TransitionProduct, which checks that
+	// the product can go there; a PUT may repeat it but not change it.
+	if product.Status != "" && product.Status != before.Status {
+		return canonical.Product{}, errs.InvalidFields(errs.FieldError{Field: "status", Message: "must be changed through POST /products/" + id + "/transitions"})
+	}
+
+	// A variant sold at the price of its parent keeps it unless it is given
+	// another one.
+	before, err = service.inheritPrice(ctx, before)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	product.InheritsPrice = before.InheritsPrice && product.Price == before.Price
+
+	err = service.checkCategoryMove(ctx, before, product.Category)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	// Reservations move the version, so the write below fails if any was
+	// made since before was read.
+	product.StockLevels = service.levelsWithStock(before, product.Stock)
+	product.Reserved = before.Reserved
+
+	err = checkStock(product)
+	if err != nil {
+		return canonical.Product{}, errs.Wrap(err, "invalid product")
+	}
+
+	product, err = service.repo.UpdateProduct(ctx, id, version, product)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to update a product")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to update a product")
+	}
+
+	service.audit(ctx, canonical.OperationUpdate, actor.FromContext(ctx), before, product)
+	if product.Price != before.Price {
+		service.recordPrice(ctx, product)
+	}
+	service.recordMovements(ctx, actor.FromContext(ctx), levelMovements(id, service.stockLevels(before), service.stockLevels(product), canonical.MovementAdjustment, ""))
+	service.checkLowStock(ctx, before, product)
+
 	return product, nil
 }
 
-func (service *service) UpdateProduct(id string, product canonical.Product) (canonical.Product, error) {
-	product, err := service.repo.UpdateProduct(id, product)
+// PatchProduct updates only the fields set in patch and returns the stored
+// product. The patch is validated against the product it will be applied to.
+func (service *service) PatchProduct(ctx context.Context, id string, version int64, patch canonical.ProductPatch) (canonical.Product, error) {
+	ctx, cancel := service.withTimeout(ctx, "patch_product")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	before, err := service.repo.GetProductById(ctx, id)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	before, err = service.inheritPrice(ctx, before)
 	if err != nil {
-		logrus.WithError(err).Error("error occurred while trying to update a product")
 		return canonical.Product{}, err
 	}
 
+	if patch.Stock != nil {
+		levels := service.levelsWithStock(before, *patch.Stock)
+		patch.StockLevels = &levels
+	}
+
+	if patch.Attributes != nil {
+		err = checkAttributeNames(*patch.Attributes)
+		if err != nil {
+			return canonical.Product{}, errs.Wrap(err, "invalid product")
+		}
+	}
+
+	patched := patch.Apply(before)
+
+	err = validateProduct(patched)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	// A product moved to another category must fit its schema too.
+	if patch.Category != nil || patch.Attributes != nil {
+		category, err := service.categoryOf(ctx, patched.Category)
+		if err != nil {
+			return canonical.Product{}, err
+		}
+
+		err = checkAttributes(category, patched.Attributes)
+		if err != nil {
+			return canonical.Product{}, errs.Wrap(err, "invalid product")
+		}
+
+		if patch.Category != nil {
+			err = service.checkCategoryMove(ctx, before, category.Slug)
+			if err != nil {
+				return canonical.Product{}, err
+			}
+
+			patch.Category = &category.Slug
+		}
+	}
+
+	product, err := service.repo.PatchProduct(ctx, id, version, patch)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to patch a product")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to patch a product")
+	}
+
+	if product.InheritsPrice {
+		product.Price = before.Price
+	}
+
+	service.audit(ctx, canonical.OperationPatch, actor.FromContext(ctx), before, product)
+	if product.Price != before.Price {
+		service.recordPrice(ctx, product)
+	}
+	service.recordMovements(ctx, actor.FromContext(ctx), levelMovements(id, service.stockLevels(before), service.stockLevels(product), canonical.MovementAdjustment, ""))
+	service.checkLowStock(ctx, before, product)
+
+	return product, nil
+}
+
+// DeleteProduct moves a product to the trash. Active products are still on
+// sale and are only deleted with force.
+func (service *service) DeleteProduct(ctx context.Context, id string, version int64, force bool) error {
+	ctx, cancel := service.withTimeout(ctx, "delete_product")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	product, err := service.repo.GetProductById(ctx, id)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	if product.Status == canonical.StatusActive && !force {
+		return errs.Conflict("product " + id + " is active; discontinue it first or delete it with force")
+	}
+
+	// Variants are sold through their parent and cannot outlive it.
+	if product.ParentId == "" {
+		hasVariants, err := service.hasVariants(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if hasVariants {
+			return errs.Conflict("product " + id + " has variants; delete them first")
+		}
+	}
+
+	deletedAt, deletedBy := time.Now(), actor.FromContext(ctx)
+
+	err = service.repo.DeleteProduct(ctx, id, version, deletedAt, deletedBy)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to delete a product")
+		return errs.Wrap(err, "error occurred while trying to delete a product")
+	}
+
+	deleted := product
+	deleted.DeletedAt = &deletedAt
+	deleted.DeletedBy = deletedBy
+	service.audit(ctx, canonical.OperationDelete, deletedBy, product, deleted)
+	service.closeLowStockAlert(ctx, id)
+
+	return nil
+}
+
+func (service *service) GetDeletedProducts(ctx context.Context, page canonical.PageRequest) (canonical.ProductPage, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_deleted_products")
+	defer cancel()
+
+	productPage, err := service.repo.GetAllProducts(ctx, canonical.ProductQuery{Deleted: true}, normalizePage(page))
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get deleted products")
+		return canonical.ProductPage{}, errs.Wrap(err, "error occurred while trying to get deleted products")
+	}
+
+	return productPage, nil
+}
+
+func (service *service) RestoreProduct(ctx context.Context, id string, version int64) (canonical.Product, error) {
+	ctx, cancel := service.withTimeout(ctx, "restore_product")
+	defer cancel()
+
+	before, err := service.repo.GetDeletedProductById(ctx, id)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a deleted product")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to get a deleted product")
+	}
+
+	if before.ParentId != "" {
+		_, err = service.repo.GetProductById(visibility.Admin(ctx), before.ParentId)
+		if errs.KindOf(err) == errs.KindNotFound {
+			return canonical.Product{}, errs.Conflict("the parent of product " + id + " is deleted; restore it first")
+		}
+
+		if err != nil {
+			service.logger.WithError(err).Error("error occurred while trying to get a product")
+			return canonical.Product{}, errs.Wrap(err, "error occurred while trying to get a product")
+		}
+	}
+
+	product, err := service.repo.RestoreProduct(ctx, id, version)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to restore a product")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to restore a product")
+	}
+
+	service.audit(ctx, canonical.OperationRestore, actor.FromContext(ctx), before, product)
+	service.checkLowStock(ctx, canonical.Product{}, product)
+
 	return product, nil
 }
 
-func (service *service) DeleteProduct(id string) error {
-	product, err := service.repo.GetProductById(id)
+// PurgeDeletedProducts removes for good the products that have been in the
+// trash for longer than the retention period.
+func (service *service) PurgeDeletedProducts(ctx context.Context) (int64, error) {
+	ctx, cancel := service.withTimeout(ctx, "purge_deleted_products")
+	defer cancel()
+
+	ids, err := service.repo.PurgeDeletedProducts(ctx, time.Now().Add(-service.trashRetention))
 	if err != nil {
-		logrus.WithError(err).Error("error occurred while trying to get a product")
-		return err
+		service.logger.WithError(err).Error("error occurred while trying to purge deleted products")
+		return 0, errs.Wrap(err, "error occurred while trying to purge deleted products")
 	}
 
-	if product.Id == "" {
-		return fmt.Errorf("product not found on db")
+	for _, id := range ids {
+		service.audit(ctx, canonical.OperationPurge, actor.System, canonical.Product{Id: id}, canonical.Product{Id: id})
+	}
+
+	if len(ids) > 0 {
+		service.logger.WithField("purged", len(ids)).Info("purged deleted products")
+	}
+
+	return int64(len(ids)), nil
+}
+
+// validateProduct checks product against the rules of its fields and those
+// that span several of them.
+func validateProduct(product canonical.Product) error {
+	err := validation.Struct(product)
+	if err == nil {
+		err = checkPriceOverrides(product)
+	}
+
+	if err == nil {
+		err = checkStock(product)
+	}
+
+	if err == nil {
+		err = checkWindow(product)
 	}
 
-	err = service.repo.DeleteProduct(id)
 	if err != nil {
-		logrus.WithError(err).Error("error occurred while trying to delete a product")
-		return err
+		return errs.Wrap(err, "invalid product")
 	}
 
 	return nil
 }
+
+// checkWindow rejects publication windows that close before they open.
+func checkWindow(product canonical.Product) error {
+	if product.PublishAt != nil && product.UnpublishAt != nil && !product.UnpublishAt.After(*product.PublishAt) {
+		return errs.InvalidFields(errs.FieldError{Field: "unpublish_at", Message: "must be after publish_at"})
+	}
+
+	return nil
+}
+
+// checkQuery rejects sorts on unknown fields and contradictory criteria.
+func checkQuery(query canonical.ProductQuery) error {
+	seen := map[string]bool{}
+
+	for _, field := range query.Sort {
+		if !slices.Contains(canonical.SortableFields, field.Field) {
+			return errs.BadRequest(fmt.Sprintf("cannot sort by %q, expected one of %s", field.Field, strings.Join(canonical.SortableFields, ", ")))
+		}
+
+		if seen[field.Field] {
+			return errs.BadRequest(fmt.Sprintf("cannot sort by %q more than once", field.Field))
+		}
+
+		seen[field.Field] = true
+	}
+
+	for _, attribute := range query.Attributes {
+		if !canonical.ValidAttributeName(attribute.Name) {
+			return errs.BadRequest(fmt.Sprintf("cannot filter by attribute %q", attribute.Name))
+		}
+	}
+
+	for _, status := range query.Statuses {
+		if !slices.Contains(canonical.Statuses, status) {
+			return errs.BadRequest(fmt.Sprintf("unknown status %q, expected one of %s", status, strings.Join(canonical.Statuses, ", ")))
+		}
+	}
+
+	if query.PriceMin != nil && query.PriceMax != nil {
+		if query.PriceMin.Currency != query.PriceMax.Currency {
+			return errs.BadRequest("price_min and price_max must be in the same currency")
+		}
+
+		if query.PriceMin.Amount > query.PriceMax.Amount {
+			return errs.BadRequest("price_min must not be greater than price_max")
+		}
+	}
+
+	return nil
+}
+
+func normalizePage(page canonical.PageRequest) canonical.PageRequest {
+	if page.Limit <= 0 {
+		page.Limit = defaultPageLimit
+	}
+
+	if page.Limit > maxPageLimit {
+		page.Limit = maxPageLimit
+	}
+
+	return page
+}
+
+// withTimeout bounds ctx by the timeout configured for operation, if any.
+func (service *service) withTimeout(ctx context.Context, operation string) (context.Context, context.CancelFunc) {
+	timeout := service.timeouts.For(operation)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
@@ -1,11 +1,19 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/nelsonalves117/go-products-api/internal/actor"
 	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/config"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/money"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -17,45 +25,61 @@ func TestGetAllProducts_Success(t *testing.T) {
 		{
 			Id:        "xpto",
 			Name:      "test",
-			Category:  "testCategory",
-			Price:     200,
+			Category:  "testcategory",
+			Price:     brl(20000),
 			Stock:     10,
 			CreatedAt: time.Now(),
 		},
 	}
 
-	mockRepo.On("GetAllProducts").Return(productsTest, nil)
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{Statuses: []string{canonical.StatusActive}, ParentsOnly: true}, canonical.PageRequest{Limit: 50}).Return(canonical.ProductPage{Products: productsTest}, nil)
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{ParentIds: []string{"xpto"}}, canonical.PageRequest{Limit: 500}).Return(canonical.ProductPage{}, nil)
 
-	service := &service{
-		repo: mockRepo,
-	}
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
 
-	products, err := service.GetAllProducts()
+	page, err := service.GetAllProducts(context.Background(), canonical.ProductQuery{}, canonical.PageRequest{})
+	products := page.Products
 
 	assert.Nil(t, err)
 	assert.Equal(t, "xpto", products[0].Id)
 	assert.Equal(t, "test", products[0].Name)
-	assert.Equal(t, "testCategory", products[0].Category)
-	assert.Equal(t, float32(200), products[0].Price)
+	assert.Equal(t, "testcategory", products[0].Category)
+	assert.Equal(t, brl(20000), products[0].Price)
 	assert.Equal(t, 10, products[0].Stock)
 	assert.True(t, products[0].CreatedAt.After(time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local)))
 
 	mockRepo.AssertExpectations(t)
 }
+
 func TestGetAllProducts_Error(t *testing.T) {
 	mockRepo := new(MockRepository)
 
-	mockRepo.On("GetAllProducts").Return([]canonical.Product{}, errors.New("error occurred while trying to get all products"))
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{Statuses: []string{canonical.StatusActive}, ParentsOnly: true}, canonical.PageRequest{Limit: 50}).Return(canonical.ProductPage{}, errors.New("error occurred while trying to get all products"))
 
-	service := &service{
-		repo: mockRepo,
-	}
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
 
-	products, err := service.GetAllProducts()
+	page, err := service.GetAllProducts(context.Background(), canonical.ProductQuery{}, canonical.PageRequest{})
+	products := page.Products
 
 	assert.NotNil(t, err)
 	assert.Empty(t, products)
-	assert.Equal(t, "error occurred while trying to get all products", err.Error())
+	assert.ErrorContains(t, err, "error occurred while trying to get all products")
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAllProducts_LimitIsCapped(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{Statuses: []string{canonical.StatusActive}, ParentsOnly: true}, canonical.PageRequest{Limit: 500, Cursor: "abc"}).Return(canonical.ProductPage{NextCursor: "def", HasMore: true}, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	page, err := service.GetAllProducts(context.Background(), canonical.ProductQuery{}, canonical.PageRequest{Limit: 10000, Cursor: "abc"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "def", page.NextCursor)
+	assert.True(t, page.HasMore)
 
 	mockRepo.AssertExpectations(t)
 }
@@ -67,26 +91,27 @@ func TestGetProductsByCategory_Success(t *testing.T) {
 		{
 			Id:        "xpto",
 			Name:      "test",
-			Category:  "testCategory",
-			Price:     200,
+			Category:  "testcategory",
+			Price:     brl(20000),
 			Stock:     10,
 			CreatedAt: time.Now(),
 		},
 	}
 
-	mockRepo.On("GetProductsByCategory", "testCategory").Return(productsTest, nil)
+	mockRepo.On("GetCategory", mock.Anything, "testcategory").Return(testCategory, nil)
+	mockRepo.On("GetProductsByCategory", mock.Anything, []string{"testcategory"}, []string{canonical.StatusActive}, canonical.PageRequest{Limit: 50}).Return(canonical.ProductPage{Products: productsTest}, nil)
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{ParentIds: []string{"xpto"}}, canonical.PageRequest{Limit: 500}).Return(canonical.ProductPage{}, nil)
 
-	service := &service{
-		repo: mockRepo,
-	}
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
 
-	products, err := service.GetProductsByCategory("testCategory")
+	page, err := service.GetProductsByCategory(context.Background(), "testcategory", false, canonical.PageRequest{})
+	products := page.Products
 
 	assert.Nil(t, err)
 	assert.Equal(t, "xpto", products[0].Id)
 	assert.Equal(t, "test", products[0].Name)
-	assert.Equal(t, "testCategory", products[0].Category)
-	assert.Equal(t, float32(200), products[0].Price)
+	assert.Equal(t, "testcategory", products[0].Category)
+	assert.Equal(t, brl(20000), products[0].Price)
 	assert.Equal(t, 10, products[0].Stock)
 	assert.True(t, products[0].CreatedAt.After(time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local)))
 
@@ -96,17 +121,17 @@ func TestGetProductsByCategory_Success(t *testing.T) {
 func TestGetProductsByCategory_Error(t *testing.T) {
 	mockRepo := new(MockRepository)
 
-	mockRepo.On("GetProductsByCategory", "testCategory").Return([]canonical.Product{}, errors.New("error occurred while trying to get a product"))
+	mockRepo.On("GetCategory", mock.Anything, "testcategory").Return(testCategory, nil)
+	mockRepo.On("GetProductsByCategory", mock.Anything, []string{"testcategory"}, []string{canonical.StatusActive}, canonical.PageRequest{Limit: 50}).Return(canonical.ProductPage{}, errors.New("error occurred while trying to get a product"))
 
-	service := &service{
-		repo: mockRepo,
-	}
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
 
-	products, err := service.GetProductsByCategory("testCategory")
+	page, err := service.GetProductsByCategory(context.Background(), "testcategory", false, canonical.PageRequest{})
+	products := page.Products
 
 	assert.NotNil(t, err)
 	assert.Empty(t, products)
-	assert.Equal(t, "error occurred while trying to get a product", err.Error())
+	assert.ErrorContains(t, err, "error occurred while trying to get a product")
 
 	mockRepo.AssertExpectations(t)
 }
@@ -118,25 +143,24 @@ func TestGetProductById_Success(t *testing.T) {
 
 		Id:        "xpto",
 		Name:      "test",
-		Category:  "testCategory",
-		Price:     200,
+		Category:  "testcategory",
+		Price:     brl(20000),
 		Stock:     10,
 		CreatedAt: time.Now(),
 	}
 
-	mockRepo.On("GetProductById", "xpto").Return(productTest, nil)
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(productTest, nil)
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{ParentIds: []string{"xpto"}}, mock.Anything).Return(canonical.ProductPage{}, nil)
 
-	service := &service{
-		repo: mockRepo,
-	}
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
 
-	product, err := service.GetProductById("xpto")
+	product, err := service.GetProductById(context.Background(), "xpto")
 
 	assert.Nil(t, err)
 	assert.Equal(t, "xpto", product.Id)
 	assert.Equal(t, "test", product.Name)
-	assert.Equal(t, "testCategory", product.Category)
-	assert.Equal(t, float32(200), product.Price)
+	assert.Equal(t, "testcategory", product.Category)
+	assert.Equal(t, brl(20000), product.Price)
 	assert.Equal(t, 10, product.Stock)
 	assert.True(t, product.CreatedAt.After(time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local)))
 
@@ -146,17 +170,15 @@ func TestGetProductById_Success(t *testing.T) {
 func TestGetProductById_Error(t *testing.T) {
 	mockRepo := new(MockRepository)
 
-	mockRepo.On("GetProductById", "xpto").Return(canonical.Product{}, errors.New("error occurred while trying to get a product"))
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{}, errors.New("error occurred while trying to get a product"))
 
-	service := &service{
-		repo: mockRepo,
-	}
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
 
-	product, err := service.GetProductById("xpto")
+	product, err := service.GetProductById(context.Background(), "xpto")
 
 	assert.NotNil(t, err)
 	assert.Empty(t, product)
-	assert.Equal(t, "error occurred while trying to get a product", err.Error())
+	assert.ErrorContains(t, err, "error occurred while trying to get a product")
 
 	mockRepo.AssertExpectations(t)
 }
@@ -164,35 +186,41 @@ func TestGetProductById_Error(t *testing.T) {
 func TestCreateProduct_Success(t *testing.T) {
 	mockRepo := new(MockRepository)
 
+	mockRepo.On("GetCategory", mock.Anything, "testcategory").Return(testCategory, nil)
+
 	productTest := canonical.Product{
 		Name:     "test",
-		Category: "testCategory",
-		Price:    200,
+		Category: "testcategory",
+		Price:    brl(20000),
 		Stock:    10,
 	}
 
 	updatedProduct := canonical.Product{
 		Id:       "xpto",
 		Name:     "test",
-		Category: "testCategory",
-		Price:    200,
+		Category: "testcategory",
+		Price:    brl(20000),
 		Stock:    10,
 	}
 
-	mockRepo.On("CreateProduct", mock.MatchedBy(func(product canonical.Product) bool {
-		return product.Name == "test" && product.Category == "testCategory" && product.Price == 200 && product.Stock == 10
+	mockRepo.On("CreateProduct", mock.Anything, mock.MatchedBy(func(product canonical.Product) bool {
+		return product.Name == "test" && product.Category == "testcategory" && product.Price == brl(20000) && product.Stock == 10
 	})).Return(updatedProduct, nil)
 
-	service := &service{
-		repo: mockRepo,
-	}
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("AppendStockMovements", mock.Anything, mock.MatchedBy(func(movements []canonical.StockMovement) bool {
+		return len(movements) == 1 && movements[0].Type == canonical.MovementReceipt && movements[0].Warehouse == "main" && movements[0].Quantity == 10
+	})).Return(nil)
 
-	product, err := service.CreateProduct(productTest)
+	mockRepo.On("CreatePriceChange", mock.Anything, mock.Anything).Return(nil)
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	product, err := service.CreateProduct(context.Background(), productTest)
 
 	assert.Nil(t, err)
 	assert.Equal(t, "test", product.Name)
-	assert.Equal(t, "testCategory", product.Category)
-	assert.Equal(t, float32(200), product.Price)
+	assert.Equal(t, "testcategory", product.Category)
+	assert.Equal(t, brl(20000), product.Price)
 	assert.Equal(t, 10, product.Stock)
 
 	mockRepo.AssertExpectations(t)
@@ -201,26 +229,26 @@ func TestCreateProduct_Success(t *testing.T) {
 func TestCreateProduct_Error(t *testing.T) {
 	mockRepo := new(MockRepository)
 
+	mockRepo.On("GetCategory", mock.Anything, "testcategory").Return(testCategory, nil)
+
 	productTest := canonical.Product{
 		Name:     "test",
-		Category: "testCategory",
-		Price:    200,
+		Category: "testcategory",
+		Price:    brl(20000),
 		Stock:    10,
 	}
 
-	mockRepo.On("CreateProduct", mock.MatchedBy(func(product canonical.Product) bool {
-		return product.Name == "test" && product.Category == "testCategory" && product.Price == 200 && product.Stock == 10
+	mockRepo.On("CreateProduct", mock.Anything, mock.MatchedBy(func(product canonical.Product) bool {
+		return product.Name == "test" && product.Category == "testcategory" && product.Price == brl(20000) && product.Stock == 10
 	})).Return(canonical.Product{}, errors.New("error occurred while trying to create a product"))
 
-	service := &service{
-		repo: mockRepo,
-	}
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
 
-	product, err := service.CreateProduct(productTest)
+	product, err := service.CreateProduct(context.Background(), productTest)
 
 	assert.NotNil(t, err)
 	assert.Empty(t, product)
-	assert.Equal(t, "error occurred while trying to create a product", err.Error())
+	assert.ErrorContains(t, err, "error occurred while trying to create a product")
 
 	mockRepo.AssertExpectations(t)
 }
@@ -228,27 +256,31 @@ func TestCreateProduct_Error(t *testing.T) {
 func TestUpdateProduct_Success(t *testing.T) {
 	mockRepo := new(MockRepository)
 
+	mockRepo.On("GetCategory", mock.Anything, "testcategory").Return(testCategory, nil)
+
 	productTest := canonical.Product{
 		Name:     "test",
-		Category: "testCategory",
-		Price:    200,
+		Category: "testcategory",
+		Price:    brl(20000),
 		Stock:    10,
 	}
 
-	mockRepo.On("UpdateProduct", "xpto", mock.MatchedBy(func(product canonical.Product) bool {
-		return product.Name == "test" && product.Category == "testCategory" && product.Price == 200 && product.Stock == 10
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Name: "old", Category: "testcategory", Price: brl(10000), Stock: 10, Version: 1}, nil)
+	mockRepo.On("UpdateProduct", mock.Anything, "xpto", int64(1), mock.MatchedBy(func(product canonical.Product) bool {
+		return product.Name == "test" && product.Category == "testcategory" && product.Price == brl(20000) && product.Stock == 10
 	})).Return(productTest, nil)
 
-	service := &service{
-		repo: mockRepo,
-	}
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.Anything).Return(nil)
+
+	mockRepo.On("CreatePriceChange", mock.Anything, mock.Anything).Return(nil)
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
 
-	product, err := service.UpdateProduct("xpto", productTest)
+	product, err := service.UpdateProduct(context.Background(), "xpto", 1, productTest)
 
 	assert.Nil(t, err)
 	assert.Equal(t, "test", product.Name)
-	assert.Equal(t, "testCategory", product.Category)
-	assert.Equal(t, float32(200), product.Price)
+	assert.Equal(t, "testcategory", product.Category)
+	assert.Equal(t, brl(20000), product.Price)
 	assert.Equal(t, 10, product.Stock)
 
 	mockRepo.AssertExpectations(t)
@@ -257,26 +289,27 @@ func TestUpdateProduct_Success(t *testing.T) {
 func TestUpdateProduct_Error(t *testing.T) {
 	mockRepo := new(MockRepository)
 
+	mockRepo.On("GetCategory", mock.Anything, "testcategory").Return(testCategory, nil)
+
 	productTest := canonical.Product{
 		Name:     "test",
-		Category: "testCategory",
-		Price:    200,
+		Category: "testcategory",
+		Price:    brl(20000),
 		Stock:    10,
 	}
 
-	mockRepo.On("UpdateProduct", "xpto", mock.MatchedBy(func(product canonical.Product) bool {
-		return product.Name == "test" && product.Category == "testCategory" && product.Price == 200 && product.Stock == 10
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Name: "old", Category: "testcategory", Price: brl(10000), Stock: 10, Version: 1}, nil)
+	mockRepo.On("UpdateProduct", mock.Anything, "xpto", int64(1), mock.MatchedBy(func(product canonical.Product) bool {
+		return product.Name == "test" && product.Category == "testcategory" && product.Price == brl(20000) && product.Stock == 10
 	})).Return(canonical.Product{}, errors.New("error occurred while trying to update a product"))
 
-	service := &service{
-		repo: mockRepo,
-	}
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
 
-	product, err := service.UpdateProduct("xpto", productTest)
+	product, err := service.UpdateProduct(context.Background(), "xpto", 1, productTest)
 
 	assert.NotNil(t, err)
 	assert.Empty(t, product)
-	assert.Equal(t, "error occurred while trying to update a product", err.Error())
+	assert.ErrorContains(t, err, "error occurred while trying to update a product")
 
 	mockRepo.AssertExpectations(t)
 }
@@ -287,21 +320,24 @@ func TestDeleteProduct_Success(t *testing.T) {
 	productTest := canonical.Product{
 		Id:        "xpto",
 		Name:      "test",
-		Category:  "testCategory",
-		Price:     200,
+		Category:  "testcategory",
+		Price:     brl(20000),
 		Stock:     10,
 		CreatedAt: time.Now(),
 	}
 
-	mockRepo.On("GetProductById", "xpto").Return(productTest, nil)
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(productTest, nil)
 
-	mockRepo.On("DeleteProduct", "xpto").Return(nil)
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{ParentId: "xpto"}, canonical.PageRequest{Limit: 1}).Return(canonical.ProductPage{}, nil)
+	mockRepo.On("DeleteProduct", mock.Anything, "xpto", int64(1), mock.Anything, actor.Anonymous).Return(nil)
 
-	service := &service{
-		repo: mockRepo,
-	}
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.Anything).Return(nil)
+
+	mockRepo.On("CloseLowStockAlert", mock.Anything, "xpto").Return(nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
 
-	err := service.DeleteProduct("xpto")
+	err := service.DeleteProduct(context.Background(), "xpto", 1, false)
 
 	assert.Nil(t, err)
 
@@ -314,24 +350,997 @@ func TestDeleteProduct_Error(t *testing.T) {
 	productTest := canonical.Product{
 		Id:        "xpto",
 		Name:      "test",
-		Category:  "testCategory",
-		Price:     200,
+		Category:  "testcategory",
+		Price:     brl(20000),
 		Stock:     10,
 		CreatedAt: time.Now(),
 	}
 
-	mockRepo.On("GetProductById", "xpto").Return(productTest, nil)
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(productTest, nil)
 
-	mockRepo.On("DeleteProduct", "xpto").Return(errors.New("error occurred while trying to delete a product"))
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{ParentId: "xpto"}, canonical.PageRequest{Limit: 1}).Return(canonical.ProductPage{}, nil)
+	mockRepo.On("DeleteProduct", mock.Anything, "xpto", int64(1), mock.Anything, actor.Anonymous).Return(errors.New("error occurred while trying to delete a product"))
 
-	service := &service{
-		repo: mockRepo,
-	}
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
 
-	err := service.DeleteProduct("xpto")
+	err := service.DeleteProduct(context.Background(), "xpto", 1, false)
 
 	assert.NotNil(t, err)
-	assert.Equal(t, "error occurred while trying to delete a product", err.Error())
+	assert.ErrorContains(t, err, "error occurred while trying to delete a product")
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetProductById_NotFound(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{}, errs.NotFound("product xpto not found"))
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.GetProductById(context.Background(), "xpto")
+
+	assert.Equal(t, errs.KindNotFound, errs.KindOf(err))
+	assert.Equal(t, "product xpto not found", errs.Detail(err))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteProduct_NotFound(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{}, errs.NotFound("product xpto not found"))
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	err := service.DeleteProduct(context.Background(), "xpto", 1, false)
+
+	assert.Equal(t, errs.KindNotFound, errs.KindOf(err))
+
+	mockRepo.AssertNotCalled(t, "DeleteProduct", mock.Anything, "xpto", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateProduct_Invalid(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	productTest := canonical.Product{
+		Name:     " ",
+		Category: strings.Repeat("c", 61),
+		Price:    brl(-100),
+		Stock:    10,
+	}
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.CreateProduct(context.Background(), productTest)
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	assert.Equal(t, []errs.FieldError{
+		{Field: "name", Message: "is required"},
+		{Field: "category", Message: "must have at most 60 characters"},
+		{Field: "price", Message: "must be greater than or equal to 0"},
+	}, errs.Fields(err))
+
+	mockRepo.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+}
+
+func TestGetProductById_AppliesOperationTimeout(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetProductById", mock.MatchedBy(func(ctx context.Context) bool {
+		deadline, ok := ctx.Deadline()
+		return ok && time.Until(deadline) <= time.Second
+	}), "xpto").Return(canonical.Product{Id: "xpto"}, nil)
+	mockRepo.On("GetAllProducts", mock.Anything, mock.Anything, mock.Anything).Return(canonical.ProductPage{}, nil)
+
+	cfg := config.Config{
+		Timeouts: config.Timeouts{
+			Default:    time.Minute,
+			Operations: map[string]time.Duration{"get_product_by_id": time.Second},
+		},
+	}
+
+	service := New(cfg, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.GetProductById(context.Background(), "xpto")
+
+	assert.Nil(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchProduct_Success(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	storedProduct := canonical.Product{
+		Id:       "xpto",
+		Name:     "test",
+		Category: "testcategory",
+		Price:    brl(20000),
+		Stock:    10,
+	}
+
+	price := brl(1000)
+	patch := canonical.ProductPatch{Price: &price}
+
+	patchedProduct := storedProduct
+	patchedProduct.Price = price
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(storedProduct, nil)
+	mockRepo.On("PatchProduct", mock.Anything, "xpto", int64(1), patch).Return(patchedProduct, nil)
+
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.Anything).Return(nil)
+
+	mockRepo.On("CreatePriceChange", mock.Anything, mock.Anything).Return(nil)
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	product, err := service.PatchProduct(context.Background(), "xpto", 1, patch)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "xpto", product.Id)
+	assert.Equal(t, "test", product.Name)
+	assert.Equal(t, brl(1000), product.Price)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchProduct_Invalid(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	storedProduct := canonical.Product{
+		Id:       "xpto",
+		Name:     "test",
+		Category: "testcategory",
+		Price:    brl(20000),
+		Stock:    10,
+	}
+
+	name := ""
+	patch := canonical.ProductPatch{Name: &name}
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(storedProduct, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.PatchProduct(context.Background(), "xpto", 1, patch)
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	assert.Equal(t, []errs.FieldError{{Field: "name", Message: "is required"}}, errs.Fields(err))
+
+	mockRepo.AssertNotCalled(t, "PatchProduct", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSearchProducts_RequiresQuery(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.SearchProducts(context.Background(), "  ", canonical.PageRequest{})
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	assert.Equal(t, []errs.FieldError{{Field: "q", Message: "is required"}}, errs.Fields(err))
+
+	mockRepo.AssertNotCalled(t, "SearchProducts", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetAllProducts_UnknownSortField(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	query := canonical.ProductQuery{Sort: []canonical.SortField{{Field: "colour"}}}
+	_, err := service.GetAllProducts(context.Background(), query, canonical.PageRequest{})
+
+	assert.Equal(t, errs.KindBadRequest, errs.KindOf(err))
+
+	mockRepo.AssertNotCalled(t, "GetAllProducts", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteProduct_RecordsActor(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Version: 1}, nil)
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{ParentId: "xpto"}, canonical.PageRequest{Limit: 1}).Return(canonical.ProductPage{}, nil)
+	mockRepo.On("DeleteProduct", mock.Anything, "xpto", int64(1), mock.Anything, "jane").Return(nil)
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("CloseLowStockAlert", mock.Anything, "xpto").Return(nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	err := service.DeleteProduct(actor.NewContext(context.Background(), "jane"), "xpto", 1, false)
+
+	assert.Nil(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgeDeletedProducts_UsesRetention(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("PurgeDeletedProducts", mock.Anything, mock.MatchedBy(func(deletedBefore time.Time) bool {
+		return time.Since(deletedBefore) >= 24*time.Hour && time.Since(deletedBefore) < 25*time.Hour
+	})).Return([]string{"a", "b"}, nil)
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.MatchedBy(func(entry canonical.AuditEntry) bool {
+		return entry.Operation == canonical.OperationPurge && entry.Actor == actor.System
+	})).Return(nil).Twice()
+
+	service := New(config.Config{Trash: config.Trash{Retention: 24 * time.Hour}}, mockRepo, nil, nil, logrus.New())
+
+	purged, err := service.PurgeDeletedProducts(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), purged)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchProduct_RecordsAuditEntry(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	storedProduct := canonical.Product{Id: "xpto", Name: "test", Category: "testcategory", Price: brl(20000), Stock: 10, Version: 1}
+
+	price := brl(1000)
+	patch := canonical.ProductPatch{Price: &price}
+
+	patchedProduct := storedProduct
+	patchedProduct.Price = price
+	patchedProduct.Version = 2
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(storedProduct, nil)
+	mockRepo.On("PatchProduct", mock.Anything, "xpto", int64(1), patch).Return(patchedProduct, nil)
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.MatchedBy(func(entry canonical.AuditEntry) bool {
+		return entry.ProductId == "xpto" &&
+			entry.Operation == canonical.OperationPatch &&
+			entry.Actor == "jane" &&
+			assert.ObjectsAreEqual([]canonical.FieldChange{{Field: "price", Before: "200.00 BRL", After: "10.00 BRL"}}, entry.Changes)
+	})).Return(nil)
+
+	mockRepo.On("CreatePriceChange", mock.Anything, mock.Anything).Return(nil)
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.PatchProduct(actor.NewContext(context.Background(), "jane"), "xpto", 1, patch)
+
+	assert.Nil(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateProduct_AuditFailureIsNotReturned(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetCategory", mock.Anything, "testcategory").Return(testCategory, nil)
+
+	productTest := canonical.Product{Name: "test", Category: "testcategory", Price: brl(20000), Stock: 10}
+
+	mockRepo.On("CreateProduct", mock.Anything, mock.Anything).Return(productTest, nil)
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.Anything).Return(errors.New("audit unavailable"))
+	mockRepo.On("AppendStockMovements", mock.Anything, mock.Anything).Return(errors.New("ledger unavailable"))
+
+	mockRepo.On("CreatePriceChange", mock.Anything, mock.Anything).Return(nil)
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.CreateProduct(context.Background(), productTest)
+
+	assert.Nil(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSchedulePriceChange_RequiresFutureTime(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.SchedulePriceChange(context.Background(), "xpto", brl(1000), time.Now().Add(-time.Hour))
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	assert.Equal(t, []errs.FieldError{{Field: "effective_at", Message: "must be in the future"}}, errs.Fields(err))
+
+	mockRepo.AssertNotCalled(t, "CreatePriceChange", mock.Anything, mock.Anything)
+}
+
+func TestSchedulePriceChange_RequiresProductCurrency(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Price: brl(20000)}, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.SchedulePriceChange(context.Background(), "xpto", money.Money{Amount: 1000, Currency: "USD"}, time.Now().Add(time.Hour))
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	assert.Equal(t, []errs.FieldError{{Field: "price", Message: "must be in BRL, the currency of the product"}}, errs.Fields(err))
+
+	mockRepo.AssertNotCalled(t, "CreatePriceChange", mock.Anything, mock.Anything)
+}
+
+func TestGetPriceAt(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	start := time.Now().Add(24 * time.Hour)
+	changes := []canonical.PriceChange{
+		{Id: "a", Price: brl(20000), EffectiveAt: start.Add(-30 * 24 * time.Hour), Status: canonical.PriceApplied},
+		{Id: "overdue", Price: brl(18000), EffectiveAt: start.Add(-25 * time.Hour), Status: canonical.PriceScheduled},
+		{Id: "b", Price: brl(15000), EffectiveAt: start, Status: canonical.PriceScheduled},
+		{Id: "c", Price: brl(100), EffectiveAt: start.Add(time.Hour), Status: canonical.PriceSkipped},
+		{Id: "d", Price: brl(20000), EffectiveAt: start.Add(72 * time.Hour), Status: canonical.PriceScheduled},
+	}
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto"}, nil)
+	mockRepo.On("GetPriceChanges", mock.Anything, "xpto").Return(changes, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	price, err := service.GetPriceAt(context.Background(), "xpto", start.Add(2*time.Hour))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "b", price.Change.Id)
+	assert.Equal(t, brl(15000), price.Change.Price)
+	assert.True(t, price.Projected)
+
+	// The overdue change has not been applied, so the product never had its
+	// price.
+	price, err = service.GetPriceAt(context.Background(), "xpto", time.Now().Add(-time.Minute))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "a", price.Change.Id)
+	assert.False(t, price.Projected)
+
+	_, err = service.GetPriceAt(context.Background(), "xpto", start.Add(-60*24*time.Hour))
+
+	assert.Equal(t, errs.KindNotFound, errs.KindOf(err))
+}
+
+func TestGetPriceAt_WithoutTimeline(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	createdAt := time.Now().Add(-30 * 24 * time.Hour)
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Price: brl(20000), CreatedAt: createdAt}, nil)
+	mockRepo.On("GetPriceChanges", mock.Anything, "xpto").Return([]canonical.PriceChange{}, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	price, err := service.GetPriceAt(context.Background(), "xpto", time.Now())
+
+	assert.Nil(t, err)
+	assert.Equal(t, brl(20000), price.Change.Price)
+	assert.Equal(t, canonical.PriceApplied, price.Change.Status)
+	assert.Empty(t, price.Change.Id)
+	assert.False(t, price.Projected)
+
+	_, err = service.GetPriceAt(context.Background(), "xpto", createdAt.Add(-time.Hour))
+
+	assert.Equal(t, errs.KindNotFound, errs.KindOf(err))
+}
+
+func TestApplyDuePriceChanges(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	price := brl(15000)
+	due := []canonical.PriceChange{
+		{Id: "a", ProductId: "xpto", Price: price, Status: canonical.PriceScheduled},
+		{Id: "b", ProductId: "gone", Price: price, Status: canonical.PriceScheduled},
+	}
+
+	storedProduct := canonical.Product{Id: "xpto", Name: "test", Category: "testcategory", Price: brl(20000), Version: 3}
+	patchedProduct := storedProduct
+	patchedProduct.Price = price
+	patchedProduct.Version = 4
+
+	mockRepo.On("GetDuePriceChanges", mock.Anything, mock.Anything, priceBatchSize).Return(due, nil)
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(storedProduct, nil)
+	mockRepo.On("GetProductById", mock.Anything, "gone").Return(canonical.Product{}, errs.NotFound("product gone not found"))
+	mockRepo.On("PatchProduct", mock.Anything, "xpto", int64(3), canonical.ProductPatch{Price: &price}).Return(patchedProduct, nil)
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.MatchedBy(func(entry canonical.AuditEntry) bool {
+		return entry.Actor == actor.System && entry.ProductId == "xpto"
+	})).Return(nil)
+	mockRepo.On("CompletePriceChange", mock.Anything, "a", canonical.PriceApplied, mock.Anything).Return(nil)
+	mockRepo.On("CompletePriceChange", mock.Anything, "b", canonical.PriceSkipped, mock.Anything).Return(nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	applied, err := service.ApplyDuePriceChanges(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, applied)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAdjustStock(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	stored := canonical.Product{Id: "xpto", Stock: 10, StockLevels: []canonical.StockLevel{{Warehouse: "main", Quantity: 10}}, Version: 1}
+	raced := canonical.Product{Id: "xpto", Stock: 8, StockLevels: []canonical.StockLevel{{Warehouse: "main", Quantity: 8}}, Version: 2}
+	levels := []canonical.StockLevel{{Warehouse: "main", Quantity: 8}, {Warehouse: "north", Quantity: 5}}
+	adjusted := canonical.Product{Id: "xpto", Stock: 13, StockLevels: levels, Version: 3}
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(stored, nil).Once()
+	mockRepo.On("SetStockLevels", mock.Anything, "xpto", int64(1), mock.Anything).Return(canonical.Product{}, errs.VersionConflict("product xpto is not at version 1")).Once()
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(raced, nil).Once()
+	mockRepo.On("SetStockLevels", mock.Anything, "xpto", int64(2), levels).Return(adjusted, nil).Once()
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.MatchedBy(func(entry canonical.AuditEntry) bool {
+		return entry.Operation == canonical.OperationAdjustStock
+	})).Return(nil)
+	mockRepo.On("AppendStockMovements", mock.Anything, mock.MatchedBy(func(movements []canonical.StockMovement) bool {
+		movement := movements[0]
+		return len(movements) == 1 && movement.ProductId == "xpto" && movement.Type == canonical.MovementReceipt &&
+			movement.Warehouse == "north" && movement.Quantity == 5 && movement.Reference == "PO-1" && movement.Id != ""
+	})).Return(nil)
+	mockRepo.On("GetCategoryThreshold", mock.Anything, "").Return(0, nil)
+	mockRepo.On("CloseLowStockAlert", mock.Anything, "xpto").Return(nil)
+
+	service := New(config.Config{Stock: config.Stock{Warehouses: []string{"main", "north"}}}, mockRepo, nil, nil, logrus.New())
+
+	movement := canonical.StockMovement{Warehouse: "north", Type: canonical.MovementReceipt, Quantity: 5, Reference: "PO-1"}
+	stock, err := service.AdjustStock(context.Background(), "xpto", movement)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 13, stock.Available)
+	assert.Equal(t, levels, stock.Levels)
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestAdjustStock_Negative(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Stock: 10, Version: 1}, nil)
+
+	service := New(config.Config{Stock: config.Stock{Warehouses: []string{"main", "north"}}}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.AdjustStock(context.Background(), "xpto", canonical.StockMovement{Warehouse: "north", Quantity: -1})
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+
+	_, err = service.AdjustStock(context.Background(), "xpto", canonical.StockMovement{Warehouse: "south", Quantity: 1})
+	assert.Equal(t, errs.KindNotFound, errs.KindOf(err))
+
+	_, err = service.AdjustStock(context.Background(), "xpto", canonical.StockMovement{Warehouse: "main", Type: canonical.MovementReceipt, Quantity: -1})
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+
+	_, err = service.AdjustStock(context.Background(), "xpto", canonical.StockMovement{Warehouse: "main", Type: canonical.MovementReservation, Quantity: 1})
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+
+	mockRepo.AssertNotCalled(t, "SetStockLevels", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAdjustStock_Backorders(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	levels := []canonical.StockLevel{{Warehouse: "main", Quantity: -2}}
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Backorders: true, Version: 1}, nil)
+	mockRepo.On("SetStockLevels", mock.Anything, "xpto", int64(1), levels).Return(canonical.Product{Id: "xpto", Stock: -2, StockLevels: levels, Backorders: true, Version: 2}, nil)
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("AppendStockMovements", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("GetCategoryThreshold", mock.Anything, "").Return(0, nil)
+	mockRepo.On("CloseLowStockAlert", mock.Anything, "xpto").Return(nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	stock, err := service.AdjustStock(context.Background(), "xpto", canonical.StockMovement{Warehouse: "main", Type: canonical.MovementSale, Quantity: -2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, -2, stock.Available)
+}
+
+func TestAdjustStock_BelowReserved(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Stock: 10, Reserved: 8, Version: 1}, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.AdjustStock(context.Background(), "xpto", canonical.StockMovement{Warehouse: "main", Type: canonical.MovementSale, Quantity: -3})
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+
+	mockRepo.AssertNotCalled(t, "SetStockLevels", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateProduct_BelowReserved(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetCategory", mock.Anything, "testcategory").Return(testCategory, nil)
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Name: "old", Category: "testcategory", Price: brl(10000), Stock: 10, Reserved: 8, Version: 1}, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.UpdateProduct(context.Background(), "xpto", 1, canonical.Product{Name: "test", Category: "testcategory", Price: brl(10000), Stock: 5})
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+
+	mockRepo.AssertNotCalled(t, "UpdateProduct", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateProduct_RaisesLowStockAlert(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetCategory", mock.Anything, "testcategory").Return(testCategory, nil)
+
+	productTest := canonical.Product{Name: "test", Category: "testcategory", Price: brl(20000), Stock: 3}
+	updated := productTest
+	updated.Id = "xpto"
+	updated.Version = 2
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Name: "test", Category: "testcategory", Price: brl(20000), Stock: 10, Version: 1}, nil)
+	mockRepo.On("UpdateProduct", mock.Anything, "xpto", int64(1), mock.Anything).Return(updated, nil)
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("AppendStockMovements", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("GetCategoryThreshold", mock.Anything, "testcategory").Return(5, nil)
+	mockRepo.On("OpenLowStockAlert", mock.Anything, mock.MatchedBy(func(alert canonical.LowStockAlert) bool {
+		return alert.ProductId == "xpto" && alert.Stock == 3 && alert.Threshold == 5
+	})).Return(true, nil).Once()
+
+	notifier := new(recordingNotifier)
+	service := New(config.Config{}, mockRepo, nil, notifier, logrus.New())
+
+	_, err := service.UpdateProduct(context.Background(), "xpto", 1, productTest)
+	assert.Nil(t, err)
+
+	assert.Empty(t, notifier.alerts)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSendLowStockAlerts_RetriesFailedSends(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	alerts := []canonical.LowStockAlert{
+		{ProductId: "a", Stock: 1, Threshold: 5},
+		{ProductId: "b", Stock: 2, Threshold: 5},
+	}
+
+	mockRepo.On("GetUnsentLowStockAlerts", mock.Anything, alertBatchSize).Return(alerts, nil)
+	mockRepo.On("MarkLowStockAlertSent", mock.Anything, alerts[0], mock.Anything).Return(nil).Once()
+
+	notifier := &recordingNotifier{fail: map[string]bool{"b": true}}
+	service := New(config.Config{}, mockRepo, nil, notifier, logrus.New())
+
+	sent, err := service.SendLowStockAlerts(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Len(t, notifier.alerts, 1)
+	mockRepo.AssertNotCalled(t, "MarkLowStockAlertSent", mock.Anything, alerts[1], mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSetCategoryThreshold_EvaluatesProducts(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	products := []canonical.Product{
+		{Id: "low", Category: "testcategory", Stock: 2},
+		{Id: "stocked", Category: "testcategory", Stock: 8},
+		{Id: "own", Category: "testcategory", Stock: 1, ReorderThreshold: 1},
+	}
+
+	mockRepo.On("GetCategory", mock.Anything, "testcategory").Return(testCategory, nil)
+	mockRepo.On("SetCategoryThreshold", mock.Anything, "testcategory", 5).Return(nil)
+	mockRepo.On("GetProductsByCategory", mock.Anything, []string{"testcategory"}, []string(nil), canonical.PageRequest{Limit: maxPageLimit}).Return(canonical.ProductPage{Products: products}, nil)
+	mockRepo.On("OpenLowStockAlert", mock.Anything, mock.MatchedBy(func(alert canonical.LowStockAlert) bool {
+		return alert.ProductId == "low" && alert.Threshold == 5
+	})).Return(true, nil).Once()
+	mockRepo.On("CloseLowStockAlert", mock.Anything, "stocked").Return(nil).Once()
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.SetCategoryThreshold(context.Background(), "testcategory", 5)
+
+	assert.Nil(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateProduct_UnknownCategory(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetCategory", mock.Anything, "phones").Return(canonical.Category{}, errs.NotFound("category phones not found"))
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.CreateProduct(context.Background(), canonical.Product{Name: "test", Category: "Phones", Price: brl(20000)})
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	mockRepo.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+}
+
+func TestGetProductsByCategory_IncludeDescendants(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	electronics := canonical.Category{Slug: "electronics", Path: "/electronics"}
+	subtree := []canonical.Category{electronics, {Slug: "phones", Parent: "electronics", Path: "/electronics/phones"}}
+
+	mockRepo.On("GetCategory", mock.Anything, "electronics").Return(electronics, nil)
+	mockRepo.On("GetCategorySubtree", mock.Anything, "/electronics").Return(subtree, nil)
+	mockRepo.On("GetProductsByCategory", mock.Anything, []string{"electronics", "phones"}, []string{canonical.StatusActive}, canonical.PageRequest{Limit: 50}).Return(canonical.ProductPage{}, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.GetProductsByCategory(context.Background(), "Electronics", true, canonical.PageRequest{})
+
+	assert.Nil(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateCategory_RejectsMoveIntoSubtree(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetCategory", mock.Anything, "electronics").Return(canonical.Category{Slug: "electronics", Path: "/electronics", Version: 1}, nil)
+	mockRepo.On("GetCategory", mock.Anything, "phones").Return(canonical.Category{Slug: "phones", Parent: "electronics", Path: "/electronics/phones", Version: 1}, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.UpdateCategory(context.Background(), "electronics", 1, canonical.Category{Name: "Electronics", Parent: "phones"})
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	mockRepo.AssertNotCalled(t, "UpdateCategory", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReleaseExpiredReservations(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	expired := []canonical.Reservation{
+		{Id: "a", ProductId: "xpto", Quantity: 2, Status: canonical.ReservationHeld},
+		{Id: "b", ProductId: "xpto", Quantity: 1, Status: canonical.ReservationHeld},
+	}
+	completed := expired[0]
+	completed.Status = canonical.ReservationExpired
+
+	mockRepo.On("GetExpiredReservations", mock.Anything, mock.Anything, reservationBatchSize).Return(expired, nil)
+	mockRepo.On("CompleteReservation", mock.Anything, "a", canonical.ReservationExpired, mock.Anything).Return(completed, nil)
+	mockRepo.On("CompleteReservation", mock.Anything, "b", canonical.ReservationExpired, mock.Anything).Return(canonical.Reservation{}, errs.Conflict("reservation b is no longer held"))
+	mockRepo.On("ReleaseStock", mock.Anything, "xpto", 2).Return(nil).Once()
+	mockRepo.On("AppendStockMovements", mock.Anything, mock.MatchedBy(func(movements []canonical.StockMovement) bool {
+		movement := movements[0]
+		return movement.Type == canonical.MovementReservation && movement.Quantity == -2 && movement.Reference == "a" && movement.Actor == actor.System
+	})).Return(nil).Once()
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	released, err := service.ReleaseExpiredReservations(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, released)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfirmReservation_ReopensWhenStockCannotBeTaken(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	held := canonical.Reservation{Id: "r", ProductId: "xpto", Quantity: 2, Status: canonical.ReservationHeld, ExpiresAt: time.Now().Add(time.Minute)}
+	confirmed := held
+	confirmed.Status = canonical.ReservationConfirmed
+
+	product := canonical.Product{Id: "xpto", Version: 3, Stock: 5, StockLevels: []canonical.StockLevel{{Warehouse: "main", Quantity: 5}}, Reserved: 2}
+
+	mockRepo.On("GetReservation", mock.Anything, "r").Return(held, nil)
+	mockRepo.On("CompleteReservation", mock.Anything, "r", canonical.ReservationConfirmed, mock.Anything).Return(confirmed, nil)
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(product, nil)
+	mockRepo.On("TakeReservedStock", mock.Anything, "xpto", int64(3), mock.Anything, 2).Return(canonical.Product{}, errs.VersionConflict("product xpto is no longer at version 3"))
+	mockRepo.On("ReopenReservation", mock.Anything, "r").Return(held, nil).Once()
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.ConfirmReservation(context.Background(), "xpto", "r")
+
+	assert.Equal(t, errs.KindVersionConflict, errs.KindOf(err))
+	mockRepo.AssertNumberOfCalls(t, "TakeReservedStock", stockWriteAttempts)
+	mockRepo.AssertNotCalled(t, "AppendStockMovements", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReconcileStock(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	products := []canonical.Product{
+		{Id: "xpto", Stock: 5, StockLevels: []canonical.StockLevel{{Warehouse: "main", Quantity: 5}}, Reserved: 1},
+		{Id: "abcd", Stock: 2, StockLevels: []canonical.StockLevel{{Warehouse: "main", Quantity: 2}}},
+	}
+	balances := []canonical.StockBalance{
+		{ProductId: "xpto", Levels: []canonical.StockLevel{{Warehouse: "main", Quantity: 3}}},
+		{ProductId: "abcd", Levels: []canonical.StockLevel{{Warehouse: "main", Quantity: 2}}},
+	}
+
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{}, canonical.PageRequest{Limit: maxPageLimit}).Return(canonical.ProductPage{Products: products}, nil)
+	mockRepo.On("GetStockBalances", mock.Anything, []string{"xpto", "abcd"}).Return(balances, nil)
+	mockRepo.On("AppendStockMovements", mock.Anything, mock.MatchedBy(func(movements []canonical.StockMovement) bool {
+		return len(movements) == 2 &&
+			movements[0].Type == canonical.MovementAdjustment && movements[0].Warehouse == "main" && movements[0].Quantity == 2 &&
+			movements[1].Type == canonical.MovementReservation && movements[1].Quantity == 1
+	})).Return(nil).Once()
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	discrepancies, err := service.ReconcileStock(context.Background(), true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []canonical.StockDiscrepancy{
+		{ProductId: "xpto", Field: canonical.FieldStock, Warehouse: "main", Stored: 5, Ledger: 3},
+		{ProductId: "xpto", Field: "reserved", Stored: 1, Ledger: 0},
+	}, discrepancies)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateVariant_InheritsFromParent(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	parent := canonical.Product{Id: "xpto", Name: "T-shirt", Category: "testcategory", Price: brl(5990), Version: 1}
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(parent, nil)
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{ParentId: "xpto"}, canonical.PageRequest{Limit: maxVariants}).Return(canonical.ProductPage{}, nil)
+	mockRepo.On("CreateProduct", mock.Anything, mock.MatchedBy(func(product canonical.Product) bool {
+		return product.ParentId == "xpto" && product.SKU == "TS-RED-M" && product.Name == "T-shirt (red, M)" &&
+			product.Category == "testcategory" && product.Price == brl(5990) && product.Stock == 3
+	})).Return(canonical.Product{Id: "abcd", ParentId: "xpto", Name: "T-shirt (red, M)", Price: brl(5990), Stock: 3}, nil)
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("CreatePriceChange", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("AppendStockMovements", mock.Anything, mock.Anything).Return(nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	variant, err := service.CreateVariant(context.Background(), "xpto", canonical.Product{
+		SKU:               "TS-RED-M",
+		VariantAttributes: map[string]string{"size": "M", "color": "red"},
+		Stock:             3,
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "T-shirt (red, M)", variant.Name)
+	assert.Equal(t, brl(5990), variant.Price)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateVariant_RejectsDuplicateAttributes(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	parent := canonical.Product{Id: "xpto", Name: "T-shirt", Category: "testcategory", Price: brl(5990), Version: 1}
+	sibling := canonical.Product{Id: "abcd", ParentId: "xpto", SKU: "TS-RED-M", VariantAttributes: map[string]string{"size": "M", "color": "red"}}
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(parent, nil)
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{ParentId: "xpto"}, mock.Anything).Return(canonical.ProductPage{Products: []canonical.Product{sibling}}, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.CreateVariant(context.Background(), "xpto", canonical.Product{
+		SKU:               "TS-RED-M-2",
+		VariantAttributes: map[string]string{"color": "red", "size": "M"},
+		Price:             brl(6990),
+	})
+
+	assert.Equal(t, errs.KindConflict, errs.KindOf(err))
+
+	mockRepo.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateVariant_Invalid(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	parent := canonical.Product{Id: "xpto", Name: "T-shirt", Category: "testcategory", Price: brl(5990), Version: 1}
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(parent, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.CreateVariant(context.Background(), "xpto", canonical.Product{
+		Price: money.Money{Amount: 1000, Currency: "USD"},
+	})
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	assert.Equal(t, []errs.FieldError{
+		{Field: "sku", Message: "is required"},
+		{Field: "variant_attributes", Message: "must not be empty"},
+		{Field: "price", Message: "must be in BRL, the currency of the parent"},
+	}, errs.Fields(err))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetProductById_SummarizesVariants(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	variants := []canonical.Product{
+		{Id: "a", ParentId: "xpto", Price: brl(5990), Stock: 4, Reserved: 1},
+		{Id: "b", ParentId: "xpto", Price: brl(6990), Stock: 2},
+		{Id: "c", ParentId: "xpto", Price: brl(4990), Stock: 0},
+	}
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Price: brl(5990)}, nil)
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{ParentIds: []string{"xpto"}}, mock.Anything).Return(canonical.ProductPage{Products: variants}, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	product, err := service.GetProductById(context.Background(), "xpto")
+
+	assert.Nil(t, err)
+	assert.Equal(t, &canonical.VariantSummary{Count: 3, Stock: 6, Available: 5, PriceMin: brl(4990), PriceMax: brl(6990)}, product.Variants)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateProduct_InvalidAttributes(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	category := testCategory
+	category.Attributes = []canonical.AttributeDefinition{
+		{Name: "voltage", Type: canonical.AttributeNumber, Required: true},
+		{Name: "plug", Type: canonical.AttributeEnum, Values: []string{"a", "c"}},
+		{Name: "wireless", Type: canonical.AttributeBool},
+	}
+
+	mockRepo.On("GetCategory", mock.Anything, "testcategory").Return(category, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.CreateProduct(context.Background(), canonical.Product{
+		Name:       "test",
+		Category:   "testcategory",
+		Price:      brl(20000),
+		Attributes: map[string]any{"plug": "b", "wireless": "yes", "material": "cotton"},
+	})
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	assert.Equal(t, []errs.FieldError{
+		{Field: "attributes.voltage", Message: "is required"},
+		{Field: "attributes.plug", Message: "must be one of a, c"},
+		{Field: "attributes.wireless", Message: "must be true or false"},
+		{Field: "attributes.material", Message: "is not defined for category testcategory"},
+	}, errs.Fields(err))
+
+	mockRepo.AssertNotCalled(t, "CreateProduct", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateCategory_InvalidSchema(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.CreateCategory(context.Background(), canonical.Category{
+		Name: "Apparel",
+		Attributes: []canonical.AttributeDefinition{
+			{Name: "material", Type: canonical.AttributeString},
+			{Name: "material", Type: canonical.AttributeString},
+			{Name: "Size", Type: canonical.AttributeString},
+			{Name: "fit", Type: canonical.AttributeEnum},
+			{Name: "weight", Type: "decimal"},
+		},
+	})
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	assert.Equal(t, []errs.FieldError{
+		{Field: "attributes[1]", Message: "name material is already defined"},
+		{Field: "attributes[2]", Message: "name must be up to 40 lower case letters, digits and underscores, starting with a letter"},
+		{Field: "attributes[3]", Message: "values must not be empty for an enum"},
+		{Field: "attributes[4]", Message: "type must be one of string, number, enum, bool"},
+	}, errs.Fields(err))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransitionProduct_RecordsActor(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	status := canonical.StatusActive
+	before := canonical.Product{Id: "xpto", Status: canonical.StatusDraft, Version: 1}
+	after := canonical.Product{Id: "xpto", Status: canonical.StatusActive, Version: 2}
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(before, nil)
+	mockRepo.On("PatchProduct", mock.Anything, "xpto", int64(1), canonical.ProductPatch{Status: &status}).Return(after, nil)
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.MatchedBy(func(entry canonical.AuditEntry) bool {
+		return entry.Operation == canonical.OperationTransition && entry.Actor == "jane" &&
+			len(entry.Changes) == 1 && entry.Changes[0] == canonical.FieldChange{Field: "status", Before: canonical.StatusDraft, After: canonical.StatusActive}
+	})).Return(nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	product, err := service.TransitionProduct(actor.NewContext(context.Background(), "jane"), "xpto", 1, canonical.StatusActive)
+
+	assert.Nil(t, err)
+	assert.Equal(t, canonical.StatusActive, product.Status)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTransitionProduct_RejectsIllegalTransition(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Status: canonical.StatusArchived, Version: 1}, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.TransitionProduct(context.Background(), "xpto", 1, canonical.StatusActive)
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	assert.Equal(t, "product xpto cannot go from archived to active", errs.Detail(err))
+
+	_, err = service.TransitionProduct(context.Background(), "xpto", 1, "retired")
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+
+	mockRepo.AssertNotCalled(t, "PatchProduct", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteProduct_RefusesActiveWithoutForce(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	mockRepo.On("GetProductById", mock.Anything, "xpto").Return(canonical.Product{Id: "xpto", Status: canonical.StatusActive, Version: 1}, nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	err := service.DeleteProduct(context.Background(), "xpto", 1, false)
+
+	assert.Equal(t, errs.KindConflict, errs.KindOf(err))
+
+	mockRepo.On("GetAllProducts", mock.Anything, canonical.ProductQuery{ParentId: "xpto"}, canonical.PageRequest{Limit: 1}).Return(canonical.ProductPage{}, nil)
+	mockRepo.On("DeleteProduct", mock.Anything, "xpto", int64(1), mock.Anything, actor.Anonymous).Return(nil)
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("CloseLowStockAlert", mock.Anything, "xpto").Return(nil)
+
+	err = service.DeleteProduct(context.Background(), "xpto", 1, true)
+
+	assert.Nil(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchProduct_SeesUnpublishedProducts(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	future := time.Now().Add(time.Hour)
+	stored := canonical.Product{Id: "xpto", Name: "test", Category: "testcategory", Price: brl(20000), PublishAt: &future}
+
+	name := "renamed"
+	patch := canonical.ProductPatch{Name: &name}
+
+	patched := stored
+	patched.Name = name
+
+	admin := mock.MatchedBy(func(ctx context.Context) bool { return visibility.FromContext(ctx).Admin })
+
+	mockRepo.On("GetProductById", admin, "xpto").Return(stored, nil)
+	mockRepo.On("PatchProduct", admin, "xpto", int64(1), patch).Return(patched, nil)
+	mockRepo.On("AppendAuditEntry", mock.Anything, mock.Anything).Return(nil)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	product, err := service.PatchProduct(context.Background(), "xpto", 1, patch)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "renamed", product.Name)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateProduct_RejectsClosedWindow(t *testing.T) {
+	mockRepo := new(MockRepository)
+
+	publishAt := time.Now()
+	unpublishAt := publishAt.Add(-time.Minute)
+
+	service := New(config.Config{}, mockRepo, nil, nil, logrus.New())
+
+	_, err := service.CreateProduct(context.Background(), canonical.Product{
+		Name:        "test",
+		Category:    "testcategory",
+		Price:       brl(20000),
+		PublishAt:   &publishAt,
+		UnpublishAt: &unpublishAt,
+	})
+
+	assert.Equal(t, errs.KindValidation, errs.KindOf(err))
+	assert.Equal(t, []errs.FieldError{{Field: "unpublish_at", Message: "must be after publish_at"}}, errs.Fields(err))
+
+	mockRepo.AssertExpectations(t)
+}
+
+var testCategory = canonical.Category{Slug: "testcategory", Name: "Test category", Path: "/testcategory", Version: 1}
+
+func brl(amount int64) money.Money {
+	return money.Money{Amount: amount, Currency: "BRL"}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nelsonalves117/go-products-api/internal/actor"
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+)
+
+// TransitionProduct moves a product to another lifecycle status, if it can go
+// there from the one it is in. The actor is recorded in the audit log.
+func (service *service) TransitionProduct(ctx context.Context, id string, version int64, status string) (canonical.Product, error) {
+	ctx, cancel := service.withTimeout(ctx, "transition_product")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	if !slices.Contains(canonical.Statuses, status) {
+		return canonical.Product{}, errs.InvalidFields(errs.FieldError{Field: "status", Message: "must be one of " + strings.Join(canonical.Statuses, ", ")})
+	}
+
+	before, err := service.repo.GetProductById(ctx, id)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	// A stale client is told so before being told about a transition that
+	// may only be illegal from a status it has not seen.
+	if before.Version != version {
+		return canonical.Product{}, errs.VersionConflict(fmt.Sprintf("product %s is no longer at version %d", id, version))
+	}
+
+	if !canonical.CanTransition(before.Status, status) {
+		return canonical.Product{}, errs.Validation(fmt.Sprintf("product %s cannot go from %s to %s", id, before.Status, status))
+	}
+
+	product, err := service.repo.PatchProduct(ctx, id, version, canonical.ProductPatch{Status: &status})
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to transition a product")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to transition a product")
+	}
+
+	service.audit(ctx, canonical.OperationTransition, actor.FromContext(ctx), before, product)
+
+	return product, nil
+}
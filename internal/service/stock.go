@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/nelsonalves117/go-products-api/internal/actor"
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+)
+
+// stockWriteAttempts bounds how many times a stock adjustment is retried when
+// another write to the product gets in between reading and writing it.
+const stockWriteAttempts = 3
+
+func (service *service) GetStock(ctx context.Context, id string) (canonical.Stock, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_stock")
+	defer cancel()
+
+	product, err := service.repo.GetProductById(ctx, id)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return canonical.Stock{}, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	return service.toStock(product), nil
+}
+
+// AdjustStock records movement, a receipt, sale, adjustment or return, and
+// applies its quantity, negative to take stock out, to the stock of a product
+// at the warehouse of the movement. Unless the product allows backorders, no
+// warehouse may end up with negative stock, nor the product with less stock
+// than it has reserved.
+func (service *service) AdjustStock(ctx context.Context, id string, movement canonical.StockMovement) (canonical.Stock, error) {
+	ctx, cancel := service.withTimeout(ctx, "adjust_stock")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	if movement.Type == "" {
+		movement.Type = canonical.MovementAdjustment
+	}
+
+	err := checkMovement(movement)
+	if err != nil {
+		return canonical.Stock{}, err
+	}
+
+	warehouse, quantity := movement.Warehouse, movement.Quantity
+	if !slices.Contains(service.warehouses, warehouse) {
+		return canonical.Stock{}, errs.NotFound("warehouse " + warehouse + " not found")
+	}
+
+	movement.ProductId = id
+
+	for attempt := 1; ; attempt++ {
+		before, err := service.repo.GetProductById(ctx, id)
+		if err != nil {
+			service.logger.WithError(err).Error("error occurred while trying to get a product")
+			return canonical.Stock{}, errs.Wrap(err, "error occurred while trying to get a product")
+		}
+
+		levels := adjustLevel(service.stockLevels(before), warehouse, quantity)
+		if !before.Backorders && canonical.StockAt(levels, warehouse) < 0 {
+			return canonical.Stock{}, errs.InvalidFields(errs.FieldError{
+				Field:   "quantity",
+				Message: fmt.Sprintf("would leave warehouse %s with negative stock", warehouse),
+			})
+		}
+
+		// Reserved units must stay in stock for their reservations to be
+		// confirmed.
+		if !before.Backorders && canonical.TotalStock(levels) < before.Reserved {
+			return canonical.Stock{}, errs.InvalidFields(errs.FieldError{
+				Field:   "quantity",
+				Message: fmt.Sprintf("would leave less stock than the %d units reserved", before.Reserved),
+			})
+		}
+
+		product, err := service.repo.SetStockLevels(ctx, id, before.Version, levels)
+		if errs.KindOf(err) == errs.KindVersionConflict && attempt < stockWriteAttempts {
+			continue
+		}
+
+		if err != nil {
+			service.logger.WithError(err).Error("error occurred while trying to adjust stock")
+			return canonical.Stock{}, errs.Wrap(err, "error occurred while trying to adjust stock")
+		}
+
+		service.audit(ctx, canonical.OperationAdjustStock, actor.FromContext(ctx), before, product)
+		service.recordMovements(ctx, actor.FromContext(ctx), []canonical.StockMovement{movement})
+		service.checkLowStock(ctx, before, product)
+
+		return service.toStock(product), nil
+	}
+}
+
+// toStock lists the stock of product at every configured warehouse, followed
+// by any it still has stock at that is no longer configured.
+func (service *service) toStock(product canonical.Product) canonical.Stock {
+	stored := service.stockLevels(product)
+
+	levels := make([]canonical.StockLevel, 0, len(service.warehouses))
+	for _, warehouse := range service.warehouses {
+		levels = append(levels, canonical.StockLevel{
+			Warehouse: warehouse,
+			Quantity:  canonical.StockAt(stored, warehouse),
+		})
+	}
+
+	for _, level := range stored {
+		if !slices.Contains(service.warehouses, level.Warehouse) {
+			levels = append(levels, level)
+		}
+	}
+
+	return canonical.Stock{
+		ProductId:  product.Id,
+		Levels:     levels,
+		Available:  product.Available(),
+		Reserved:   product.Reserved,
+		Backorders: product.Backorders,
+		Version:    product.Version,
+	}
+}
+
+// stockLevels returns a copy of the stock levels of product. Products stored
+// before stock was kept per warehouse have all of it at the default one.
+func (service *service) stockLevels(product canonical.Product) []canonical.StockLevel {
+	if len(product.StockLevels) == 0 && product.Stock != 0 {
+		return []canonical.StockLevel{{Warehouse: service.warehouses[0], Quantity: product.Stock}}
+	}
+
+	return slices.Clone(product.StockLevels)
+}
+
+// levelsWithStock returns the stock levels product has once its total stock is
+// set to stock. Product writes carry a total only, so the difference is made
+// up at the default warehouse.
+func (service *service) levelsWithStock(product canonical.Product, stock int) []canonical.StockLevel {
+	levels := service.stockLevels(product)
+
+	difference := stock - canonical.TotalStock(levels)
+	if difference == 0 {
+		return levels
+	}
+
+	return adjustLevel(levels, service.warehouses[0], difference)
+}
+
+func adjustLevel(levels []canonical.StockLevel, warehouse string, quantity int) []canonical.StockLevel {
+	for i := range levels {
+		if levels[i].Warehouse == warehouse {
+			levels[i].Quantity += quantity
+			return levels
+		}
+	}
+
+	return append(levels, canonical.StockLevel{Warehouse: warehouse, Quantity: quantity})
+}
+
+// checkStock rejects negative stock, at any warehouse or in total, and stock
+// below what reservations hold, for products that do not allow backorders.
+func checkStock(product canonical.Product) error {
+	if product.Backorders {
+		return nil
+	}
+
+	if product.Stock < 0 {
+		return errs.InvalidFields(errs.FieldError{Field: canonical.FieldStock, Message: "must not be negative unless backorders are allowed"})
+	}
+
+	if product.Stock < product.Reserved {
+		return errs.InvalidFields(errs.FieldError{
+			Field:   canonical.FieldStock,
+			Message: fmt.Sprintf("must not be below the %d units reserved unless backorders are allowed", product.Reserved),
+		})
+	}
+
+	for _, level := range product.StockLevels {
+		if level.Quantity < 0 {
+			return errs.InvalidFields(errs.FieldError{
+				Field:   canonical.FieldStock,
+				Message: fmt.Sprintf("would leave warehouse %s with negative stock", level.Warehouse),
+			})
+		}
+	}
+
+	return nil
+}
+
+// checkMovement rejects movements that cannot be made through AdjustStock and
+// quantities whose sign does not fit the type of the movement.
+func checkMovement(movement canonical.StockMovement) error {
+	var message string
+
+	switch {
+	case movement.Quantity == 0:
+		return errs.InvalidFields(errs.FieldError{Field: "quantity", Message: "must not be zero"})
+	case movement.Type == canonical.MovementReceipt || movement.Type == canonical.MovementReturn:
+		if movement.Quantity < 0 {
+			message = "must be positive for a " + movement.Type
+		}
+	case movement.Type == canonical.MovementSale:
+		if movement.Quantity > 0 {
+			message = "must be negative for a sale"
+		}
+	case movement.Type != canonical.MovementAdjustment:
+		return errs.InvalidFields(errs.FieldError{
+			Field:   "type",
+			Message: fmt.Sprintf("must be one of %s, %s, %s or %s", canonical.MovementReceipt, canonical.MovementSale, canonical.MovementAdjustment, canonical.MovementReturn),
+		})
+	}
+
+	if message != "" {
+		return errs.InvalidFields(errs.FieldError{Field: "quantity", Message: message})
+	}
+
+	return nil
+}
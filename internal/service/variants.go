@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"unicode/utf8"
+
+	"github.com/nelsonalves117/go-products-api/internal/canonical"
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+	"github.com/nelsonalves117/go-products-api/internal/visibility"
+)
+
+const (
+	// maxVariants bounds the variants of a parent, which are all read to sum
+	// them up whenever the parent is.
+	maxVariants = 100
+
+	maxSKULength = 64
+)
+
+func (service *service) GetVariants(ctx context.Context, parentId string) ([]canonical.Product, error) {
+	ctx, cancel := service.withTimeout(ctx, "get_variants")
+	defer cancel()
+
+	parent, err := service.repo.GetProductById(ctx, parentId)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return nil, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	variants, err := service.getVariants(ctx, parentId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range variants {
+		variants[i] = canonical.InheritPrice(variants[i], parent)
+	}
+
+	return variants, nil
+}
+
+// CreateVariant adds a variant to a parent product. The variant is named after
+// the parent and its variant attributes and takes the category and the
+// attributes of the parent. Unless it is given a price of its own, it is sold
+// at the price of the parent, whatever the parent is repriced to later.
+func (service *service) CreateVariant(ctx context.Context, parentId string, variant canonical.Product) (canonical.Product, error) {
+	ctx, cancel := service.withTimeout(ctx, "create_variant")
+	defer cancel()
+
+	ctx = visibility.Admin(ctx)
+
+	parent, err := service.repo.GetProductById(ctx, parentId)
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get a product")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to get a product")
+	}
+
+	if parent.ParentId != "" {
+		return canonical.Product{}, errs.Validation("product " + parentId + " is a variant and cannot have variants of its own")
+	}
+
+	if variant.Price.IsZero() {
+		variant.Price = parent.Price
+		variant.InheritsPrice = true
+	}
+
+	err = checkVariant(parent, variant)
+	if err != nil {
+		return canonical.Product{}, errs.Wrap(err, "invalid variant")
+	}
+
+	variant.ParentId = parent.Id
+	variant.Name = canonical.VariantName(parent.Name, variant.VariantAttributes)
+	variant.Category = parent.Category
+	variant.Attributes = parent.Attributes
+	variant.PriceOverrides = nil
+
+	err = validateProduct(variant)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	siblings, err := service.getVariants(ctx, parent.Id)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	if len(siblings) >= maxVariants {
+		return canonical.Product{}, errs.Validation(fmt.Sprintf("product %s already has %d variants", parent.Id, maxVariants))
+	}
+
+	for _, sibling := range siblings {
+		if maps.Equal(sibling.VariantAttributes, variant.VariantAttributes) {
+			return canonical.Product{}, errs.Conflict("product " + parent.Id + " already has a variant with these attributes")
+		}
+	}
+
+	return service.createProduct(ctx, variant)
+}
+
+func (service *service) getVariants(ctx context.Context, parentId string) ([]canonical.Product, error) {
+	productPage, err := service.repo.GetAllProducts(ctx, canonical.ProductQuery{ParentId: parentId}, canonical.PageRequest{Limit: maxVariants})
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get variants")
+		return nil, errs.Wrap(err, "error occurred while trying to get variants")
+	}
+
+	return productPage.Products, nil
+}
+
+// withVariants completes product as it is read on its own: a parent with the
+// summary of its variants and a variant with the price it is sold at.
+func (service *service) withVariants(ctx context.Context, product canonical.Product) (canonical.Product, error) {
+	if product.ParentId != "" {
+		return service.inheritPrice(ctx, product)
+	}
+
+	products := []canonical.Product{product}
+
+	err := service.summarizeVariants(ctx, products)
+	if err != nil {
+		return canonical.Product{}, err
+	}
+
+	return products[0], nil
+}
+
+// summarizeVariants sets the summary of their variants on the parents among
+// products, reading the variants of all of them at once.
+func (service *service) summarizeVariants(ctx context.Context, products []canonical.Product) error {
+	parentIds := make([]string, 0, len(products))
+	for _, product := range products {
+		if product.ParentId == "" {
+			parentIds = append(parentIds, product.Id)
+		}
+	}
+
+	if len(parentIds) == 0 {
+		return nil
+	}
+
+	variants := map[string][]canonical.Product{}
+	page := canonical.PageRequest{Limit: maxPageLimit}
+	for {
+		productPage, err := service.repo.GetAllProducts(ctx, canonical.ProductQuery{ParentIds: parentIds}, page)
+		if err != nil {
+			service.logger.WithError(err).Error("error occurred while trying to get variants")
+			return errs.Wrap(err, "error occurred while trying to get variants")
+		}
+
+		for _, variant := range productPage.Products {
+			variants[variant.ParentId] = append(variants[variant.ParentId], variant)
+		}
+
+		if !productPage.HasMore {
+			break
+		}
+
+		page.Cursor = productPage.NextCursor
+	}
+
+	for i, product := range products {
+		if product.ParentId == "" {
+			products[i].Variants = canonical.SummarizeVariants(product, variants[product.Id])
+		}
+	}
+
+	return nil
+}
+
+// inheritPrice fills in the current price of the parent of product if it is a
+// variant without a price of its own. A variant whose parent is gone keeps the
+// last price it had.
+func (service *service) inheritPrice(ctx context.Context, product canonical.Product) (canonical.Product, error) {
+	if !product.InheritsPrice {
+		return product, nil
+	}
+
+	// The parent prices its variants even when it is not on sale itself.
+	parent, err := service.repo.GetProductById(visibility.Admin(ctx), product.ParentId)
+	if errs.KindOf(err) == errs.KindNotFound {
+		return product, nil
+	}
+
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get the parent of a variant")
+		return canonical.Product{}, errs.Wrap(err, "error occurred while trying to get the parent of a variant")
+	}
+
+	return canonical.InheritPrice(product, parent), nil
+}
+
+// hasVariants reports whether the product id has live variants.
+func (service *service) hasVariants(ctx context.Context, id string) (bool, error) {
+	productPage, err := service.repo.GetAllProducts(ctx, canonical.ProductQuery{ParentId: id}, canonical.PageRequest{Limit: 1})
+	if err != nil {
+		service.logger.WithError(err).Error("error occurred while trying to get variants")
+		return false, errs.Wrap(err, "error occurred while trying to get variants")
+	}
+
+	return len(productPage.Products) > 0, nil
+}
+
+// checkCategoryMove refuses to move product to category apart from its
+// variants or its parent, which share their category.
+func (service *service) checkCategoryMove(ctx context.Context, product canonical.Product, category string) error {
+	if category == product.Category {
+		return nil
+	}
+
+	if product.ParentId != "" {
+		return errs.InvalidFields(errs.FieldError{Field: "category", Message: "must be the category of the parent product"})
+	}
+
+	hasVariants, err := service.hasVariants(ctx, product.Id)
+	if err != nil {
+		return err
+	}
+
+	if hasVariants {
+		return errs.Conflict("product " + product.Id + " has variants and cannot move to another category without them")
+	}
+
+	return nil
+}
+
+// checkVariant rejects variants without a SKU or attributes, and variants
+// priced in another currency than their parent, which its price range could
+// not span.
+func checkVariant(parent, variant canonical.Product) error {
+	var fields []errs.FieldError
+
+	switch {
+	case variant.SKU == "":
+		fields = append(fields, errs.FieldError{Field: "sku", Message: "is required"})
+	case utf8.RuneCountInString(variant.SKU) > maxSKULength:
+		fields = append(fields, errs.FieldError{Field: "sku", Message: fmt.Sprintf("must have at most %d characters", maxSKULength)})
+	}
+
+	if len(variant.VariantAttributes) == 0 {
+		fields = append(fields, errs.FieldError{Field: "variant_attributes", Message: "must not be empty"})
+	}
+
+	for key, value := range variant.VariantAttributes {
+		if key == "" || value == "" {
+			fields = append(fields, errs.FieldError{Field: "variant_attributes", Message: "must not contain empty names or values"})
+			break
+		}
+	}
+
+	if variant.Price.Currency != parent.Price.Currency {
+		fields = append(fields, errs.FieldError{Field: "price", Message: "must be in " + parent.Price.Currency.String() + ", the currency of the parent"})
+	}
+
+	if len(fields) > 0 {
+		return errs.InvalidFields(fields...)
+	}
+
+	return nil
+}
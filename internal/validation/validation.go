@@ -0,0 +1,164 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nelsonalves117/go-products-api/internal/errs"
+)
+
+// Struct checks v against the rules declared in its `validate` struct tags and
+// returns a validation error listing every field that broke a rule.
+//
+// Supported rules, separated by commas:
+//
+//	required  the value must not be the zero value (blank strings are zero)
+//	min=N     numbers must be >= N, strings/slices/maps must have >= N elements
+//	max=N     numbers must be <= N, strings/slices/maps must have <= N elements
+//
+// Types with a Float64 method, such as money.Money, count as numbers.
+//
+// Fields are reported by their json name, falling back to bson and then to the
+// Go field name.
+func Struct(v any) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("validation: expected a struct, got %s", value.Kind())
+	}
+
+	var fields []errs.FieldError
+
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+
+		tag := field.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := fieldName(field)
+
+		for _, rule := range strings.Split(tag, ",") {
+			message, err := check(value.Field(i), rule)
+			if err != nil {
+				return fmt.Errorf("validation: field %s: %w", name, err)
+			}
+
+			if message != "" {
+				fields = append(fields, errs.FieldError{Field: name, Message: message})
+				break
+			}
+		}
+	}
+
+	if len(fields) > 0 {
+		return errs.InvalidFields(fields...)
+	}
+
+	return nil
+}
+
+func check(value reflect.Value, rule string) (string, error) {
+	name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			if name == "required" {
+				return "is required", nil
+			}
+
+			return "", nil
+		}
+
+		value = value.Elem()
+	}
+
+	switch name {
+	case "required":
+		if isZero(value) {
+			return "is required", nil
+		}
+
+		return "", nil
+	case "min", "max":
+		bound, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s bound %q", name, param)
+		}
+
+		return checkBound(value, name, bound)
+	default:
+		return "", fmt.Errorf("unknown rule %q", name)
+	}
+}
+
+func checkBound(value reflect.Value, name string, bound float64) (string, error) {
+	var (
+		actual float64
+		unit   string
+	)
+
+	switch value.Kind() {
+	case reflect.String:
+		actual, unit = float64(utf8.RuneCountInString(value.String())), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		actual, unit = float64(value.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	case reflect.Struct:
+		number, ok := value.Interface().(interface{ Float64() float64 })
+		if !ok {
+			return "", fmt.Errorf("rule %s does not apply to %s", name, value.Type())
+		}
+
+		actual = number.Float64()
+	default:
+		return "", fmt.Errorf("rule %s does not apply to %s", name, value.Kind())
+	}
+
+	limit := strconv.FormatFloat(bound, 'f', -1, 64)
+
+	switch {
+	case name == "min" && actual < bound:
+		if unit != "" {
+			return "must have at least " + limit + unit, nil
+		}
+
+		return "must be greater than or equal to " + limit, nil
+	case name == "max" && actual > bound:
+		if unit != "" {
+			return "must have at most " + limit + unit, nil
+		}
+
+		return "must be less than or equal to " + limit, nil
+	}
+
+	return "", nil
+}
+
+func isZero(value reflect.Value) bool {
+	if value.Kind() == reflect.String {
+		return strings.TrimSpace(value.String()) == ""
+	}
+
+	return value.IsZero()
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "bson"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return field.Name
+}
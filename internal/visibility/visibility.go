@@ -0,0 +1,48 @@
+package visibility
+
+import (
+	"context"
+	"time"
+)
+
+// Scope tells reads of products which publication windows to apply. Admin
+// reads see every product; the others only see the products published at
+// At, which is now unless a preview asked for another time.
+type Scope struct {
+	Admin bool
+	At    time.Time
+}
+
+// Time returns the time the publication windows are evaluated at.
+func (scope Scope) Time() time.Time {
+	if scope.At.IsZero() {
+		return time.Now()
+	}
+
+	return scope.At
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries scope.
+func NewContext(ctx context.Context, scope Scope) context.Context {
+	return context.WithValue(ctx, contextKey{}, scope)
+}
+
+// Admin returns a copy of ctx whose reads see every product. Writes and jobs
+// run in it, since they work on products whether they are published or not.
+func Admin(ctx context.Context) context.Context {
+	return NewContext(ctx, Scope{Admin: true})
+}
+
+// Preview returns a copy of ctx whose reads see the products published at at.
+func Preview(ctx context.Context, at time.Time) context.Context {
+	return NewContext(ctx, Scope{At: at})
+}
+
+// FromContext returns the scope carried by ctx. Without one, reads see the
+// products published now.
+func FromContext(ctx context.Context) Scope {
+	scope, _ := ctx.Value(contextKey{}).(Scope)
+	return scope
+}